@@ -2,13 +2,24 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
 	"net/http"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Davut97/go-user/pkg/app"
 	"github.com/Davut97/go-user/pkg/config"
+	"github.com/Davut97/go-user/pkg/geoip"
 	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/secret"
+	"github.com/Davut97/go-user/pkg/token"
 	"github.com/Davut97/go-user/repo"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/labstack/echo/v4"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -31,7 +42,20 @@ func main() {
 	//"https://api.chucknorris.io"
 	httpClient := http.Client{}
 	httpClient.Timeout = time.Second * time.Duration(cn.JokesTimeout)
-	jokeClient := joke.NewChuckNorrisJokeClient(cn.JokesURL, &httpClient, cn.JokesLimit)
+	chuckNorrisClient := joke.NewChuckNorrisJokeClient(cn.JokesURL, &httpClient, cn.JokesLimit)
+	var blockedWords, blockedPatterns []string
+	if cn.JokesBlocklistFile != "" {
+		blockedWords, blockedPatterns, err = joke.LoadBlocklist(cn.JokesBlocklistFile)
+		if err != nil {
+			logger.Error("Failed to load jokes blocklist", zap.Error(err))
+			return
+		}
+	}
+	filter, err := joke.NewFilter(blockedWords, blockedPatterns, strings.Split(cn.JokesExcludedCategories, ","))
+	if err != nil {
+		logger.Error("Failed to create jokes filter", zap.Error(err))
+		return
+	}
 
 	//("mongodb://localhost:27017"
 	clientOptions := options.Client().ApplyURI(cn.DBConnectionString)
@@ -40,17 +64,305 @@ func main() {
 		logger.Error("Failed to connect to MongoDB", zap.Error(err))
 		return
 	}
-	userRepo, err := repo.NewMongoUserRepository(db.Database(cn.DBName).Collection("users"))
+	submissionRepo, err := repo.NewMongoSubmissionRepository(db.Database(cn.DBName).Collection("joke_submissions"))
+	if err != nil {
+		logger.Error("Failed to create submission repository", zap.Error(err))
+		return
+	}
+	// Jokes are served to all tenants, so only the submissions of the default one are mixed in
+	mixedJokeClient := joke.NewMixedJokeClient(chuckNorrisClient, joke.NewSubmissionJokeClient(submissionRepo, repo.DefaultTenant), cn.JokesSubmissionsShare)
+	jokeClient := joke.NewFilteringJokeClient(mixedJokeClient, filter)
+	safeJokeClient := joke.NewFilteringJokeClient(mixedJokeClient, filter.WithCategories(strings.Split(cn.JokesSafeExcludedCategories, ",")...))
+	hasher, err := password.NewHasherFor(cn.PasswordAlgorithm, password.Argon2idParams{
+		Memory:      uint32(cn.PasswordArgon2Memory),
+		Time:        uint32(cn.PasswordArgon2Time),
+		Parallelism: uint8(cn.PasswordArgon2Parallelism),
+		SaltLength:  password.DefaultArgon2idParams.SaltLength,
+		KeyLength:   password.DefaultArgon2idParams.KeyLength,
+	}, cn.PasswordBcryptCost)
+	if err != nil {
+		logger.Error("Failed to create password hasher", zap.Error(err))
+		return
+	}
+	if cn.PasswordPeppers != "" || cn.PasswordPeppersFile != "" {
+		var peppers *password.Peppers
+		if cn.PasswordPeppersFile != "" {
+			peppers, err = password.LoadPeppers(cn.PasswordPepperCurrent, cn.PasswordPeppersFile)
+		} else {
+			peppers, err = password.ParsePeppers(cn.PasswordPepperCurrent, cn.PasswordPeppers)
+		}
+		if err != nil {
+			logger.Error("Failed to load password peppers", zap.Error(err))
+			return
+		}
+		hasher = hasher.WithPeppers(peppers)
+	}
+	passwordPolicy := password.Policy{
+		MinLength:      cn.PasswordMinLength,
+		MaxLength:      cn.PasswordMaxLength,
+		RequireUpper:   cn.PasswordRequireUpper,
+		RequireLower:   cn.PasswordRequireLower,
+		RequireDigit:   cn.PasswordRequireDigit,
+		RequireSymbol:  cn.PasswordRequireSymbol,
+		RejectPersonal: true,
+		RejectCommon:   true,
+		History:        cn.PasswordHistory,
+	}
+	if cn.PasswordBreachedFile != "" {
+		breached, err := password.OpenBreached(cn.PasswordBreachedFile)
+		if err != nil {
+			logger.Error("Failed to open breached passwords file", zap.Error(err))
+			return
+		}
+		defer breached.Close()
+		passwordPolicy.Breached = breached
+	}
+	userRepo, err := repo.NewMongoUserRepository(db.Database(cn.DBName).Collection("users"), hasher)
 	if err != nil {
 		logger.Error("Failed to create user repository", zap.Error(err))
 		return
 	}
+	jokeRepo := repo.NewMongoJokeRepository(db.Database(cn.DBName).Collection("jokes"))
+	voteRepo, err := repo.NewMongoVoteRepository(db.Database(cn.DBName).Collection("joke_votes"), db.Database(cn.DBName).Collection("jokes"))
+	if err != nil {
+		logger.Error("Failed to create vote repository", zap.Error(err))
+		return
+	}
+	dailyLocation, err := time.LoadLocation(cn.JokesDailyTimeZone)
+	if err != nil {
+		logger.Error("Failed to load joke of the day time zone", zap.Error(err))
+		return
+	}
+	daily := joke.NewDaily(jokeClient, repo.NewMongoDailyJokeRepository(db.Database(cn.DBName).Collection("daily_jokes")), dailyLocation)
+	loginAttemptRepo, err := repo.NewMongoLoginAttemptRepository(db.Database(cn.DBName).Collection("login_attempts"))
+	if err != nil {
+		logger.Error("Failed to create login attempt repository", zap.Error(err))
+		return
+	}
+	limiter := lockout.NewLimiter(loginAttemptRepo, lockout.Policy{
+		FreeAttempts: cn.LoginFreeAttempts,
+		BaseDelay:    time.Second * time.Duration(cn.LoginBaseDelay),
+		MaxDelay:     time.Second * time.Duration(cn.LoginMaxDelay),
+		Threshold:    cn.LoginLockoutThreshold,
+		IPThreshold:  cn.LoginIPLockoutThreshold,
+		Duration:     time.Minute * time.Duration(cn.LoginLockoutDuration),
+		Window:       time.Minute * time.Duration(cn.LoginFailureWindow),
+	})
+	resetRequestRepo, err := repo.NewMongoLoginAttemptRepository(db.Database(cn.DBName).Collection("password_reset_requests"))
+	if err != nil {
+		logger.Error("Failed to create password reset request repository", zap.Error(err))
+		return
+	}
+	resetLimiter := lockout.NewLimiter(resetRequestRepo, lockout.QuotaPolicy(cn.PasswordResetPerEmail, cn.PasswordResetPerIP, time.Hour))
+	oneTimeTokenRepo, err := repo.NewMongoOneTimeTokenRepository(db.Database(cn.DBName).Collection("one_time_tokens"))
+	if err != nil {
+		logger.Error("Failed to create one-time token repository", zap.Error(err))
+		return
+	}
+	var mailer mail.Sender
+	switch cn.MailSender {
+	case "smtp":
+		mailer = mail.NewSMTPSender(cn.SMTPHost, cn.SMTPPort, cn.SMTPUsername, cn.SMTPPassword, cn.MailFrom)
+	case "log":
+		mailer = mail.NewLogSender(logger)
+	default:
+		logger.Error("Unknown mail sender", zap.String("sender", cn.MailSender))
+		return
+	}
+	twoFactor := app.TwoFactor{Issuer: cn.TOTPIssuer}
+	if cn.TOTPEncryptionKey != "" {
+		twoFactor.Box, err = secret.ParseBox(cn.TOTPEncryptionKey)
+		if err != nil {
+			logger.Error("Failed to parse TOTP encryption key", zap.Error(err))
+			return
+		}
+	}
+	webAuthnCredentialRepo, err := repo.NewMongoWebAuthnCredentialRepository(db.Database(cn.DBName).Collection("webauthn_credentials"))
+	if err != nil {
+		logger.Error("Failed to create WebAuthn credential repository", zap.Error(err))
+		return
+	}
+	webAuthnSessionRepo, err := repo.NewMongoWebAuthnSessionRepository(db.Database(cn.DBName).Collection("webauthn_sessions"))
+	if err != nil {
+		logger.Error("Failed to create WebAuthn session repository", zap.Error(err))
+		return
+	}
+	passkeys := app.WebAuthn{Credentials: webAuthnCredentialRepo, Sessions: webAuthnSessionRepo}
+	if cn.WebAuthnRPID != "" {
+		passkeys.RP, err = webauthn.New(&webauthn.Config{
+			RPID:          cn.WebAuthnRPID,
+			RPDisplayName: cn.WebAuthnRPDisplayName,
+			RPOrigins:     strings.Split(cn.WebAuthnRPOrigins, ","),
+		})
+		if err != nil {
+			logger.Error("Failed to create WebAuthn relying party", zap.Error(err))
+			return
+		}
+	}
+	groupRepo, err := repo.NewMongoGroupRepository(db.Database(cn.DBName).Collection("groups"))
+	if err != nil {
+		logger.Error("Failed to create group repository", zap.Error(err))
+		return
+	}
+	apiKeyRepo, err := repo.NewMongoAPIKeyRepository(db.Database(cn.DBName).Collection("api_keys"))
+	if err != nil {
+		logger.Error("Failed to create API key repository", zap.Error(err))
+		return
+	}
+	sessionRepo, err := repo.NewMongoSessionRepository(db.Database(cn.DBName).Collection("sessions"))
+	if err != nil {
+		logger.Error("Failed to create session repository", zap.Error(err))
+		return
+	}
+	loginEventRepo, err := repo.NewMongoLoginEventRepository(db.Database(cn.DBName).Collection("login_events"))
+	if err != nil {
+		logger.Error("Failed to create login event repository", zap.Error(err))
+		return
+	}
+	loginHistory := app.LoginHistory{
+		Events:    loginEventRepo,
+		MaxSpeed:  cn.LoginMaxSpeed,
+		Retention: 24 * time.Hour * time.Duration(cn.LoginHistoryDays),
+	}
+	if cn.GeoIPFile != "" {
+		locations, err := geoip.Open(cn.GeoIPFile)
+		if err != nil {
+			logger.Error("Failed to open GeoIP file", zap.Error(err))
+			return
+		}
+		defer locations.Close()
+		loginHistory.Locator = locations
+	}
+	auditRepo, err := repo.NewMongoAuditRepository(db.Database(cn.DBName).Collection("audit_log"))
+	if err != nil {
+		logger.Error("Failed to create audit repository", zap.Error(err))
+		return
+	}
+	invitationRepo, err := repo.NewMongoInvitationRepository(db.Database(cn.DBName).Collection("invitations"))
+	if err != nil {
+		logger.Error("Failed to create invitation repository", zap.Error(err))
+		return
+	}
+	oauthClientRepo, err := repo.NewMongoOAuthClientRepository(db.Database(cn.DBName).Collection("oauth_clients"))
+	if err != nil {
+		logger.Error("Failed to create OAuth client repository", zap.Error(err))
+		return
+	}
+	authorizationCodeRepo, err := repo.NewMongoAuthorizationCodeRepository(db.Database(cn.DBName).Collection("authorization_codes"))
+	if err != nil {
+		logger.Error("Failed to create authorization code repository", zap.Error(err))
+		return
+	}
+	provider := app.OIDC{
+		Clients:    oauthClientRepo,
+		Codes:      authorizationCodeRepo,
+		ConsentURL: cn.OIDCConsentURL,
+		CodeTTL:    time.Minute,
+		TokenTTL:   time.Minute * time.Duration(cn.OIDCTokenTTL),
+	}
+	if cn.OIDCIssuer != "" {
+		var signingKey *rsa.PrivateKey
+		if cn.OIDCSigningKeyFile != "" {
+			signingKey, err = oidc.LoadKey(cn.OIDCSigningKeyFile)
+		} else {
+			logger.Warn("No OpenID Connect signing key configured, tokens handed to apps are invalidated on restart")
+			signingKey, err = oidc.GenerateKey()
+		}
+		if err != nil {
+			logger.Error("Failed to load OpenID Connect signing key", zap.Error(err))
+			return
+		}
+		provider.Signer, err = oidc.NewSigner(strings.TrimSuffix(cn.OIDCIssuer, "/"), signingKey)
+		if err != nil {
+			logger.Error("Failed to create OpenID Connect signer", zap.Error(err))
+			return
+		}
+	}
+	externalIdentityRepo, err := repo.NewMongoExternalIdentityRepository(db.Database(cn.DBName).Collection("external_identities"))
+	if err != nil {
+		logger.Error("Failed to create external identity repository", zap.Error(err))
+		return
+	}
+	federatedLoginRepo, err := repo.NewMongoFederatedLoginRepository(db.Database(cn.DBName).Collection("federated_logins"))
+	if err != nil {
+		logger.Error("Failed to create federated login repository", zap.Error(err))
+		return
+	}
+	federation := app.Federation{
+		Identities:  externalIdentityRepo,
+		Logins:      federatedLoginRepo,
+		CreateUsers: cn.OIDCLoginCreateUsers,
+		TTL:         10 * time.Minute,
+	}
+	if cn.OIDCLoginIssuer != "" {
+		federation.RP = oidc.NewRelyingParty(oidc.RelyingPartyConfig{
+			Issuer:       cn.OIDCLoginIssuer,
+			ClientID:     cn.OIDCLoginClientID,
+			ClientSecret: cn.OIDCLoginClientSecret,
+			RedirectURL:  cn.OIDCLoginRedirectURL,
+			Scopes:       strings.Fields(cn.OIDCLoginScopes),
+		}, &http.Client{Timeout: 10 * time.Second})
+	}
+	if err := app.BootstrapAdmins(userRepo, logger, strings.Split(cn.AdminEmails, ",")...); err != nil {
+		logger.Error("Failed to grant admin roles", zap.Error(err))
+		return
+	}
+	tokenIssuer := token.NewIssuer(cn.JWTSecret, time.Minute*time.Duration(cn.JWTExpiration))
 	e := echo.New()
 	if err != nil {
 		logger.Error("Failed to create echo instance", zap.Error(err))
 		return
 	}
-	a := app.NewApp(e, userRepo, logger, jokeClient)
+	e.IPExtractor, err = app.IPExtractor(strings.Split(cn.TrustedProxies, ","))
+	if err != nil {
+		logger.Error("Failed to parse trusted proxies", zap.Error(err))
+		return
+	}
+	a := app.NewApp(e, userRepo, logger, jokeClient,
+		app.WithSafeJokeClient(safeJokeClient),
+		app.WithPasswordHasher(hasher),
+		app.WithPasswordPolicy(passwordPolicy),
+		app.WithLockout(limiter),
+		app.WithTokenIssuer(tokenIssuer),
+		app.WithMailer(mailer),
+		app.WithOneTimeTokenRepository(oneTimeTokenRepo),
+		app.WithEmailVerification(app.Verification{
+			URL:      cn.EmailVerificationURL,
+			TTL:      time.Hour * time.Duration(cn.EmailVerificationTTL),
+			Required: cn.EmailVerificationRequired,
+		}),
+		app.WithPasswordReset(app.PasswordReset{
+			URL:     cn.PasswordResetURL,
+			TTL:     time.Minute * time.Duration(cn.PasswordResetTTL),
+			Limiter: resetLimiter,
+		}),
+		app.WithRegistration(app.Registration{
+			InviteOnly:  cn.RegistrationInviteOnly,
+			Invitations: invitationRepo,
+			URL:         cn.InvitationURL,
+			TTL:         time.Hour * time.Duration(cn.InvitationTTL),
+		}),
+		app.WithTwoFactor(twoFactor),
+		app.WithWebAuthn(passkeys),
+		app.WithOIDC(provider),
+		app.WithFederation(federation),
+		app.WithTenancy(app.Tenancy{
+			Users:   userRepo,
+			Tenants: strings.Split(cn.Tenants, ","),
+			Header:  cn.TenantHeader,
+		}),
+		app.WithJokeRepository(jokeRepo),
+		app.WithVoteRepository(voteRepo),
+		app.WithDailyJoke(daily),
+		app.WithSubmissionRepository(submissionRepo),
+		app.WithGroupRepository(groupRepo),
+		app.WithAPIKeyRepository(apiKeyRepo),
+		app.WithSessionRepository(sessionRepo),
+		app.WithLoginHistory(loginHistory),
+		app.WithImpersonation(app.Impersonation{Audit: auditRepo, TTL: time.Minute * time.Duration(cn.ImpersonationTTL)}),
+		app.WithJokeLimit(cn.JokesLimit),
+		app.WithTopJokesWindow(time.Hour*time.Duration(cn.JokesTopWindow)),
+	)
 	if err := a.Start(cn.BindAddress); err != nil {
 		logger.Error("Failed to start server", zap.Error(err))
 		return
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes are permissions of the user the key is limited to.
+	Scopes    []string   `json:"scopes" validate:"max=20,dive,required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// APIKeyResponse carries the key itself, which is only shown once.
+type APIKeyResponse struct {
+	repo.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey creates an API key limited to scopes the user is granted.
+func (a *App) CreateAPIKey(c echo.Context) error {
+	if a.apiKeyRepo == nil {
+		return apiKeysNotConfigured(c)
+	}
+	createRequest := new(CreateAPIKeyRequest)
+	if err := c.Bind(createRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(createRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	now := time.Now()
+	if createRequest.ExpiresAt != nil && !createRequest.ExpiresAt.After(now) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid expiry", Error: "expiresAt must be in the future"})
+	}
+	user := userFrom(c)
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	roles := inheritedRoles(user, groups)
+	scopes := []string{}
+	for _, scope := range createRequest.Scopes {
+		if !a.roles.Allows(roles, rbac.Permission(scope)) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid scope", Error: "permission " + scope + " is not granted to you"})
+		}
+		scopes = append(scopes, scope)
+	}
+
+	key, lookup, err := token.NewAPIKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to generate API key", Error: err.Error()})
+	}
+	apiKey, err := a.apiKeyRepo.Create(repo.APIKey{
+		TenantID:  tenantFrom(c),
+		UserID:    user.ID,
+		Name:      createRequest.Name,
+		Lookup:    lookup,
+		Hash:      token.HashOpaque(key),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: createRequest.ExpiresAt,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save API key", Error: err.Error()})
+	}
+	a.log.Info("Created API key", zap.String("apiKeyId", apiKey.ID), zap.String("userId", user.ID))
+	return c.JSON(http.StatusCreated, APIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// GetAPIKeys lists the API keys of the user, without the keys themselves.
+func (a *App) GetAPIKeys(c echo.Context) error {
+	if a.apiKeyRepo == nil {
+		return apiKeysNotConfigured(c)
+	}
+	keys, err := a.apiKeyRepo.FindByUser(userFrom(c).ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list API keys", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+func (a *App) DeleteAPIKey(c echo.Context) error {
+	if a.apiKeyRepo == nil {
+		return apiKeysNotConfigured(c)
+	}
+	err := a.apiKeyRepo.Delete(c.Param("id"), userFrom(c).ID)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "API key not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke API key", Error: err.Error()})
+	}
+	a.log.Info("Revoked API key", zap.String("apiKeyId", c.Param("id")), zap.String("userId", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// verifyAPIKey finds the API key with the lookup and checks key against its
+// hash. Unknown, wrong and expired keys are reported as errInvalidAPIKey.
+func (a *App) verifyAPIKey(lookup, key string, at time.Time) (repo.APIKey, error) {
+	if a.apiKeyRepo == nil {
+		return repo.APIKey{}, fmt.Errorf("%w: API keys are not configured", errInvalidAPIKey)
+	}
+	apiKey, err := a.apiKeyRepo.FindByLookup(lookup)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return repo.APIKey{}, fmt.Errorf("%w: unknown key", errInvalidAPIKey)
+	}
+	if err != nil {
+		return repo.APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token.HashOpaque(key)), []byte(apiKey.Hash)) != 1 {
+		return repo.APIKey{}, fmt.Errorf("%w: unknown key", errInvalidAPIKey)
+	}
+	if apiKey.ExpiresAt != nil && !at.Before(*apiKey.ExpiresAt) {
+		return repo.APIKey{}, fmt.Errorf("%w: key expired", errInvalidAPIKey)
+	}
+	return apiKey, nil
+}
+
+func apiKeysNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "API keys are not configured", Error: "missing API key repository"})
+}
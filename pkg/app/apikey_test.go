@@ -0,0 +1,125 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("support-1").Return(repo.User{ID: "support-1", Roles: []string{rbac.RoleSupport}}, nil).AnyTimes()
+	db.EXPECT().FindOne("user-2").Return(repo.User{ID: "user-2", Email: "fo@bo.com"}, nil)
+	keys := repo.NewMockAPIKeyRepository(ctrl)
+	var stored repo.APIKey
+	keys.EXPECT().Create(gomock.Any()).DoAndReturn(func(key repo.APIKey) (repo.APIKey, error) {
+		key.ID = "key-1"
+		stored = key
+		return key, nil
+	})
+	keys.EXPECT().FindByLookup(gomock.Any()).DoAndReturn(func(lookup string) (repo.APIKey, error) {
+		require.Equal(t, stored.Lookup, lookup)
+		return stored, nil
+	}).AnyTimes()
+	// Uses within a minute are only recorded once
+	keys.EXPECT().Touch("key-1", gomock.Any()).DoAndReturn(func(id string, at time.Time) error {
+		stored.LastUsedAt = &at
+		return nil
+	})
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithAPIKeyRepository(keys))
+	send := func(method, path, body, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if bearer == "" {
+			authorize(t, req, repo.User{ID: "support-1"})
+		} else {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Keys can only be limited to permissions of the user
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/me/api-keys", `{"name": "ci", "scopes": ["roles:manage"]}`, "").Code)
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/me/api-keys", `{"name": "ci", "expiresAt": "2001-01-01T00:00:00Z"}`, "").Code)
+	rec := send(http.MethodPost, "/me/api-keys", `{"name": "ci", "scopes": ["users:read"]}`, "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created APIKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "ci", created.Name)
+	require.Equal(t, []string{"users:read"}, created.Scopes)
+	require.Equal(t, token.HashOpaque(created.Key), stored.Hash)
+	require.NotContains(t, rec.Body.String(), stored.Hash)
+
+	require.Equal(t, http.StatusOK, send(http.MethodGet, "/admin/users/user-2", "", created.Key).Code)
+	// The user may unlock accounts, the key may not
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/admin/unlock", `{"email": "fo@bo.com"}`, created.Key).Code)
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/me/api-keys", `{"name": "more"}`, created.Key).Code)
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/me/password", `{}`, created.Key).Code)
+	lookup, _ := token.ParseAPIKey(created.Key)
+	require.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/users/user-2", "", token.APIKeyPrefix+lookup+"_guessed").Code)
+
+	expired := time.Now().Add(-time.Minute)
+	stored.ExpiresAt = &expired
+	require.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/users/user-2", "", created.Key).Code)
+
+}
+
+func TestAPIKeyUnknown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	key, lookup, err := token.NewAPIKey()
+	require.NoError(t, err)
+	keys := repo.NewMockAPIKeyRepository(ctrl)
+	keys.EXPECT().FindByLookup(lookup).Return(repo.APIKey{}, mongo.ErrNoDocuments)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/me/api-keys", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+key)
+	rec := httptest.NewRecorder()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithAPIKeyRepository(keys))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	keys := repo.NewMockAPIKeyRepository(ctrl)
+	keys.EXPECT().FindByUser("user-1").Return([]repo.APIKey{{ID: "key-1", Name: "ci", Hash: "secret-hash"}}, nil)
+	keys.EXPECT().Delete("key-1", "user-1").Return(nil)
+	keys.EXPECT().Delete("key-2", "user-1").Return(mongo.ErrNoDocuments)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithAPIKeyRepository(keys))
+	req := httptest.NewRequest(http.MethodGet, "/me/api-keys", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "secret-hash")
+
+	for id, code := range map[string]int{"key-1": http.StatusNoContent, "key-2": http.StatusNotFound} {
+		req = httptest.NewRequest(http.MethodDelete, "/me/api-keys/"+id, nil)
+		authorize(t, req, repo.User{ID: "user-1"})
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, code, rec.Code)
+	}
+
+}
@@ -2,8 +2,15 @@ package app
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
 	"github.com/Davut97/go-user/repo"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -15,6 +22,246 @@ type App struct {
 	log      *zap.Logger
 	userRepo repo.UserRepository
 	joke     joke.JokeClient
+	safeJoke joke.JokeClient
+	tokens   *token.Issuer
+	hasher   *password.Hasher
+	policy   password.Policy
+	lockout  *lockout.Limiter
+	jokeRepo repo.JokeRepository
+	voteRepo repo.VoteRepository
+	topJokes time.Duration
+	daily    *joke.Daily
+
+	submissionRepo repo.SubmissionRepository
+	jokeLimit      int
+	roles          rbac.Roles
+	groupRepo      repo.GroupRepository
+	apiKeyRepo     repo.APIKeyRepository
+	sessionRepo    repo.SessionRepository
+	impersonation  Impersonation
+
+	mailer       mail.Sender
+	tokenRepo    repo.OneTimeTokenRepository
+	verification Verification
+	reset        PasswordReset
+	twoFactor    TwoFactor
+	webAuthn     WebAuthn
+	tenancy      Tenancy
+	registration Registration
+	oidc         OIDC
+	federation   Federation
+	loginHistory LoginHistory
+
+	// background tracks the work running after responses, Stop waits for it.
+	background sync.WaitGroup
+}
+
+// Verification configures the email verification links sent on signup.
+type Verification struct {
+	// URL is the page verification links point to, the token is appended as the token query parameter.
+	URL string
+	TTL time.Duration
+	// Required rejects logins of users who did not verify their email yet.
+	Required bool
+}
+
+// Option configures optional dependencies of the App.
+type Option func(*App)
+
+// WithSafeJokeClient sets the client used for requests asking for safe jokes only.
+func WithSafeJokeClient(client joke.JokeClient) Option {
+	return func(a *App) {
+		a.safeJoke = client
+	}
+}
+
+// WithPasswordHasher sets the hasher used to verify passwords on login, it
+// must be the one the user repository hashes with.
+func WithPasswordHasher(hasher *password.Hasher) Option {
+	return func(a *App) {
+		a.hasher = hasher
+	}
+}
+
+// WithPasswordPolicy sets the rules new passwords must follow.
+func WithPasswordPolicy(policy password.Policy) Option {
+	return func(a *App) {
+		a.policy = policy
+	}
+}
+
+func WithLockout(limiter *lockout.Limiter) Option {
+	return func(a *App) {
+		a.lockout = limiter
+	}
+}
+
+func WithTokenIssuer(issuer *token.Issuer) Option {
+	return func(a *App) {
+		a.tokens = issuer
+	}
+}
+
+func WithJokeRepository(jokeRepo repo.JokeRepository) Option {
+	return func(a *App) {
+		a.jokeRepo = jokeRepo
+	}
+}
+
+func WithVoteRepository(voteRepo repo.VoteRepository) Option {
+	return func(a *App) {
+		a.voteRepo = voteRepo
+	}
+}
+
+func WithDailyJoke(daily *joke.Daily) Option {
+	return func(a *App) {
+		a.daily = daily
+	}
+}
+
+func WithSubmissionRepository(submissionRepo repo.SubmissionRepository) Option {
+	return func(a *App) {
+		a.submissionRepo = submissionRepo
+	}
+}
+
+func WithGroupRepository(groupRepo repo.GroupRepository) Option {
+	return func(a *App) {
+		a.groupRepo = groupRepo
+	}
+}
+
+func WithAPIKeyRepository(apiKeyRepo repo.APIKeyRepository) Option {
+	return func(a *App) {
+		a.apiKeyRepo = apiKeyRepo
+	}
+}
+
+func WithSessionRepository(sessionRepo repo.SessionRepository) Option {
+	return func(a *App) {
+		a.sessionRepo = sessionRepo
+	}
+}
+
+func WithMailer(sender mail.Sender) Option {
+	return func(a *App) {
+		a.mailer = sender
+	}
+}
+
+func WithOneTimeTokenRepository(tokenRepo repo.OneTimeTokenRepository) Option {
+	return func(a *App) {
+		a.tokenRepo = tokenRepo
+	}
+}
+
+// PasswordReset configures the password reset links sent on request.
+type PasswordReset struct {
+	// URL is the page reset links point to, the token is appended as the token query parameter.
+	URL string
+	TTL time.Duration
+	// Limiter limits how often resets can be requested per email and IP address.
+	Limiter *lockout.Limiter
+}
+
+func WithPasswordReset(reset PasswordReset) Option {
+	return func(a *App) {
+		a.reset = reset
+	}
+}
+
+// Registration configures how accounts are created.
+type Registration struct {
+	// InviteOnly closes POST /user, accounts are only created by accepting invitations.
+	InviteOnly  bool
+	Invitations repo.InvitationRepository
+	// URL is the page invitation links point to, the token is appended as the token query parameter.
+	URL string
+	TTL time.Duration
+}
+
+func WithRegistration(registration Registration) Option {
+	return func(a *App) {
+		a.registration = registration
+	}
+}
+
+func WithTwoFactor(twoFactor TwoFactor) Option {
+	return func(a *App) {
+		a.twoFactor = twoFactor
+	}
+}
+
+// Tenancy configures the tenants, the organizations users belong to.
+type Tenancy struct {
+	// Users hands out the user repositories of the tenants, only the default
+	// tenant is served without it.
+	Users repo.UserTenants
+	// Tenants are the IDs of the tenants besides repo.DefaultTenant.
+	Tenants []string
+	// Header names the request header choosing the tenant of requests
+	// without an access token.
+	Header string
+}
+
+func WithTenancy(tenancy Tenancy) Option {
+	return func(a *App) {
+		a.tenancy = tenancy
+	}
+}
+
+func WithWebAuthn(webAuthn WebAuthn) Option {
+	return func(a *App) {
+		a.webAuthn = webAuthn
+	}
+}
+
+func WithOIDC(provider OIDC) Option {
+	return func(a *App) {
+		a.oidc = provider
+	}
+}
+
+func WithFederation(federation Federation) Option {
+	return func(a *App) {
+		a.federation = federation
+	}
+}
+
+func WithLoginHistory(history LoginHistory) Option {
+	return func(a *App) {
+		a.loginHistory = history
+	}
+}
+
+func WithImpersonation(impersonation Impersonation) Option {
+	return func(a *App) {
+		a.impersonation = impersonation
+	}
+}
+
+func WithEmailVerification(verification Verification) Option {
+	return func(a *App) {
+		a.verification = verification
+	}
+}
+
+// WithJokeLimit sets how many jokes /jokes and /jokes/stream serve, it should
+// not exceed the limit of the joke client.
+func WithJokeLimit(limit int) Option {
+	return func(a *App) {
+		if limit > 0 {
+			a.jokeLimit = limit
+		}
+	}
+}
+
+// WithTopJokesWindow sets the default time window used to rank jokes by votes.
+func WithTopJokesWindow(window time.Duration) Option {
+	return func(a *App) {
+		a.topJokes = window
+	}
 }
 
 type CustomValidator struct {
@@ -29,9 +276,47 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
-func NewApp(e *echo.Echo, userRepo repo.UserRepository, log *zap.Logger, jokeClient joke.JokeClient) *App {
+func NewApp(e *echo.Echo, userRepo repo.UserRepository, log *zap.Logger, jokeClient joke.JokeClient, opts ...Option) *App {
 	e.Validator = &CustomValidator{validator: validator.New()}
-	app := &App{e: e, log: log, userRepo: userRepo, joke: jokeClient}
+	app := &App{
+		e:         e,
+		log:       log,
+		userRepo:  userRepo,
+		joke:      jokeClient,
+		hasher:    password.NewDefaultHasher(),
+		policy:    password.DefaultPolicy,
+		topJokes:  7 * 24 * time.Hour,
+		jokeLimit: JokeLimit,
+		roles:     rbac.DefaultRoles,
+		verification: Verification{
+			URL: "http://localhost:8080/verify-email",
+			TTL: 24 * time.Hour,
+		},
+		twoFactor: TwoFactor{Issuer: "go-user"},
+		tenancy:   Tenancy{Header: "X-Tenant-ID"},
+		reset: PasswordReset{
+			URL: "http://localhost:8080/reset-password",
+			TTL: 30 * time.Minute,
+		},
+		registration: Registration{
+			URL: "http://localhost:8080/accept-invitation",
+			TTL: 7 * 24 * time.Hour,
+		},
+		oidc: OIDC{
+			ConsentURL: "http://localhost:8080/consent",
+			CodeTTL:    time.Minute,
+			TokenTTL:   time.Hour,
+		},
+		federation: Federation{TTL: 10 * time.Minute},
+		loginHistory: LoginHistory{
+			MaxSpeed:  1000,
+			Retention: 90 * 24 * time.Hour,
+		},
+		impersonation: Impersonation{TTL: 15 * time.Minute},
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
 	app.RegisterRoutes()
 	return app
 
@@ -44,5 +329,17 @@ func (a *App) Start(port string) error {
 
 func (a *App) Stop() error {
 	a.log.Info("Shutting down the server...")
-	return a.e.Close()
+	err := a.e.Close()
+	a.background.Wait()
+	return err
+}
+
+// inBackground runs work after the response was sent, for work whose
+// duration must not show in the response time.
+func (a *App) inBackground(work func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		work()
+	}()
 }
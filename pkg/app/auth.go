@@ -0,0 +1,155 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	claimsKey = "claims"
+	userKey   = "user"
+	tenantKey = "tenant"
+	apiKeyKey = "apiKey"
+)
+
+// Authenticated rejects requests without a valid bearer access token or API
+// key and makes the token claims and the user available to the handler. The
+// request belongs to the tenant of the token, a tenant header naming another
+// one is rejected. Requests made with an impersonation token are audited.
+func (a *App) Authenticated(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		bearer, found := strings.CutPrefix(header, "Bearer ")
+		if !found || bearer == "" {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Missing access token", Error: "Missing access token"})
+		}
+		var claims *token.Claims
+		var apiKey *repo.APIKey
+		if lookup, isKey := token.ParseAPIKey(bearer); isKey {
+			key, err := a.verifyAPIKey(lookup, bearer, time.Now())
+			if errors.Is(err, errInvalidAPIKey) {
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid API key", Error: err.Error()})
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find API key", Error: err.Error()})
+			}
+			apiKey = &key
+			claims = &token.Claims{Tenant: key.TenantID, RegisteredClaims: jwt.RegisteredClaims{Subject: key.UserID}}
+		} else {
+			var err error
+			claims, err = a.tokens.Verify(bearer)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: err.Error()})
+			}
+		}
+		tenantID := claims.Tenant
+		if tenantID == "" {
+			tenantID = repo.DefaultTenant
+		}
+		if header := c.Request().Header.Get(a.tenancy.Header); (header != "" && header != tenantID) || !a.knownTenant(tenantID) {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: "token was issued for another tenant"})
+		}
+		c.Set(tenantKey, tenantID)
+		user, err := a.users(c).FindOne(claims.Subject)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: "user not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+		}
+		// Token times only have second precision, API keys outlive password changes
+		if apiKey == nil && user.PasswordChangedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second))) {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: "password changed after the token was issued"})
+		}
+		if err := a.checkSession(claims, time.Now()); errors.Is(err, errRevokedSession) {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: err.Error()})
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find session", Error: err.Error()})
+		}
+		if apiKey != nil {
+			claims.Email = user.Email
+			c.Set(apiKeyKey, *apiKey)
+			// Like sessions, so that scripts polling the API do not write on every request
+			if now := time.Now(); apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastSeenPrecision {
+				if err := a.apiKeyRepo.Touch(apiKey.ID, now); err != nil {
+					a.log.Warn("Failed to record API key use", zap.String("apiKeyId", apiKey.ID), zap.Error(err))
+				}
+			}
+		}
+		c.Set(claimsKey, claims)
+		c.Set(userKey, user)
+		if claims.Actor != nil {
+			if err := a.checkActor(c, claims.Actor); errors.Is(err, errRevokedImpersonation) {
+				return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid access token", Error: err.Error()})
+			} else if err != nil {
+				return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find impersonating user", Error: err.Error()})
+			}
+			return a.audited(c, next)
+		}
+		return next(c)
+	}
+}
+
+// Interactive rejects requests authenticated with an API key, so that a
+// leaked key can not be used to take over the account. It must run after
+// Authenticated.
+func (a *App) Interactive(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, isKey := apiKeyFrom(c); isKey {
+			return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Forbidden", Error: "API keys can not manage credentials"})
+		}
+		return next(c)
+	}
+}
+
+// Require only lets users through whose roles, or the roles of their
+// groups, grant all of the permissions. Requests with an API key also need
+// the permissions among its scopes. It must run after Authenticated.
+func (a *App) Require(permissions ...rbac.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := userFrom(c)
+			groups, err := a.groupsOf(c, user.ID)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+			}
+			roles := inheritedRoles(user, groups)
+			for _, permission := range permissions {
+				if !a.roles.Allows(roles, permission) {
+					return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Forbidden", Error: "missing permission " + string(permission)})
+				}
+				if apiKey, isKey := apiKeyFrom(c); isKey && !slices.Contains(apiKey.Scopes, string(permission)) {
+					return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Forbidden", Error: "API key is missing scope " + string(permission)})
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func claimsFrom(c echo.Context) *token.Claims {
+	claims, _ := c.Get(claimsKey).(*token.Claims)
+	return claims
+}
+
+// apiKeyFrom returns the API key the request was authenticated with, if any.
+func apiKeyFrom(c echo.Context) (repo.APIKey, bool) {
+	apiKey, ok := c.Get(apiKeyKey).(repo.APIKey)
+	return apiKey, ok
+}
+
+func userFrom(c echo.Context) repo.User {
+	user, _ := c.Get(userKey).(repo.User)
+	return user
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+var testIssuer = token.NewIssuer("secret", time.Hour)
+
+func authorize(t *testing.T, req *http.Request, user repo.User) {
+	accessToken, err := testIssuer.Issue(user)
+	require.NoError(t, err)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+}
+
+// knownUsers accepts the access tokens of any user.
+func knownUsers(ctrl *gomock.Controller) *repo.MockUserRepository {
+	users := repo.NewMockUserRepository(ctrl)
+	users.EXPECT().FindOne(gomock.Any()).Return(repo.User{}, nil).AnyTimes()
+	return users
+}
+
+// usersWithRoles accepts the access tokens of any user and gives them roles.
+func usersWithRoles(ctrl *gomock.Controller, roles ...string) *repo.MockUserRepository {
+	users := repo.NewMockUserRepository(ctrl)
+	users.EXPECT().FindOne(gomock.Any()).DoAndReturn(func(id string) (repo.User, error) {
+		return repo.User{ID: id, Roles: roles}, nil
+	}).AnyTimes()
+	return users
+}
+
+func TestRequire(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	e := echo.New()
+	users := repo.NewMockUserRepository(ctrl)
+	users.EXPECT().FindOne("1").Return(repo.User{ID: "1", Roles: []string{rbac.RoleAdmin}}, nil)
+	users.EXPECT().FindOne("2").Return(repo.User{ID: "2", Roles: []string{rbac.RoleModerator}}, nil)
+	users.EXPECT().FindOne("3").Return(repo.User{ID: "3"}, nil)
+	app := NewApp(e, users, nil, nil, WithTokenIssuer(testIssuer))
+	handler := app.Authenticated(app.Require(rbac.UsersRead, rbac.UsersWrite)(func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	authorize(t, req, repo.User{ID: "1"})
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// Roles granting other permissions, or none, are not enough
+	for _, id := range []string{"2", "3"} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		authorize(t, req, repo.User{ID: id})
+		rec = httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer garbage")
+	rec = httptest.NewRecorder()
+	require.NoError(t, handler(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthenticatedPasswordChanged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	e := echo.New()
+	users := repo.NewMockUserRepository(ctrl)
+	changedAt := time.Now().Add(time.Minute)
+	users.EXPECT().FindOne("1").Return(repo.User{ID: "1", PasswordChangedAt: &changedAt}, nil)
+	users.EXPECT().FindOne("2").Return(repo.User{}, mongo.ErrNoDocuments)
+	app := NewApp(e, users, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	handler := app.Authenticated(func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// Issued before the password was reset
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	authorize(t, req, repo.User{ID: "1"})
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The user was deleted
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	authorize(t, req, repo.User{ID: "2"})
+	rec = httptest.NewRecorder()
+	require.NoError(t, handler(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	errInvalidFederatedLogin = errors.New("invalid federated login")
+	errProviderUnavailable   = errors.New("identity provider unavailable")
+	errNoLinkedAccount       = errors.New("no account linked")
+)
+
+// Federation configures logging in with an external OpenID Connect provider,
+// such as the identity provider of a company.
+type Federation struct {
+	// RP logs users in with the provider, federated login is disabled
+	// without it.
+	RP         *oidc.RelyingParty
+	Identities repo.ExternalIdentityRepository
+	Logins     repo.FederatedLoginRepository
+	// CreateUsers creates an account for users of the provider who log in
+	// for the first time and have no account with their email yet.
+	CreateUsers bool
+	// TTL is how long users have to log in at the provider.
+	TTL time.Duration
+}
+
+type FederatedLoginResponse struct {
+	// AuthorizationURL is the page of the provider to send the user to.
+	AuthorizationURL string `json:"authorizationUrl"`
+}
+
+// FederatedCallbackRequest carries what the provider sent the user back to
+// the redirect URL with.
+type FederatedCallbackRequest struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// BeginFederatedLogin starts a login with the external provider.
+func (a *App) BeginFederatedLogin(c echo.Context) error {
+	return a.beginFederation(c, "")
+}
+
+// FinishFederatedLogin logs in the user the provider vouches for. The account
+// at the provider is linked to a user explicitly, or on first login to the
+// user with the same email if the provider verified it. Otherwise an account
+// is created if allowed. A federated login replaces both the password and the
+// second factor.
+func (a *App) FinishFederatedLogin(c echo.Context) error {
+	if a.federation.RP == nil {
+		return federationNotConfigured(c)
+	}
+	callback := new(FederatedCallbackRequest)
+	if err := c.Bind(callback); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(callback); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	identity, err := a.exchangeFederation(c, callback, "")
+	if err != nil {
+		a.recordLogin(c, repo.User{}, repo.LoginEvent{Method: repo.LoginOIDC, Outcome: repo.LoginFailed})
+		return federationFailed(c, err)
+	}
+	link, err := a.federation.Identities.FindBySubject(tenantFrom(c), identity.Issuer, identity.Subject)
+	var user repo.User
+	if err == nil {
+		user, err = a.users(c).FindOne(link.UserID)
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		user, err = a.federatedUser(c, identity)
+	}
+	if errors.Is(err, errNoLinkedAccount) {
+		a.recordLogin(c, repo.User{}, repo.LoginEvent{Email: identity.Email, Method: repo.LoginOIDC, Outcome: repo.LoginFailed})
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "No account linked", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	if a.verification.Required && !user.EmailVerified {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Email not verified", Error: "verify your email before logging in"})
+	}
+	a.log.Info("Federated login", zap.String("userId", user.ID), zap.String("issuer", identity.Issuer))
+	a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginOIDC, Outcome: repo.LoginSucceeded})
+	return a.issueToken(c, user)
+}
+
+// BeginIdentityLink starts linking the account at the external provider the
+// user logs in with to the logged in user.
+func (a *App) BeginIdentityLink(c echo.Context) error {
+	return a.beginFederation(c, userFrom(c).ID)
+}
+
+// FinishIdentityLink links the account at the provider to the logged in user.
+func (a *App) FinishIdentityLink(c echo.Context) error {
+	if a.federation.RP == nil {
+		return federationNotConfigured(c)
+	}
+	callback := new(FederatedCallbackRequest)
+	if err := c.Bind(callback); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(callback); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	user := userFrom(c)
+	identity, err := a.exchangeFederation(c, callback, user.ID)
+	if err != nil {
+		return federationFailed(c, err)
+	}
+	linked, err := a.linkIdentity(c, user, identity)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Identity already linked", Error: "the account at the provider is linked to a user already"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to link identity", Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, linked)
+}
+
+func (a *App) GetIdentities(c echo.Context) error {
+	if a.federation.RP == nil {
+		return federationNotConfigured(c)
+	}
+	identities, err := a.federation.Identities.FindByUser(userFrom(c).ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list identities", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, identities)
+}
+
+// DeleteIdentity unlinks an identity, it can be linked again by email on the
+// next federated login if the provider verified the email.
+func (a *App) DeleteIdentity(c echo.Context) error {
+	if a.federation.RP == nil {
+		return federationNotConfigured(c)
+	}
+	err := a.federation.Identities.Delete(c.Param("id"), userFrom(c).ID)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Identity not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to unlink identity", Error: err.Error()})
+	}
+	a.log.Info("Unlinked identity", zap.String("identityId", c.Param("id")), zap.String("userId", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// beginFederation stores the nonce and code verifier of a login at the
+// provider under the hash of its state, userID is set for links.
+func (a *App) beginFederation(c echo.Context, userID string) error {
+	if a.federation.RP == nil {
+		return federationNotConfigured(c)
+	}
+	var secrets [3]string
+	for i := range secrets {
+		var err error
+		if secrets[i], err = token.NewOpaque(); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to begin login", Error: err.Error()})
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	authURL, err := a.federation.RP.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, ErrorResponse{Message: "Failed to reach identity provider", Error: err.Error()})
+	}
+	err = a.federation.Logins.Create(repo.FederatedLogin{
+		ID:        token.HashOpaque(state),
+		TenantID:  tenantFrom(c),
+		UserID:    userID,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(a.federation.TTL),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save login", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, FederatedLoginResponse{AuthorizationURL: authURL})
+}
+
+// exchangeFederation redeems the code the user came back with. The login must
+// have been begun in the tenant of the request, by the user for links.
+func (a *App) exchangeFederation(c echo.Context, callback *FederatedCallbackRequest, userID string) (oidc.Identity, error) {
+	login, err := a.federation.Logins.Consume(token.HashOpaque(callback.State), time.Now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return oidc.Identity{}, fmt.Errorf("%w: unknown or expired state", errInvalidFederatedLogin)
+	}
+	if err != nil {
+		return oidc.Identity{}, err
+	}
+	if login.TenantID != tenantFrom(c) || login.UserID != userID {
+		return oidc.Identity{}, fmt.Errorf("%w: the login was begun by someone else", errInvalidFederatedLogin)
+	}
+	identity, err := a.federation.RP.Exchange(callback.Code, login.Verifier, login.Nonce)
+	if err != nil && !errors.Is(err, oidc.ErrLoginFailed) {
+		return oidc.Identity{}, fmt.Errorf("%w: %w", errProviderUnavailable, err)
+	}
+	return identity, err
+}
+
+// federatedUser finds the user with the email the provider verified, or
+// creates one, and links the identity to it. It returns errNoLinkedAccount
+// when neither is possible, also when the email is registered but was not
+// verified by the provider, so that the answer does not tell which emails
+// have an account.
+func (a *App) federatedUser(c echo.Context, identity oidc.Identity) (repo.User, error) {
+	user, err := repo.User{}, mongo.ErrNoDocuments
+	if identity.Email != "" && identity.EmailVerified {
+		user, err = a.users(c).FindByEmail(identity.Email)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !a.federation.CreateUsers || a.registration.InviteOnly {
+			return repo.User{}, fmt.Errorf("%w: log in and link the account at the provider first", errNoLinkedAccount)
+		}
+		if identity.Email == "" {
+			return repo.User{}, fmt.Errorf("%w: the provider did not share an email", errNoLinkedAccount)
+		}
+		// The user can choose a password with the password reset
+		var unusable string
+		if unusable, err = token.NewOpaque(); err != nil {
+			return repo.User{}, err
+		}
+		user, err = a.createAccount(c, repo.User{
+			Email:         identity.Email,
+			FirstName:     identity.GivenName,
+			LastName:      identity.FamilyName,
+			Password:      &unusable,
+			EmailVerified: identity.EmailVerified,
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return repo.User{}, fmt.Errorf("%w: log in and link the account at the provider first", errNoLinkedAccount)
+		}
+	}
+	if err != nil {
+		return repo.User{}, err
+	}
+	if _, err := a.linkIdentity(c, user, identity); err != nil {
+		return repo.User{}, err
+	}
+	return user, nil
+}
+
+func (a *App) linkIdentity(c echo.Context, user repo.User, identity oidc.Identity) (repo.ExternalIdentity, error) {
+	linked, err := a.federation.Identities.Create(repo.ExternalIdentity{
+		TenantID: tenantFrom(c),
+		UserID:   user.ID,
+		Issuer:   identity.Issuer,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	})
+	if err != nil {
+		return repo.ExternalIdentity{}, err
+	}
+	a.log.Info("Linked identity", zap.String("identityId", linked.ID), zap.String("userId", user.ID), zap.String("issuer", identity.Issuer))
+	return linked, nil
+}
+
+func federationFailed(c echo.Context, err error) error {
+	if errors.Is(err, errInvalidFederatedLogin) || errors.Is(err, oidc.ErrLoginFailed) {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid credentials", Error: err.Error()})
+	}
+	if errors.Is(err, errProviderUnavailable) {
+		return c.JSON(http.StatusBadGateway, ErrorResponse{Message: "Failed to reach identity provider", Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find login", Error: err.Error()})
+}
+
+func federationNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Federated login is not configured", Error: "missing identity provider"})
+}
@@ -0,0 +1,326 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/oidc/oidctest"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+// externalUser is the account of the user at the external provider.
+var externalUser = repo.User{ID: "ext-1", Email: "fo@bo.com", EmailVerified: true, FirstName: "Fo", LastName: "Bo"}
+
+// federatedApp serves an app logging users in with a mock provider. Logins
+// are finished with the one begun last.
+func federatedApp(t *testing.T, ctrl *gomock.Controller, users repo.UserRepository, identities repo.ExternalIdentityRepository, opts ...Option) (*echo.Echo, *oidctest.Issuer) {
+	issuer := oidctest.NewIssuer(t, "go-user", "s3cret")
+	rp := oidc.NewRelyingParty(oidc.RelyingPartyConfig{
+		Issuer:       issuer.URL,
+		ClientID:     "go-user",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/login/callback",
+		Scopes:       oidc.Scopes,
+	}, issuer.Client())
+	var begun repo.FederatedLogin
+	logins := repo.NewMockFederatedLoginRepository(ctrl)
+	logins.EXPECT().Create(gomock.Any()).DoAndReturn(func(login repo.FederatedLogin) error {
+		begun = login
+		return nil
+	}).AnyTimes()
+	logins.EXPECT().Consume(gomock.Any(), gomock.Any()).DoAndReturn(func(string, time.Time) (repo.FederatedLogin, error) {
+		return begun, nil
+	}).AnyTimes()
+	e := echo.New()
+	opts = append([]Option{WithTokenIssuer(testIssuer), WithFederation(Federation{
+		RP:          rp,
+		Identities:  identities,
+		Logins:      logins,
+		CreateUsers: true,
+		TTL:         time.Minute,
+	})}, opts...)
+	NewApp(e, users, zap.NewNop(), nil, opts...)
+	return e, issuer
+}
+
+// federate logs the external user in at the provider, starting at the begin
+// path and returning the response of the finish path. A non empty user ID
+// makes the requests as that user.
+func federate(t *testing.T, e *echo.Echo, issuer *oidctest.Issuer, path string, as repo.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path+"/begin", nil)
+	if as.ID != "" {
+		authorize(t, req, as)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var begun FederatedLoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &begun))
+	require.True(t, strings.HasPrefix(begun.AuthorizationURL, issuer.URL+"/authorize?"))
+
+	callback, err := issuer.Login(begun.AuthorizationURL, externalUser)
+	require.NoError(t, err)
+	return finishFederation(t, e, path, as, callback.Get("state"), callback.Get("code"))
+}
+
+func finishFederation(t *testing.T, e *echo.Echo, path string, as repo.User, state, code string) *httptest.ResponseRecorder {
+	body, err := json.Marshal(FederatedCallbackRequest{State: state, Code: code})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, path+"/finish", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if as.ID != "" {
+		authorize(t, req, as)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func requireLoggedIn(t *testing.T, rec *httptest.ResponseRecorder, userID string) {
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	claims, err := testIssuer.Verify(login.Token)
+	require.NoError(t, err)
+	require.Equal(t, userID, claims.Subject)
+}
+
+func TestFederatedLoginLinked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1", Email: "other@bo.com"}, nil)
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	e, issuer := federatedApp(t, ctrl, db, identities)
+	identities.EXPECT().FindBySubject(repo.DefaultTenant, issuer.URL, "ext-1").Return(repo.ExternalIdentity{ID: "identity-1", UserID: "user-1"}, nil)
+
+	// Assertions
+	requireLoggedIn(t, federate(t, e, issuer, "/login/oidc", repo.User{}), "user-1")
+
+}
+
+func TestFederatedLoginVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	e, issuer := federatedApp(t, ctrl, db, identities)
+	identities.EXPECT().FindBySubject(repo.DefaultTenant, issuer.URL, "ext-1").Return(repo.ExternalIdentity{}, mongo.ErrNoDocuments)
+	identities.EXPECT().Create(gomock.Any()).DoAndReturn(func(identity repo.ExternalIdentity) (repo.ExternalIdentity, error) {
+		require.Equal(t, "user-1", identity.UserID)
+		require.Equal(t, issuer.URL, identity.Issuer)
+		require.Equal(t, "ext-1", identity.Subject)
+		identity.ID = "identity-1"
+		return identity, nil
+	})
+
+	// Assertions
+	requireLoggedIn(t, federate(t, e, issuer, "/login/oidc", repo.User{}), "user-1")
+
+}
+
+func TestFederatedLoginCreatesUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+	db.EXPECT().Create(gomock.Any()).DoAndReturn(func(user repo.User) (repo.User, error) {
+		require.Equal(t, "fo@bo.com", user.Email)
+		require.Equal(t, "Fo", user.FirstName)
+		require.Equal(t, "Bo", user.LastName)
+		require.True(t, user.EmailVerified)
+		require.NotNil(t, user.Password)
+		user.ID = "user-2"
+		return user, nil
+	})
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	identities.EXPECT().FindBySubject(gomock.Any(), gomock.Any(), "ext-1").Return(repo.ExternalIdentity{}, mongo.ErrNoDocuments)
+	identities.EXPECT().Create(gomock.Any()).DoAndReturn(func(identity repo.ExternalIdentity) (repo.ExternalIdentity, error) {
+		require.Equal(t, "user-2", identity.UserID)
+		identity.ID = "identity-1"
+		return identity, nil
+	})
+	e, issuer := federatedApp(t, ctrl, db, identities)
+
+	// Assertions
+	requireLoggedIn(t, federate(t, e, issuer, "/login/oidc", repo.User{}), "user-2")
+
+}
+
+func TestFederatedLoginNoAccount(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"creation disabled": func(a *App) { a.federation.CreateUsers = false },
+		"invite only":       WithRegistration(Registration{InviteOnly: true}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// Setup
+			db := repo.NewMockUserRepository(ctrl)
+			db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+			identities := repo.NewMockExternalIdentityRepository(ctrl)
+			identities.EXPECT().FindBySubject(gomock.Any(), gomock.Any(), "ext-1").Return(repo.ExternalIdentity{}, mongo.ErrNoDocuments)
+			e, issuer := federatedApp(t, ctrl, db, identities, opt)
+
+			// Assertions
+			rec := federate(t, e, issuer, "/login/oidc", repo.User{})
+			require.Equal(t, http.StatusForbidden, rec.Code)
+
+		})
+	}
+}
+
+func TestFederatedLoginUnverifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().Create(gomock.Any()).Return(repo.User{}, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}})
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	identities.EXPECT().FindBySubject(gomock.Any(), gomock.Any(), "ext-1").Return(repo.ExternalIdentity{}, mongo.ErrNoDocuments)
+	e, issuer := federatedApp(t, ctrl, db, identities)
+
+	req := httptest.NewRequest(http.MethodPost, "/login/oidc/begin", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var begun FederatedLoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &begun))
+	unverified := externalUser
+	unverified.EmailVerified = false
+	callback, err := issuer.Login(begun.AuthorizationURL, unverified)
+	require.NoError(t, err)
+
+	// Assertions
+	// A registered email is answered like an unknown one
+	rec = finishFederation(t, e, "/login/oidc", repo.User{}, callback.Get("state"), callback.Get("code"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Equal(t, ErrorResponse{Message: "No account linked", Error: "no account linked: log in and link the account at the provider first"}, response)
+
+}
+
+func TestFederatedLoginReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	identities.EXPECT().FindBySubject(gomock.Any(), gomock.Any(), "ext-1").Return(repo.ExternalIdentity{ID: "identity-1", UserID: "user-1"}, nil)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1"}, nil)
+	var login repo.FederatedLogin
+	logins := repo.NewMockFederatedLoginRepository(ctrl)
+	logins.EXPECT().Create(gomock.Any()).DoAndReturn(func(begun repo.FederatedLogin) error {
+		login = begun
+		return nil
+	})
+	e, issuer := federatedApp(t, ctrl, db, identities, func(a *App) { a.federation.Logins = logins })
+
+	req := httptest.NewRequest(http.MethodPost, "/login/oidc/begin", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var begun FederatedLoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &begun))
+	callback, err := issuer.Login(begun.AuthorizationURL, externalUser)
+	require.NoError(t, err)
+	require.Equal(t, token.HashOpaque(callback.Get("state")), login.ID)
+	logins.EXPECT().Consume(token.HashOpaque("unknown"), gomock.Any()).Return(repo.FederatedLogin{}, mongo.ErrNoDocuments)
+	gomock.InOrder(
+		logins.EXPECT().Consume(login.ID, gomock.Any()).Return(login, nil),
+		logins.EXPECT().Consume(login.ID, gomock.Any()).Return(repo.FederatedLogin{}, mongo.ErrNoDocuments),
+	)
+
+	// Assertions
+	rec = finishFederation(t, e, "/login/oidc", repo.User{}, "unknown", callback.Get("code"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	requireLoggedIn(t, finishFederation(t, e, "/login/oidc", repo.User{}, callback.Get("state"), callback.Get("code")), "user-1")
+	rec = finishFederation(t, e, "/login/oidc", repo.User{}, callback.Get("state"), callback.Get("code"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestIdentityLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	user := repo.User{ID: "user-1", Email: "other@bo.com"}
+	identities := repo.NewMockExternalIdentityRepository(ctrl)
+	e, issuer := federatedApp(t, ctrl, usersWithRoles(ctrl), identities)
+	linked := repo.ExternalIdentity{ID: "identity-1", UserID: "user-1", Issuer: issuer.URL, Subject: "ext-1", Email: "fo@bo.com"}
+	gomock.InOrder(
+		identities.EXPECT().Create(gomock.Any()).DoAndReturn(func(identity repo.ExternalIdentity) (repo.ExternalIdentity, error) {
+			require.Equal(t, "user-1", identity.UserID)
+			require.Equal(t, "ext-1", identity.Subject)
+			identity.ID = "identity-1"
+			return identity, nil
+		}),
+		identities.EXPECT().Create(gomock.Any()).Return(repo.ExternalIdentity{}, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}),
+	)
+	identities.EXPECT().FindByUser("user-1").Return([]repo.ExternalIdentity{linked}, nil)
+	identities.EXPECT().Delete("identity-1", "user-1").Return(nil)
+	identities.EXPECT().Delete("identity-2", "user-1").Return(mongo.ErrNoDocuments)
+
+	// Assertions
+	rec := federate(t, e, issuer, "/me/identities", user)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = federate(t, e, issuer, "/me/identities", user)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/me/identities", nil)
+	authorize(t, req, user)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"subject":"ext-1"`)
+	require.NotContains(t, rec.Body.String(), "user-1")
+
+	for id, status := range map[string]int{"identity-1": http.StatusNoContent, "identity-2": http.StatusNotFound} {
+		req = httptest.NewRequest(http.MethodDelete, "/me/identities/"+id, nil)
+		authorize(t, req, user)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, status, rec.Code)
+	}
+
+}
+
+func TestIdentityLinkOtherUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e, issuer := federatedApp(t, ctrl, usersWithRoles(ctrl), repo.NewMockExternalIdentityRepository(ctrl))
+	req := httptest.NewRequest(http.MethodPost, "/me/identities/begin", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var begun FederatedLoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &begun))
+	callback, err := issuer.Login(begun.AuthorizationURL, externalUser)
+	require.NoError(t, err)
+
+	// Assertions
+	rec = finishFederation(t, e, "/me/identities", repo.User{ID: "user-2"}, callback.Get("state"), callback.Get("code"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestFederationNotConfigured(t *testing.T) {
+	// Setup
+	e := echo.New()
+	NewApp(e, nil, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+
+	// Assertions
+	for _, path := range []string{"/login/oidc/begin", "/login/oidc/finish"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		require.Equal(t, http.StatusNotImplemented, rec.Code)
+	}
+
+}
@@ -0,0 +1,331 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var GroupPageLimit = 100
+
+type GroupRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+type MemberRequest struct {
+	UserID string `json:"userId" validate:"required"`
+}
+
+func (a *App) CreateGroup(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	groupRequest := new(GroupRequest)
+	if err := c.Bind(groupRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(groupRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	group, err := a.groupRepo.Create(repo.Group{
+		TenantID:    tenantFrom(c),
+		Name:        groupRequest.Name,
+		Description: groupRequest.Description,
+		CreatedAt:   time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Group name already taken", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create group", Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, group)
+}
+
+// GetGroups lists the groups of the tenant by name with ?offset= and ?limit= paging.
+func (a *App) GetGroups(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	offset, limit, err := pagination(c, GroupPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	groups, err := a.groupRepo.Find(tenantFrom(c), offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list groups", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+func (a *App) GetGroup(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	group, err := a.groupRepo.FindOne(c.Param("id"), tenantFrom(c))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find group", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, group)
+}
+
+// UpdateGroup renames a group or changes its description.
+func (a *App) UpdateGroup(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	groupRequest := new(GroupRequest)
+	if err := c.Bind(groupRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(groupRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	group, err := a.groupRepo.Update(repo.Group{
+		ID:          c.Param("id"),
+		TenantID:    tenantFrom(c),
+		Name:        groupRequest.Name,
+		Description: groupRequest.Description,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Group name already taken", Error: err.Error()})
+	}
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update group", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup removes a group, its members lose the roles they inherited from
+// it. Administrators can not delete a group they only are an administrator
+// through.
+func (a *App) DeleteGroup(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	keeps, err := a.keepsRoleManagement(c, c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	if !keeps {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Can not revoke your own admin role", Error: "ask another administrator"})
+	}
+	err = a.groupRepo.Delete(c.Param("id"), tenantFrom(c))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete group", Error: err.Error()})
+	}
+	a.log.Info("Deleted group", zap.String("groupId", c.Param("id")), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetGroupMembers lists the IDs of the members in the order they were added
+// with ?offset= and ?limit= paging.
+func (a *App) GetGroupMembers(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	offset, limit, err := pagination(c, GroupPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	members, err := a.groupRepo.Members(c.Param("id"), tenantFrom(c), offset, limit)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list members", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, members)
+}
+
+// AddGroupMember adds a user to a group, who inherits its roles. It needs the
+// same permission as granting roles, otherwise anyone managing users could
+// join a group with more permissions than their own.
+func (a *App) AddGroupMember(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	memberRequest := new(MemberRequest)
+	if err := c.Bind(memberRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(memberRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	_, err := a.users(c).FindOne(memberRequest.UserID)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	err = a.groupRepo.AddMember(c.Param("id"), tenantFrom(c), memberRequest.UserID)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to add member", Error: err.Error()})
+	}
+	a.log.Info("Added group member", zap.String("groupId", c.Param("id")), zap.String("userId", memberRequest.UserID), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveGroupMember removes a user from a group. Administrators can not leave
+// a group they only are an administrator through.
+func (a *App) RemoveGroupMember(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	if c.Param("userId") == userFrom(c).ID {
+		keeps, err := a.keepsRoleManagement(c, c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+		}
+		if !keeps {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "Can not revoke your own admin role", Error: "ask another administrator"})
+		}
+	}
+	err := a.groupRepo.RemoveMember(c.Param("id"), tenantFrom(c), c.Param("userId"))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to remove member", Error: err.Error()})
+	}
+	a.log.Info("Removed group member", zap.String("groupId", c.Param("id")), zap.String("userId", c.Param("userId")), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GrantGroupRole adds a role all members of a group inherit.
+func (a *App) GrantGroupRole(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	roleRequest := new(RoleRequest)
+	if err := c.Bind(roleRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(roleRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if !a.roles.Known(roleRequest.Role) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Unknown role", Error: "unknown role " + roleRequest.Role})
+	}
+	err := a.groupRepo.GrantRole(c.Param("id"), tenantFrom(c), roleRequest.Role)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to grant role", Error: err.Error()})
+	}
+	a.log.Info("Granted group role", zap.String("groupId", c.Param("id")), zap.String("role", roleRequest.Role), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeGroupRole removes a role from a group. Like their own admin role,
+// administrators can not revoke the admin role of a group they only are an
+// administrator through.
+func (a *App) RevokeGroupRole(c echo.Context) error {
+	if a.groupRepo == nil {
+		return groupsNotConfigured(c)
+	}
+	id, role := c.Param("id"), c.Param("role")
+	if role == rbac.RoleAdmin {
+		keeps, err := a.keepsRoleManagement(c, id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+		}
+		if !keeps {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "Can not revoke your own admin role", Error: "ask another administrator"})
+		}
+	}
+	err := a.groupRepo.RevokeRole(id, tenantFrom(c), role)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Group not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke role", Error: err.Error()})
+	}
+	a.log.Info("Revoked group role", zap.String("groupId", id), zap.String("role", role), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// keepsRoleManagement reports whether the caller could still manage roles
+// without the group with the given ID.
+func (a *App) keepsRoleManagement(c echo.Context, groupID string) (bool, error) {
+	user := userFrom(c)
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return false, err
+	}
+	remaining := make([]repo.Group, 0, len(groups))
+	for _, group := range groups {
+		if group.ID != groupID {
+			remaining = append(remaining, group)
+		}
+	}
+	return a.roles.Allows(inheritedRoles(user, remaining), rbac.RolesManage), nil
+}
+
+// groupsOf returns the groups of the tenant the user is a member of, none
+// when groups are not configured.
+func (a *App) groupsOf(c echo.Context, userID string) ([]repo.Group, error) {
+	if a.groupRepo == nil {
+		return nil, nil
+	}
+	return a.groupRepo.FindByMember(tenantFrom(c), userID)
+}
+
+// inheritedRoles returns the roles of the user together with those of their groups.
+func inheritedRoles(user repo.User, groups []repo.Group) []string {
+	roles := append([]string{}, user.Roles...)
+	for _, group := range groups {
+		roles = append(roles, group.Roles...)
+	}
+	return roles
+}
+
+func groupNames(groups []repo.Group) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// issueToken answers a successful login with an access token carrying the
+// names of the groups of the user.
+func (a *App) issueToken(c echo.Context, user repo.User) error {
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	session, err := a.startSession(c, user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to start session", Error: err.Error()})
+	}
+	accessToken, err := a.tokens.IssueSession(user, session, groupNames(groups)...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to issue token", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, LoginResponse{Token: accessToken})
+}
+
+func groupsNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Groups are not configured", Error: "missing group repository"})
+}
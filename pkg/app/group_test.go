@@ -0,0 +1,187 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestGroupInheritedPermissions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	groups := repo.NewMockGroupRepository(ctrl)
+	groups.EXPECT().FindByMember(repo.DefaultTenant, "support-1").Return([]repo.Group{{ID: "group-1", Name: "support", Roles: []string{rbac.RoleSupport}}}, nil).AnyTimes()
+	groups.EXPECT().FindByMember(repo.DefaultTenant, "user-2").Return([]repo.Group{}, nil).AnyTimes()
+	groups.EXPECT().Find(repo.DefaultTenant, 0, GroupPageLimit).Return([]repo.Group{{ID: "group-1", Name: "support"}}, nil)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithGroupRepository(groups))
+	list := func(userID string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/groups", nil)
+		authorize(t, req, repo.User{ID: userID})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Neither user has a role of their own
+	require.Equal(t, http.StatusOK, list("support-1"))
+	require.Equal(t, http.StatusForbidden, list("user-2"))
+
+}
+
+func TestLoginGroupsClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	hash, err := password.NewDefaultHasher().Hash("1234567898")
+	require.NoError(t, err)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1", Email: "fo@bo.com", Password: &hash}, nil)
+	groups := repo.NewMockGroupRepository(ctrl)
+	groups.EXPECT().FindByMember(repo.DefaultTenant, "user-1").Return([]repo.Group{{ID: "group-1", Name: "dev"}, {ID: "group-2", Name: "ops"}}, nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)), WithGroupRepository(groups))
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	claims, err := testIssuer.Verify(login.Token)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev", "ops"}, claims.Groups)
+
+}
+
+func TestGroups(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("admin-1").Return(repo.User{ID: "admin-1", Roles: []string{rbac.RoleAdmin}}, nil).AnyTimes()
+	db.EXPECT().FindOne("user-2").Return(repo.User{ID: "user-2"}, nil)
+	db.EXPECT().FindOne("user-3").Return(repo.User{}, mongo.ErrNoDocuments)
+	groups := repo.NewMockGroupRepository(ctrl)
+	groups.EXPECT().FindByMember(gomock.Any(), gomock.Any()).Return([]repo.Group{}, nil).AnyTimes()
+	groups.EXPECT().Create(gomock.Any()).DoAndReturn(func(group repo.Group) (repo.Group, error) {
+		require.Equal(t, repo.DefaultTenant, group.TenantID)
+		group.ID = "group-1"
+		return group, nil
+	})
+	groups.EXPECT().Create(gomock.Any()).Return(repo.Group{}, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}})
+	groups.EXPECT().FindOne("group-2", repo.DefaultTenant).Return(repo.Group{}, mongo.ErrNoDocuments)
+	groups.EXPECT().Members("group-1", repo.DefaultTenant, 20, 10).Return([]string{"user-2"}, nil)
+	groups.EXPECT().AddMember("group-1", repo.DefaultTenant, "user-2").Return(nil)
+	groups.EXPECT().RemoveMember("group-1", repo.DefaultTenant, "user-2").Return(nil)
+	groups.EXPECT().GrantRole("group-1", repo.DefaultTenant, rbac.RoleModerator).Return(nil)
+	groups.EXPECT().Delete("group-1", repo.DefaultTenant).Return(nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithGroupRepository(groups))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/admin/groups", `{"name": "ops", "description": "Operations"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var group repo.Group
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &group))
+	require.Equal(t, repo.Group{ID: "group-1", Name: "ops", Description: "Operations"}, repo.Group{ID: group.ID, Name: group.Name, Description: group.Description})
+	require.Equal(t, http.StatusConflict, send(http.MethodPost, "/admin/groups", `{"name": "ops"}`).Code)
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/groups", `{}`).Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodGet, "/admin/groups/group-2", "").Code)
+
+	require.Equal(t, http.StatusNoContent, send(http.MethodPost, "/admin/groups/group-1/members", `{"userId": "user-2"}`).Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodPost, "/admin/groups/group-1/members", `{"userId": "user-3"}`).Code)
+	rec = send(http.MethodGet, "/admin/groups/group-1/members?offset=20&limit=10", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `["user-2"]`, rec.Body.String())
+	require.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/admin/groups/group-1/members?limit=1000", "").Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/groups/group-1/members/user-2", "").Code)
+
+	require.Equal(t, http.StatusNoContent, send(http.MethodPost, "/admin/groups/group-1/roles", `{"role": "moderator"}`).Code)
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/groups/group-1/roles", `{"role": "root"}`).Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/groups/group-1", "").Code)
+
+}
+
+func TestJoinAdminGroupAsSupport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	groups := repo.NewMockGroupRepository(ctrl)
+	groups.EXPECT().FindByMember(repo.DefaultTenant, "support-1").Return([]repo.Group{}, nil).AnyTimes()
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleSupport), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithGroupRepository(groups))
+	send := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "support-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Assertions
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/admin/groups/admins/members", `{"userId": "support-1"}`))
+	require.Equal(t, http.StatusForbidden, send(http.MethodDelete, "/admin/groups/admins/members/admin-1", ""))
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/admin/groups", `{"name": "ops"}`))
+	require.Equal(t, http.StatusForbidden, send(http.MethodPut, "/admin/groups/admins", `{"name": "ops"}`))
+	require.Equal(t, http.StatusForbidden, send(http.MethodDelete, "/admin/groups/admins", ""))
+
+}
+
+func TestRevokeOwnGroupAdminRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	groups := repo.NewMockGroupRepository(ctrl)
+	groups.EXPECT().FindByMember(repo.DefaultTenant, "admin-1").Return([]repo.Group{{ID: "group-1", Roles: []string{rbac.RoleAdmin}}}, nil).AnyTimes()
+	groups.EXPECT().RevokeRole("group-2", repo.DefaultTenant, rbac.RoleAdmin).Return(nil)
+	groups.EXPECT().RemoveMember("group-1", repo.DefaultTenant, "admin-2").Return(nil)
+	groups.EXPECT().Delete("group-2", repo.DefaultTenant).Return(nil)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithGroupRepository(groups))
+	send := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusConflict, send(http.MethodDelete, "/admin/groups/group-1/roles/admin"))
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/groups/group-2/roles/admin"))
+	// Neither leaving nor deleting the group gets around it
+	require.Equal(t, http.StatusConflict, send(http.MethodDelete, "/admin/groups/group-1/members/admin-1"))
+	require.Equal(t, http.StatusConflict, send(http.MethodDelete, "/admin/groups/group-1"))
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/groups/group-1/members/admin-2"))
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/groups/group-2"))
+
+}
+
+func TestGroupsNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/groups", nil)
+	authorize(t, req, repo.User{ID: "admin-1"})
+	rec := httptest.NewRecorder()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleAdmin), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var errRevokedImpersonation = errors.New("impersonation revoked")
+
+var AuditPageLimit = 100
+
+// Impersonation configures how support staff act as users. Every request
+// made while impersonating is written to the audit log, impersonation is
+// refused without one.
+type Impersonation struct {
+	Audit repo.AuditRepository
+	// TTL is how long impersonation tokens are valid, they belong to no
+	// session and can not be revoked before.
+	TTL time.Duration
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MeResponse names who impersonates the user, if anyone.
+type MeResponse struct {
+	UserResponse
+	ImpersonatedBy *token.Actor `json:"impersonatedBy,omitempty"`
+}
+
+// GetMe returns the user the request is made as, with their groups and
+// permissions.
+func (a *App) GetMe(c echo.Context) error {
+	response, err := a.userResponse(c, userFrom(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, MeResponse{UserResponse: response, ImpersonatedBy: claimsFrom(c).Actor})
+}
+
+// Impersonate issues a short-lived access token for acting as a user, so that
+// support staff see the app as the user does. The token names the admin in
+// its act claim and can not be used to manage credentials.
+func (a *App) Impersonate(c echo.Context) error {
+	if a.impersonation.Audit == nil {
+		return impersonationNotConfigured(c)
+	}
+	actor := userFrom(c)
+	if c.Param("id") == actor.ID {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Can not impersonate yourself", Error: "user is the caller"})
+	}
+	user, err := a.users(c).FindOne(c.Param("id"))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	accessToken, claims, err := a.tokens.Impersonate(user, actor, a.impersonation.TTL, groupNames(groups)...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to issue token", Error: err.Error()})
+	}
+	// The token is only handed out once it is on record
+	if err := a.audit(c, repo.AuditEntry{Action: repo.AuditImpersonate, ActorID: actor.ID, UserID: user.ID}); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to write audit log", Error: err.Error()})
+	}
+	a.log.Info("Impersonating user", zap.String("userId", user.ID), zap.String("actorId", actor.ID))
+	return c.JSON(http.StatusOK, ImpersonationResponse{Token: accessToken, ExpiresAt: claims.ExpiresAt.Time})
+}
+
+// GetAuditLog lists the audit log of the tenant, newest first. The userId
+// query parameter narrows it down to the entries of a user, as the actor or
+// the one acted as.
+func (a *App) GetAuditLog(c echo.Context) error {
+	if a.impersonation.Audit == nil {
+		return impersonationNotConfigured(c)
+	}
+	offset, limit, err := pagination(c, AuditPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	entries, err := a.impersonation.Audit.Find(tenantFrom(c), c.QueryParam("userId"), offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list audit log", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// NotImpersonated rejects requests made with an impersonation token, so that
+// support staff can not change the password, second factors or other
+// credentials of the user they act as. It must run after Authenticated.
+func (a *App) NotImpersonated(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if claimsFrom(c).Actor != nil {
+			return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Forbidden", Error: "impersonated sessions can not manage credentials"})
+		}
+		return next(c)
+	}
+}
+
+// checkActor reports errRevokedImpersonation unless the actor named in the
+// claims may still impersonate, so that revoking the role of an admin ends
+// their impersonations too.
+func (a *App) checkActor(c echo.Context, actor *token.Actor) error {
+	if a.impersonation.Audit == nil {
+		return fmt.Errorf("%w: impersonation is not configured", errRevokedImpersonation)
+	}
+	user, err := a.users(c).FindOne(actor.Subject)
+	if notFound(err) {
+		return fmt.Errorf("%w: the impersonating user no longer exists", errRevokedImpersonation)
+	}
+	if err != nil {
+		return err
+	}
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return err
+	}
+	if !a.roles.Allows(inheritedRoles(user, groups), rbac.UsersImpersonate) {
+		return fmt.Errorf("%w: the impersonating user may no longer impersonate", errRevokedImpersonation)
+	}
+	return nil
+}
+
+// audited handles an impersonated request and writes it to the audit log
+// with the status it was answered with. The response is sent by then, so a
+// failure to record is only logged.
+func (a *App) audited(c echo.Context, next echo.HandlerFunc) error {
+	if err := next(c); err != nil {
+		c.Error(err)
+	}
+	claims := claimsFrom(c)
+	entry := repo.AuditEntry{
+		Action:  repo.AuditImpersonatedRequest,
+		ActorID: claims.Actor.Subject,
+		UserID:  claims.Subject,
+		Method:  c.Request().Method,
+		Path:    c.Request().URL.Path,
+		Status:  c.Response().Status,
+	}
+	if err := a.audit(c, entry); err != nil {
+		a.log.Error("Failed to write audit log", zap.String("userId", claims.Subject), zap.String("actorId", claims.Actor.Subject), zap.Error(err))
+	}
+	return nil
+}
+
+func (a *App) audit(c echo.Context, entry repo.AuditEntry) error {
+	entry.TenantID = tenantFrom(c)
+	entry.At = time.Now()
+	entry.IP, entry.UserAgent = c.RealIP(), c.Request().UserAgent()
+	_, err := a.impersonation.Audit.Create(entry)
+	return err
+}
+
+func impersonationNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Impersonation is not configured", Error: "missing audit repository"})
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestImpersonate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	users := map[string]repo.User{
+		"admin-1":   {ID: "admin-1", Email: "admin@bo.com", Roles: []string{rbac.RoleAdmin}},
+		"support-1": {ID: "support-1", Email: "support@bo.com", Roles: []string{rbac.RoleSupport}},
+		"user-1":    {ID: "user-1", Email: "fo@bo.com"},
+	}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne(gomock.Any()).DoAndReturn(func(id string) (repo.User, error) {
+		user, ok := users[id]
+		if !ok {
+			return repo.User{}, mongo.ErrNoDocuments
+		}
+		return user, nil
+	}).AnyTimes()
+	var entries []repo.AuditEntry
+	store := repo.NewMockAuditRepository(ctrl)
+	store.EXPECT().Create(gomock.Any()).DoAndReturn(func(entry repo.AuditEntry) (repo.AuditEntry, error) {
+		entries = append(entries, entry)
+		return entry, nil
+	}).Times(6)
+	store.EXPECT().Find(repo.DefaultTenant, "user-1", 0, 2).Return([]repo.AuditEntry{
+		{Action: repo.AuditImpersonatedRequest, Path: "/admin/users/support-1/impersonate"},
+		{Action: repo.AuditImpersonatedRequest, Path: "/me/totp"},
+	}, nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithImpersonation(Impersonation{Audit: store, TTL: time.Minute}))
+	request := func(method, path, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	admin, err := testIssuer.Issue(users["admin-1"])
+	require.NoError(t, err)
+	support, err := testIssuer.Issue(users["support-1"])
+	require.NoError(t, err)
+
+	// Assertions
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/admin/users/user-1/impersonate", support).Code)
+	require.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/admin/users/admin-1/impersonate", admin).Code)
+	require.Equal(t, http.StatusNotFound, request(http.MethodPost, "/admin/users/user-2/impersonate", admin).Code)
+	require.Empty(t, entries)
+
+	rec := request(http.MethodPost, "/admin/users/user-1/impersonate", admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response ImpersonationResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.WithinDuration(t, time.Now().Add(time.Minute), response.ExpiresAt, time.Second)
+	impersonated := response.Token
+	require.Len(t, entries, 1)
+	require.Equal(t, repo.AuditImpersonate, entries[0].Action)
+	require.Equal(t, "admin-1", entries[0].ActorID)
+	require.Equal(t, "user-1", entries[0].UserID)
+
+	rec = request(http.MethodGet, "/me", impersonated)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var me MeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &me))
+	require.Equal(t, "user-1", me.ID)
+	require.Equal(t, &token.Actor{Subject: "admin-1", Email: "admin@bo.com"}, me.ImpersonatedBy)
+
+	// Credentials can not be managed while impersonating
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/me/password", impersonated).Code)
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/me/totp", impersonated).Code)
+	require.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/me/totp", impersonated).Code)
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/admin/users/support-1/impersonate", impersonated).Code)
+
+	require.Len(t, entries, 6)
+	for i, want := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/me", http.StatusOK},
+		{http.MethodPost, "/me/password", http.StatusForbidden},
+		{http.MethodPost, "/me/totp", http.StatusForbidden},
+		{http.MethodDelete, "/me/totp", http.StatusForbidden},
+		{http.MethodPost, "/admin/users/support-1/impersonate", http.StatusForbidden},
+	} {
+		entry := entries[i+1]
+		require.Equal(t, repo.AuditImpersonatedRequest, entry.Action)
+		require.Equal(t, "admin-1", entry.ActorID)
+		require.Equal(t, "user-1", entry.UserID)
+		require.Equal(t, repo.DefaultTenant, entry.TenantID)
+		require.Equal(t, want.method, entry.Method)
+		require.Equal(t, want.path, entry.Path)
+		require.Equal(t, want.status, entry.Status)
+	}
+
+	// Requests without impersonation are not audited
+	rec = request(http.MethodGet, "/me", admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "impersonatedBy")
+	require.Len(t, entries, 6)
+
+	rec = request(http.MethodGet, "/admin/audit?userId=user-1&limit=2", admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var audit []repo.AuditEntry
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &audit))
+	require.Len(t, audit, 2)
+	require.Equal(t, "/admin/users/support-1/impersonate", audit[0].Path)
+	require.Equal(t, http.StatusForbidden, request(http.MethodGet, "/admin/audit", support).Code)
+
+	// Revoking the role of the admin ends their impersonations
+	users["admin-1"] = repo.User{ID: "admin-1", Email: "admin@bo.com"}
+	require.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/me", impersonated).Code)
+
+}
+
+func TestImpersonationNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleAdmin), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/user-2/impersonate", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+
+	// Assertions
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	// Without an audit log impersonation tokens are not accepted
+	impersonated, _, err := testIssuer.Impersonate(repo.User{ID: "user-2"}, repo.User{ID: "user-1"}, time.Minute)
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+impersonated)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
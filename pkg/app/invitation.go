@@ -0,0 +1,170 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var InvitationPageLimit = 100
+
+type InviteRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	FirstName string `json:"firstName" validate:"required"`
+	LastName  string `json:"lastName" validate:"required"`
+	Password  string `json:"password" validate:"required"`
+}
+
+// CreateInvitation emails an invitation to sign up to the tenant of the
+// administrator and revokes the previous invitations of the email.
+func (a *App) CreateInvitation(c echo.Context) error {
+	if a.registration.Invitations == nil {
+		return invitationsNotConfigured(c)
+	}
+	inviteRequest := new(InviteRequest)
+	if err := c.Bind(inviteRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(inviteRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	_, err := a.users(c).FindByEmail(inviteRequest.Email)
+	if err == nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Email already registered", Error: "the user can log in already"})
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	tenantID := tenantFrom(c)
+	if err := a.registration.Invitations.DeleteByEmail(tenantID, inviteRequest.Email); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke invitations", Error: err.Error()})
+	}
+
+	signed, claims, err := a.tokens.IssueOneTime(inviteRequest.Email, token.PurposeInvite, a.registration.TTL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to issue invitation token", Error: err.Error()})
+	}
+	invitation := repo.Invitation{
+		ID:        claims.ID,
+		TenantID:  tenantID,
+		Email:     inviteRequest.Email,
+		InvitedBy: userFrom(c).ID,
+		CreatedAt: claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if err := a.registration.Invitations.Create(invitation); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save invitation", Error: err.Error()})
+	}
+	if err := a.sendInvitation(invitation, signed); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to send invitation email", Error: err.Error()})
+	}
+	a.log.Info("Invited user", zap.String("invitationId", invitation.ID), zap.String("by", invitation.InvitedBy))
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+// GetInvitations lists the pending invitations of the tenant with ?offset= and ?limit= paging.
+func (a *App) GetInvitations(c echo.Context) error {
+	if a.registration.Invitations == nil {
+		return invitationsNotConfigured(c)
+	}
+	offset, limit, err := pagination(c, InvitationPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	invitations, err := a.registration.Invitations.FindPending(tenantFrom(c), time.Now(), offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list invitations", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation deletes a pending invitation, its link can no longer be used.
+func (a *App) RevokeInvitation(c echo.Context) error {
+	if a.registration.Invitations == nil {
+		return invitationsNotConfigured(c)
+	}
+	err := a.registration.Invitations.Delete(c.Param("id"), tenantFrom(c))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Invitation not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke invitation", Error: err.Error()})
+	}
+	a.log.Info("Revoked invitation", zap.String("invitationId", c.Param("id")), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AcceptInvitation creates the account of an invited user. The email is the
+// invited one and counts as verified, as the invitation was sent to it.
+func (a *App) AcceptInvitation(c echo.Context) error {
+	if a.registration.Invitations == nil {
+		return invitationsNotConfigured(c)
+	}
+	acceptRequest := new(AcceptInvitationRequest)
+	if err := c.Bind(acceptRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(acceptRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	claims, err := a.tokens.VerifyOneTime(acceptRequest.Token, token.PurposeInvite)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid invitation", Error: err.Error()})
+	}
+	// The invitation is only consumed once the password follows the policy,
+	// so that a rejected password does not waste it.
+	if err := a.checkPassword(acceptRequest.Password, claims.Subject, acceptRequest.FirstName, acceptRequest.LastName); err != nil {
+		return passwordError(c, err)
+	}
+	invitation, err := a.registration.Invitations.Consume(claims.ID, tenantFrom(c), time.Now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid invitation", Error: "invitation was already used or revoked"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to consume invitation", Error: err.Error()})
+	}
+
+	user, err := a.createAccount(c, repo.User{
+		Email:         invitation.Email,
+		FirstName:     acceptRequest.FirstName,
+		LastName:      acceptRequest.LastName,
+		Password:      &acceptRequest.Password,
+		EmailVerified: true,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Email already registered", Error: "log in instead"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create user", Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, user)
+}
+
+func (a *App) sendInvitation(invitation repo.Invitation, signed string) error {
+	link, err := tokenLink(a.registration.URL, signed, invitation.TenantID)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(mail.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hello,\n\nyou have been invited to create an account. Open the link below within %s to sign up:\n\n%s\n",
+			a.registration.TTL, link),
+	})
+}
+
+func invitationsNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Invitations are not configured", Error: "missing invitation repository"})
+}
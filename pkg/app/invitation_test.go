@@ -0,0 +1,165 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestCreateUserInviteOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"email": "fo@bo.com", "firstName": "Fo", "lastName": "Bo", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithRegistration(Registration{InviteOnly: true}))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+}
+
+func TestInvitation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := usersWithRoles(ctrl, rbac.RoleSupport)
+	db.EXPECT().FindByEmail("new@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+	var created repo.User
+	db.EXPECT().Create(gomock.Any()).DoAndReturn(func(user repo.User) (repo.User, error) {
+		created = user
+		user.ID = "user-2"
+		return user, nil
+	})
+	invitations := repo.NewMockInvitationRepository(ctrl)
+	invitations.EXPECT().DeleteByEmail(repo.DefaultTenant, "new@bo.com").Return(nil)
+	var invitation repo.Invitation
+	invitations.EXPECT().Create(gomock.Any()).DoAndReturn(func(i repo.Invitation) error {
+		invitation = i
+		return nil
+	})
+	invitations.EXPECT().Consume(gomock.Any(), repo.DefaultTenant, gomock.Any()).DoAndReturn(func(id, tenantID string, at time.Time) (repo.Invitation, error) {
+		require.Equal(t, invitation.ID, id)
+		return invitation, nil
+	})
+	mailer := mail.NewMockSender(ctrl)
+	var sent mail.Message
+	mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg mail.Message) error {
+		sent = msg
+		return nil
+	})
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithMailer(mailer),
+		WithRegistration(Registration{InviteOnly: true, Invitations: invitations, URL: "https://bo.com/invite", TTL: time.Hour}))
+	req := httptest.NewRequest(http.MethodPost, "/admin/invitations", strings.NewReader(`{"email": "new@bo.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, "new@bo.com", invitation.Email)
+	require.Equal(t, "admin-1", invitation.InvitedBy)
+	require.Equal(t, repo.DefaultTenant, invitation.TenantID)
+
+	require.Equal(t, "new@bo.com", sent.To)
+	link := sent.Body[strings.Index(sent.Body, "https://"):]
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	require.NoError(t, err)
+	signed := parsed.Query().Get("token")
+
+	req = httptest.NewRequest(http.MethodPost, "/user/invitations/accept", strings.NewReader(`{"token": "`+signed+`", "firstName": "New", "lastName": "Bo", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	// The invitation proves the email, so no verification link is sent
+	require.Equal(t, "new@bo.com", created.Email)
+	require.True(t, created.EmailVerified)
+	var user repo.User
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	require.Equal(t, "user-2", user.ID)
+
+}
+
+func TestCreateInvitationRegistered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	db := usersWithRoles(ctrl, rbac.RoleSupport)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1"}, nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithRegistration(Registration{Invitations: repo.NewMockInvitationRepository(ctrl)}))
+	req := httptest.NewRequest(http.MethodPost, "/admin/invitations", strings.NewReader(`{"email": "fo@bo.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+}
+
+func TestAcceptInvitationUsed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	signed, _, err := testIssuer.IssueOneTime("new@bo.com", token.PurposeInvite, time.Hour)
+	require.NoError(t, err)
+	verification, _, err := testIssuer.IssueOneTime("new@bo.com", token.PurposeVerifyEmail, time.Hour)
+	require.NoError(t, err)
+	invitations := repo.NewMockInvitationRepository(ctrl)
+	invitations.EXPECT().Consume(gomock.Any(), repo.DefaultTenant, gomock.Any()).Return(repo.Invitation{}, mongo.ErrNoDocuments)
+	e := echo.New()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithRegistration(Registration{Invitations: invitations}))
+	accept := func(signed, password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/user/invitations/accept", strings.NewReader(`{"token": "`+signed+`", "firstName": "New", "lastName": "Bo", "password": "`+password+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Rejected passwords do not consume the invitation
+	require.Equal(t, http.StatusBadRequest, accept(signed, "short"))
+	require.Equal(t, http.StatusBadRequest, accept(verification, "1234567898"))
+	require.Equal(t, http.StatusBadRequest, accept(signed, "1234567898"))
+
+}
+
+func TestInvitationsList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	invitations := repo.NewMockInvitationRepository(ctrl)
+	invitations.EXPECT().FindPending(repo.DefaultTenant, gomock.Any(), 10, 5).Return([]repo.Invitation{{ID: "invitation-1", Email: "new@bo.com"}}, nil)
+	invitations.EXPECT().Delete("invitation-1", repo.DefaultTenant).Return(nil)
+	invitations.EXPECT().Delete("invitation-2", repo.DefaultTenant).Return(mongo.ErrNoDocuments)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleSupport), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithRegistration(Registration{Invitations: invitations}))
+	req := httptest.NewRequest(http.MethodGet, "/admin/invitations?offset=10&limit=5", nil)
+	authorize(t, req, repo.User{ID: "admin-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var pending []repo.Invitation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pending))
+	require.Equal(t, []repo.Invitation{{ID: "invitation-1", Email: "new@bo.com"}}, pending)
+
+	for id, code := range map[string]int{"invitation-1": http.StatusNoContent, "invitation-2": http.StatusNotFound} {
+		req = httptest.NewRequest(http.MethodDelete, "/admin/invitations/"+id, nil)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, code, rec.Code)
+	}
+
+}
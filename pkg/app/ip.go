@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor returns how the client IP address of requests is found, which
+// the lockout, sessions, login history and audit log rely on. Without trusted
+// proxies it is the address of the connection, as forwarding headers can be
+// set by anyone. Otherwise it is the rightmost address in X-Forwarded-For that
+// is not one of the proxies, given as CIDR ranges or single addresses.
+func IPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	var ranges []*net.IPNet
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		ranges = append(ranges, ipRange)
+	}
+	if len(ranges) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipRange := range ranges {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
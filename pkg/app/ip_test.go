@@ -0,0 +1,42 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPExtractor(t *testing.T) {
+	for name, test := range map[string]struct {
+		proxies    []string
+		remoteAddr string
+		forwarded  string
+		ip         string
+	}{
+		"no proxies":            {proxies: []string{""}, remoteAddr: "10.0.0.1:1234", forwarded: "1.2.3.4", ip: "10.0.0.1"},
+		"trusted proxy":         {proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:1234", forwarded: "1.2.3.4", ip: "1.2.3.4"},
+		"trusted address":       {proxies: []string{" 10.0.0.1"}, remoteAddr: "10.0.0.1:1234", forwarded: "1.2.3.4", ip: "1.2.3.4"},
+		"untrusted peer":        {proxies: []string{"10.0.0.0/8"}, remoteAddr: "5.6.7.8:1234", forwarded: "1.2.3.4", ip: "5.6.7.8"},
+		"spoofed by client":     {proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:1234", forwarded: "9.9.9.9, 1.2.3.4", ip: "1.2.3.4"},
+		"private net untrusted": {proxies: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:1234", forwarded: "192.168.0.1, 1.2.3.4", ip: "1.2.3.4"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			extract, err := IPExtractor(test.proxies)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, test.forwarded)
+			req.Header.Set(echo.HeaderXRealIP, "9.9.9.9")
+
+			// Assertions
+			require.Equal(t, test.ip, extract(req))
+
+		})
+	}
+
+	_, err := IPExtractor([]string{"not-an-ip"})
+	require.Error(t, err)
+}
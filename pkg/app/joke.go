@@ -1,20 +1,146 @@
 package app
 
 import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/Davut97/go-user/repo"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 )
 
 var (
-	JokeLimit = 10
+	JokeLimit    = 10
+	TopJokeLimit = 100
 )
 
-// get 10 jokes
+type VoteRequest struct {
+	Value int `json:"value" validate:"oneof=-1 1"`
+}
+
+// get the configured number of jokes, ?safe=true applies the stricter content filter
 func (a *App) GetJokes(c echo.Context) error {
+	client, err := a.jokeClient(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid safe parameter", Error: err.Error()})
+	}
 
-	jokes, err := a.joke.GetJokes(JokeLimit)
+	jokes, err := client.GetJokes(a.jokeLimit)
 	if err != nil {
 		return c.JSON(500, err)
 	}
 
+	a.saveServed(jokes)
+
 	return c.JSON(200, jokes)
 }
+
+// jokeClient returns the client for the request, ?safe=true selects the safe one.
+func (a *App) jokeClient(c echo.Context) (joke.JokeClient, error) {
+	s := c.QueryParam("safe")
+	if s == "" {
+		return a.joke, nil
+	}
+	safe, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, err
+	}
+	if safe {
+		return a.safeJoke, nil
+	}
+	return a.joke, nil
+}
+
+// saveServed remembers served jokes so that they can be voted on later.
+func (a *App) saveServed(jokes []joke.Joke) {
+	served := make([]repo.Joke, 0, len(jokes))
+	for _, j := range jokes {
+		served = append(served, repo.Joke{ID: j.ID, Categories: j.Categories, IconURL: j.IconURL, URL: j.URL, Value: j.Value})
+	}
+	if err := a.jokeRepo.Save(served); err != nil {
+		a.log.Warn("Failed to save served jokes", zap.Error(err))
+	}
+}
+
+func (a *App) VoteJoke(c echo.Context) error {
+	voteRequest := new(VoteRequest)
+	if err := c.Bind(voteRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(voteRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+
+	j, err := a.jokeRepo.FindOne(c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Joke not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find joke", Error: err.Error()})
+	}
+
+	vote := repo.Vote{
+		TenantID: tenantFrom(c),
+		UserID:   claimsFrom(c).Subject,
+		JokeID:   j.ID,
+		Value:    voteRequest.Value,
+		VotedAt:  time.Now(),
+	}
+	if err := a.voteRepo.Vote(vote); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save vote", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, vote)
+}
+
+// GetTopJokes ranks jokes by their vote score within the tenant. The optional window query
+// parameter is a duration such as "24h"; limit caps the number of results.
+func (a *App) GetTopJokes(c echo.Context) error {
+	window := a.topJokes
+	if w := c.QueryParam("window"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil || d <= 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid window", Error: "window must be a positive duration"})
+		}
+		window = d
+	}
+	limit := JokeLimit
+	if l := c.QueryParam("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > TopJokeLimit {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid limit", Error: "limit must be between 1 and " + strconv.Itoa(TopJokeLimit)})
+		}
+		limit = n
+	}
+
+	jokes, err := a.voteRepo.Top(tenantFrom(c), time.Now().Add(-window), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to rank jokes", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, jokes)
+}
+
+// GetDailyJoke returns the joke of the day, or of an earlier day given as ?date=YYYY-MM-DD.
+func (a *App) GetDailyJoke(c echo.Context) error {
+	var (
+		daily repo.DailyJoke
+		err   error
+	)
+	if date := c.QueryParam("date"); date != "" {
+		daily, err = a.daily.ForDate(date)
+	} else {
+		daily, err = a.daily.Today()
+	}
+	switch {
+	case errors.Is(err, joke.ErrInvalidDate), errors.Is(err, joke.ErrFutureDate):
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid date", Error: err.Error()})
+	case errors.Is(err, joke.ErrNoDailyJoke):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Joke of the day not found", Error: err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get joke of the day", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, daily)
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestVoteJoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	issuer := token.NewIssuer("secret", time.Hour)
+	accessToken, err := issuer.Issue(repo.User{ID: "user-1", Email: "fo@bo.com"})
+	require.NoError(t, err)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/joke-1/vote", strings.NewReader(`{"value": -1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	jokeRepo := repo.NewMockJokeRepository(ctrl)
+	jokeRepo.EXPECT().FindOne("joke-1").Return(repo.Joke{ID: "joke-1"}, nil)
+	voteRepo := repo.NewMockVoteRepository(ctrl)
+	voteRepo.EXPECT().Vote(gomock.Any()).DoAndReturn(func(vote repo.Vote) error {
+		require.Equal(t, repo.DefaultTenant, vote.TenantID)
+		require.Equal(t, "user-1", vote.UserID)
+		require.Equal(t, "joke-1", vote.JokeID)
+		require.Equal(t, -1, vote.Value)
+		return nil
+	})
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(issuer), WithJokeRepository(jokeRepo), WithVoteRepository(voteRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
+
+func TestVoteJokeUnauthenticated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/joke-1/vote", strings.NewReader(`{"value": 1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(token.NewIssuer("secret", time.Hour)),
+		WithJokeRepository(repo.NewMockJokeRepository(ctrl)), WithVoteRepository(repo.NewMockVoteRepository(ctrl)))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestVoteJoke400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/joke-1/vote", strings.NewReader(`{"value": 5}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	app := NewApp(e, nil, zap.NewNop(), nil, WithJokeRepository(repo.NewMockJokeRepository(ctrl)), WithVoteRepository(repo.NewMockVoteRepository(ctrl)))
+	err := app.VoteJoke(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestVoteJoke404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/unknown/vote", strings.NewReader(`{"value": 1}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("unknown")
+	jokeRepo := repo.NewMockJokeRepository(ctrl)
+	jokeRepo.EXPECT().FindOne("unknown").Return(repo.Joke{}, mongo.ErrNoDocuments)
+	app := NewApp(e, nil, zap.NewNop(), nil, WithJokeRepository(jokeRepo), WithVoteRepository(repo.NewMockVoteRepository(ctrl)))
+	err := app.VoteJoke(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+}
+
+func TestGetTopJokes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/top?window=24h&limit=3", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	voteRepo := repo.NewMockVoteRepository(ctrl)
+	voteRepo.EXPECT().Top(repo.DefaultTenant, gomock.Any(), 3).DoAndReturn(func(tenantID string, since time.Time, limit int) ([]repo.RatedJoke, error) {
+		require.WithinDuration(t, time.Now().Add(-24*time.Hour), since, time.Minute)
+		return []repo.RatedJoke{{Joke: repo.Joke{ID: "joke-1"}, Score: 2, Upvotes: 2}}, nil
+	})
+	app := NewApp(e, nil, zap.NewNop(), nil, WithVoteRepository(voteRepo))
+	err := app.GetTopJokes(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"score":2`)
+
+}
+
+func TestGetTopJokes400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/top?window=yesterday", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	app := NewApp(e, nil, zap.NewNop(), nil, WithVoteRepository(repo.NewMockVoteRepository(ctrl)))
+	err := app.GetTopJokes(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestGetJokesSafe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes?safe=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	jokeClient := joke.NewMockJokeClient(ctrl)
+	safeJokeClient := joke.NewMockJokeClient(ctrl)
+	safeJokeClient.EXPECT().GetJokes(JokeLimit).Return([]joke.Joke{{ID: "joke-1"}}, nil)
+	jokeRepo := repo.NewMockJokeRepository(ctrl)
+	jokeRepo.EXPECT().Save([]repo.Joke{{ID: "joke-1"}}).Return(nil)
+	app := NewApp(e, nil, zap.NewNop(), jokeClient, WithSafeJokeClient(safeJokeClient), WithJokeRepository(jokeRepo))
+	err := app.GetJokes(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/geoip"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// minTravelDistance is the distance in km below which logins are never
+// flagged as impossible travel, GeoIP databases are not more precise.
+const minTravelDistance = 500
+
+var LoginPageLimit = 100
+
+// Locator places IP addresses, such as a geoip.DB.
+type Locator interface {
+	Lookup(ip string) (geoip.Location, bool, error)
+}
+
+// LoginHistory configures recording login attempts and flagging suspicious
+// logins, users are emailed about flagged ones.
+type LoginHistory struct {
+	Events repo.LoginEventRepository
+	// Locator places logins for the impossible travel rule, which is skipped
+	// without one.
+	Locator Locator
+	// MaxSpeed is the fastest travel between two logins in km/h that is
+	// considered possible.
+	MaxSpeed float64
+	// Retention is how long login attempts are kept.
+	Retention time.Duration
+}
+
+// GetLogins lists the login attempts of the user, newest first.
+func (a *App) GetLogins(c echo.Context) error {
+	if a.loginHistory.Events == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Login history is not configured", Error: "missing login event repository"})
+	}
+	offset, limit, err := pagination(c, LoginPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	events, err := a.loginHistory.Events.FindByUser(userFrom(c).ID, offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list logins", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, events)
+}
+
+// recordLogin records a login attempt of user, who is empty when the email
+// is unknown. Successful logins are checked against the earlier ones. A
+// failure to record does not fail the login.
+func (a *App) recordLogin(c echo.Context, user repo.User, event repo.LoginEvent) {
+	if a.loginHistory.Events == nil {
+		return
+	}
+	now := time.Now()
+	event.TenantID = tenantFrom(c)
+	event.UserID = user.ID
+	if event.Email == "" {
+		event.Email = user.Email
+	}
+	event.At, event.IP, event.UserAgent = now, c.RealIP(), c.Request().UserAgent()
+	event.Device = deviceOf(event.UserAgent)
+	event.ExpiresAt = now.Add(a.loginHistory.Retention)
+	if a.loginHistory.Locator != nil {
+		location, found, err := a.loginHistory.Locator.Lookup(event.IP)
+		if err != nil {
+			a.log.Warn("Failed to locate IP address", zap.String("ip", event.IP), zap.Error(err))
+		} else if found {
+			event.Location = &location
+		}
+	}
+	if event.Outcome == repo.LoginSucceeded && user.ID != "" {
+		flags, err := a.suspiciousLogin(event)
+		if err != nil {
+			a.log.Warn("Failed to check login history", zap.String("userId", user.ID), zap.Error(err))
+		}
+		event.Flags = flags
+	}
+	if _, err := a.loginHistory.Events.Create(event); err != nil {
+		a.log.Warn("Failed to record login", zap.String("userId", user.ID), zap.Error(err))
+	}
+	if len(event.Flags) == 0 {
+		return
+	}
+	a.log.Warn("Suspicious login", zap.String("userId", user.ID), zap.Strings("flags", event.Flags), zap.String("ip", event.IP))
+	a.inBackground(func() {
+		if err := a.sendLoginAlert(user, event); err != nil {
+			a.log.Warn("Failed to send login alert", zap.String("userId", user.ID), zap.Error(err))
+		}
+	})
+}
+
+// suspiciousLogin flags a successful login from a device the user never
+// logged in with, or from too far away from the previous login for the
+// time in between. The first login of a user is never flagged.
+func (a *App) suspiciousLogin(event repo.LoginEvent) ([]string, error) {
+	last, err := a.loginHistory.Events.LastSuccess(event.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var flags []string
+	known, err := a.loginHistory.Events.KnownDevice(event.UserID, event.Device, event.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		flags = append(flags, repo.FlagNewDevice)
+	}
+	if last.Location != nil && event.Location != nil {
+		distance := geoip.Distance(*last.Location, *event.Location)
+		hours := event.At.Sub(last.At).Hours()
+		if distance >= minTravelDistance && (hours <= 0 || distance/hours > a.loginHistory.MaxSpeed) {
+			flags = append(flags, repo.FlagImpossibleTravel)
+		}
+	}
+	return flags, nil
+}
+
+// deviceOf names the browser and operating system of a user agent, such as
+// "Firefox on Windows". Unknown browsers are named by the first product of
+// the user agent, so "curl/8.4.0" is "curl".
+func deviceOf(userAgent string) string {
+	browser, _, _ := strings.Cut(userAgent, "/")
+	for _, known := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"FxiOS/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"CriOS/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, known.token) {
+			browser = known.name
+			break
+		}
+	}
+	for _, known := range []struct{ token, name string }{
+		{"Windows", "Windows"}, {"iPhone", "iOS"}, {"iPad", "iOS"}, {"Android", "Android"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, known.token) {
+			return browser + " on " + known.name
+		}
+	}
+	return browser
+}
+
+func (a *App) sendLoginAlert(user repo.User, event repo.LoginEvent) error {
+	where := event.IP
+	if event.Location != nil {
+		where = fmt.Sprintf("%s (%s)", event.IP, strings.TrimPrefix(event.Location.City+", "+event.Location.Country, ", "))
+	}
+	return a.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "New login to your account",
+		Body: fmt.Sprintf("Hello %s,\n\nyour account was logged in to at %s from %s with %s.\n"+
+			"If it was not you, change your password and log out everywhere.\n",
+			user.FirstName, event.At.UTC().Format(time.RFC1123), where, event.UserAgent),
+	})
+}
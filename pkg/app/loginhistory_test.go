@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/geoip"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+// testLocator places the IP addresses it knows.
+type testLocator map[string]geoip.Location
+
+func (l testLocator) Lookup(ip string) (geoip.Location, bool, error) {
+	location, found := l[ip]
+	return location, found, nil
+}
+
+func TestLoginHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	bcrypt := password.NewBcrypt(4)
+	hash, err := bcrypt.Hash("1234567898", "")
+	require.NoError(t, err)
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", FirstName: "Fo", Password: &hash}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(user, nil).AnyTimes()
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	var alerts []mail.Message
+	mailer := mail.NewMockSender(ctrl)
+	mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg mail.Message) error {
+		alerts = append(alerts, msg)
+		return nil
+	}).Times(2)
+	var recorded []repo.LoginEvent
+	events := repo.NewMockLoginEventRepository(ctrl)
+	events.EXPECT().Create(gomock.Any()).DoAndReturn(func(event repo.LoginEvent) (repo.LoginEvent, error) {
+		recorded = append(recorded, event)
+		return event, nil
+	}).Times(5)
+	gomock.InOrder(
+		events.EXPECT().LastSuccess("user-1").Return(repo.LoginEvent{}, mongo.ErrNoDocuments),
+		events.EXPECT().LastSuccess("user-1").Return(repo.LoginEvent{UserID: "user-1", At: time.Now(), Location: &geoip.Location{Latitude: 50.1109, Longitude: 8.68213}}, nil).Times(3),
+	)
+	events.EXPECT().KnownDevice("user-1", "phone", "phone").Return(false, nil)
+	events.EXPECT().KnownDevice("user-1", "phone", "phone/2").Return(true, nil)
+	events.EXPECT().KnownDevice("user-1", "laptop", "laptop").Return(true, nil)
+	e := echo.New()
+	app := NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)), WithMailer(mailer),
+		WithPasswordHasher(password.NewHasher(bcrypt)), WithLoginHistory(LoginHistory{
+			Events: events,
+			Locator: testLocator{
+				"2.16.0.1": {Country: "DE", City: "Frankfurt am Main", Latitude: 50.1109, Longitude: 8.68213},
+				"1.0.0.1":  {Country: "AU", City: "South Brisbane", Latitude: -27.4767, Longitude: 153.017},
+			},
+			MaxSpeed:  1000,
+			Retention: time.Hour,
+		}))
+	login := func(ip, userAgent, plain string) string {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "`+plain+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRealIP, ip)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		app.background.Wait()
+		var response LoginResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.Token
+	}
+
+	// Assertions
+	require.Empty(t, login("2.16.0.1", "laptop", "wrong password"))
+	accessToken := login("2.16.0.1", "laptop", "1234567898")
+	require.Empty(t, alerts, "the first login is not suspicious")
+	login("2.16.0.1", "phone", "1234567898")
+	require.Len(t, alerts, 1)
+	require.Equal(t, "fo@bo.com", alerts[0].To)
+	require.Contains(t, alerts[0].Body, "Frankfurt am Main, DE")
+	require.Contains(t, alerts[0].Body, "phone")
+	login("2.16.0.1", "phone/2", "1234567898")
+	require.Len(t, alerts, 1, "a browser update is not a new device")
+	login("1.0.0.1", "laptop", "1234567898")
+	require.Len(t, alerts, 2)
+	require.Contains(t, alerts[1].Body, "South Brisbane, AU")
+
+	require.Len(t, recorded, 5)
+	require.Equal(t, repo.LoginFailed, recorded[0].Outcome)
+	require.Equal(t, "laptop", recorded[0].UserAgent)
+	require.Equal(t, "2.16.0.1", recorded[0].IP)
+	require.Equal(t, repo.LoginSucceeded, recorded[1].Outcome)
+	require.Empty(t, recorded[1].Flags)
+	require.Equal(t, []string{repo.FlagNewDevice}, recorded[2].Flags)
+	require.Empty(t, recorded[3].Flags)
+	require.Equal(t, []string{repo.FlagImpossibleTravel}, recorded[4].Flags)
+	require.Equal(t, "South Brisbane", recorded[4].Location.City)
+
+	events.EXPECT().FindByUser("user-1", 0, LoginPageLimit).Return(recorded, nil)
+	req := httptest.NewRequest(http.MethodGet, "/me/logins", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed []repo.LoginEvent
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 5)
+	require.NotContains(t, rec.Body.String(), "fo@bo.com")
+
+	events.EXPECT().FindByUser("user-1", 3, 1).Return(recorded[1:2], nil)
+	req = httptest.NewRequest(http.MethodGet, "/me/logins?offset=3&limit=1", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+
+}
+
+func TestSuspiciousLoginTravel(t *testing.T) {
+	frankfurt := &geoip.Location{Latitude: 50.1109, Longitude: 8.68213}
+	berlin := &geoip.Location{Latitude: 52.52, Longitude: 13.405}
+	brisbane := &geoip.Location{Latitude: -27.4767, Longitude: 153.017}
+	now := time.Now()
+	for name, test := range map[string]struct {
+		from, to *geoip.Location
+		after    time.Duration
+		flagged  bool
+	}{
+		"same place":       {from: frankfurt, to: frankfurt, after: time.Second},
+		"nearby":           {from: frankfurt, to: berlin, after: time.Minute},
+		"far and fast":     {from: frankfurt, to: brisbane, after: time.Hour, flagged: true},
+		"far but flown":    {from: frankfurt, to: brisbane, after: 24 * time.Hour},
+		"unknown location": {from: nil, to: brisbane, after: time.Minute},
+		"at the same time": {from: frankfurt, to: brisbane, flagged: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// Setup
+			events := repo.NewMockLoginEventRepository(ctrl)
+			events.EXPECT().LastSuccess("user-1").Return(repo.LoginEvent{UserID: "user-1", At: now, Location: test.from}, nil)
+			events.EXPECT().KnownDevice("user-1", "laptop", "laptop").Return(true, nil)
+			app := NewApp(echo.New(), nil, zap.NewNop(), nil, WithLoginHistory(LoginHistory{Events: events, MaxSpeed: 1000}))
+
+			// Assertions
+			flags, err := app.suspiciousLogin(repo.LoginEvent{UserID: "user-1", UserAgent: "laptop", Device: "laptop", At: now.Add(test.after), Location: test.to})
+			require.NoError(t, err)
+			require.Equal(t, test.flagged, slices.Contains(flags, repo.FlagImpossibleTravel))
+
+		})
+	}
+}
+
+func TestDeviceOf(t *testing.T) {
+	// Assertions
+	for userAgent, device := range map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0":                                                        "Firefox on Windows",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36":                         "Chrome on Windows",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0":           "Edge on Windows",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15":                   "Safari on macOS",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36":                   "Chrome on Android",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 OPR/114.0.0.0":                     "Opera on Linux",
+		"curl/8.4.0": "curl",
+		"":           "",
+	} {
+		require.Equal(t, device, deviceOf(userAgent), userAgent)
+	}
+	require.Equal(t, deviceOf("Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"),
+		deviceOf("Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"), "browser updates keep the device")
+
+}
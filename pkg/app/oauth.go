@@ -0,0 +1,449 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var errInvalidAuthorization = errors.New("invalid authorization request")
+
+// OIDC configures go-user as an OpenID Connect provider, so that other apps
+// can sign their users in with their go-user account.
+type OIDC struct {
+	// Signer signs the tokens handed to clients, the provider is disabled
+	// without it.
+	Signer  *oidc.Signer
+	Clients repo.OAuthClientRepository
+	Codes   repo.AuthorizationCodeRepository
+	// ConsentURL is the page authorization requests are redirected to, with
+	// the query of the request. It asks the logged in user to approve the
+	// request with POST /oauth/consent.
+	ConsentURL string
+	CodeTTL    time.Duration
+	// TokenTTL is how long ID and access tokens handed to clients are valid.
+	TokenTTL time.Duration
+}
+
+// oauthError is an error of the OAuth protocol, reported to clients as error
+// and error_description, see RFC 6749.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// ProviderMetadata is the OpenID Connect discovery document.
+type ProviderMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+type ConsentRequest struct {
+	Approve bool `json:"approve"`
+}
+
+// ConsentResponse describes what the client asks for, for the user to decide.
+type ConsentResponse struct {
+	ClientID string   `json:"clientId"`
+	Client   string   `json:"client"`
+	Scopes   []string `json:"scopes"`
+}
+
+// RedirectResponse points the consent page to the client it should send the
+// user back to.
+type RedirectResponse struct {
+	RedirectTo string `json:"redirectTo"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+}
+
+// UserInfo is returned by the userinfo endpoint, the claims depend on the
+// scopes granted to the client.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	oidc.Profile
+}
+
+// authorizationRequest is the query of an authorization request, which is
+// passed on to the consent page and back.
+type authorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+func authorizationRequestFrom(c echo.Context) authorizationRequest {
+	return authorizationRequest{
+		ClientID:            c.QueryParam("client_id"),
+		RedirectURI:         c.QueryParam("redirect_uri"),
+		ResponseType:        c.QueryParam("response_type"),
+		Scope:               c.QueryParam("scope"),
+		State:               c.QueryParam("state"),
+		Nonce:               c.QueryParam("nonce"),
+		CodeChallenge:       c.QueryParam("code_challenge"),
+		CodeChallengeMethod: c.QueryParam("code_challenge_method"),
+	}
+}
+
+// scopes returns the requested scopes go-user knows, others are ignored.
+func (r authorizationRequest) scopes() []string {
+	scopes := []string{}
+	for _, scope := range strings.Fields(r.Scope) {
+		if slices.Contains(oidc.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// check validates the request once the client and redirect URI are known to
+// be valid, so that errors can be reported to the client.
+func (r authorizationRequest) check() *oauthError {
+	if r.ResponseType != "code" {
+		return &oauthError{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+	if !slices.Contains(r.scopes(), oidc.ScopeOpenID) {
+		return &oauthError{Code: "invalid_scope", Description: "the openid scope is required"}
+	}
+	if r.CodeChallenge == "" || r.CodeChallengeMethod != "S256" {
+		return &oauthError{Code: "invalid_request", Description: "PKCE with the S256 method is required"}
+	}
+	return nil
+}
+
+// redirect returns the redirect URI of the request with params and the state
+// of the request added to its query.
+func (r authorizationRequest) redirect(params url.Values) (string, error) {
+	link, err := url.Parse(r.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if r.State != "" {
+		query.Set("state", r.State)
+	}
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+func (a *App) GetProviderMetadata(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	issuer := a.oidc.Signer.Issuer()
+	return c.JSON(http.StatusOK, ProviderMetadata{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/oauth/jwks",
+		ScopesSupported:                   oidc.Scopes,
+		ClaimsSupported:                   []string{"sub", "email", "email_verified", "name", "given_name", "family_name", "nonce", "auth_time"},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	})
+}
+
+func (a *App) GetJWKS(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	return c.JSON(http.StatusOK, a.oidc.Signer.JWKS())
+}
+
+// Authorize starts the authorization code flow. Requests of unknown clients
+// or to unregistered redirect URIs are rejected, other errors are reported
+// to the client. Valid requests are sent on to the consent page.
+func (a *App) Authorize(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	request := authorizationRequestFrom(c)
+	_, err := a.oauthClient(request)
+	if errors.Is(err, errInvalidAuthorization) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid authorization request", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find client", Error: err.Error()})
+	}
+	if oauthErr := request.check(); oauthErr != nil {
+		link, err := request.redirect(url.Values{"error": {oauthErr.Code}, "error_description": {oauthErr.Description}})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to redirect", Error: err.Error()})
+		}
+		return c.Redirect(http.StatusFound, link)
+	}
+	consent, err := url.Parse(a.oidc.ConsentURL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to redirect", Error: err.Error()})
+	}
+	consent.RawQuery = c.QueryString()
+	return c.Redirect(http.StatusFound, consent.String())
+}
+
+// GetConsent describes the authorization request passed in the query to the
+// logged in user.
+func (a *App) GetConsent(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	request := authorizationRequestFrom(c)
+	client, err := a.consentClient(c, request)
+	if err != nil {
+		return consentFailed(c, err)
+	}
+	return c.JSON(http.StatusOK, ConsentResponse{ClientID: client.ID, Client: client.Name, Scopes: request.scopes()})
+}
+
+// Consent records the decision of the logged in user about the authorization
+// request passed in the query. Approved requests hand the client a single-use
+// code it exchanges for tokens.
+func (a *App) Consent(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	consentRequest := new(ConsentRequest)
+	if err := c.Bind(consentRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	request := authorizationRequestFrom(c)
+	client, err := a.consentClient(c, request)
+	if err != nil {
+		return consentFailed(c, err)
+	}
+	user := userFrom(c)
+	params := url.Values{"error": {"access_denied"}}
+	if consentRequest.Approve {
+		code, err := token.NewOpaque()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to generate code", Error: err.Error()})
+		}
+		now := time.Now()
+		authTime := now
+		if claims := claimsFrom(c); claims != nil && claims.IssuedAt != nil {
+			authTime = claims.IssuedAt.Time
+		}
+		err = a.oidc.Codes.Create(repo.AuthorizationCode{
+			ID:            token.HashOpaque(code),
+			ClientID:      client.ID,
+			TenantID:      tenantFrom(c),
+			UserID:        user.ID,
+			RedirectURI:   request.RedirectURI,
+			Scope:         strings.Join(request.scopes(), " "),
+			Nonce:         request.Nonce,
+			CodeChallenge: request.CodeChallenge,
+			AuthTime:      authTime,
+			ExpiresAt:     now.Add(a.oidc.CodeTTL),
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save code", Error: err.Error()})
+		}
+		params = url.Values{"code": {code}}
+		a.log.Info("Authorized client", zap.String("clientId", client.ID), zap.String("userId", user.ID))
+	}
+	link, err := request.redirect(params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to redirect", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, RedirectResponse{RedirectTo: link})
+}
+
+// Token exchanges an authorization code for an ID and an access token. The
+// client authenticates with its secret, using HTTP basic authentication or
+// the form, public clients with the PKCE code verifier alone.
+func (a *App) Token(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	clientID, secret, basic := c.Request().BasicAuth()
+	if basic {
+		// Basic credentials of clients are form encoded, see RFC 6749
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.FormValue("client_id"), c.FormValue("client_secret")
+	}
+	client, err := a.oidc.Clients.FindOne(clientID)
+	if notFound(err) || (err == nil && !client.Public() && subtle.ConstantTimeCompare([]byte(token.HashOpaque(secret)), []byte(client.SecretHash)) != 1) {
+		c.Response().Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		return c.JSON(http.StatusUnauthorized, oauthError{Code: "invalid_client", Description: "unknown client or wrong secret"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+	if grantType := c.FormValue("grant_type"); grantType != "authorization_code" {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "unsupported_grant_type", Description: "only the authorization_code grant is supported"})
+	}
+
+	// The code is used up even if the exchange fails, so that it can not be
+	// tried with other verifiers
+	code, err := a.oidc.Codes.Consume(token.HashOpaque(c.FormValue("code")), time.Now())
+	if notFound(err) {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "invalid_grant", Description: "unknown, used or expired code"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+	if code.ClientID != client.ID || code.RedirectURI != c.FormValue("redirect_uri") {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "invalid_grant", Description: "the code was issued to another client or redirect URI"})
+	}
+	if !oidc.VerifyPKCE(c.FormValue("code_verifier"), code.CodeChallenge) {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "invalid_grant", Description: "wrong code verifier"})
+	}
+	if !a.knownTenant(code.TenantID) {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "invalid_grant", Description: "unknown tenant " + code.TenantID})
+	}
+	c.Set(tenantKey, code.TenantID)
+	user, err := a.users(c).FindOne(code.UserID)
+	if notFound(err) {
+		return c.JSON(http.StatusBadRequest, oauthError{Code: "invalid_grant", Description: "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+
+	accessToken, err := a.oidc.Signer.AccessToken(user, client.ID, code.Scope, a.oidc.TokenTTL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+	idToken, err := a.oidc.Signer.IDToken(user, client.ID, code.Nonce, strings.Fields(code.Scope), code.AuthTime, a.oidc.TokenTTL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+	a.log.Info("Issued tokens to client", zap.String("clientId", client.ID), zap.String("userId", user.ID))
+	return c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(a.oidc.TokenTTL.Seconds()),
+		IDToken:     idToken,
+		Scope:       code.Scope,
+	})
+}
+
+// GetUserInfo returns the claims about the user the access token of a
+// client was granted.
+func (a *App) GetUserInfo(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	bearer, found := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !found || bearer == "" {
+		c.Response().Header().Set("WWW-Authenticate", "Bearer")
+		return c.JSON(http.StatusUnauthorized, oauthError{Code: "invalid_token", Description: "missing access token"})
+	}
+	claims, err := a.oidc.Signer.VerifyAccessToken(bearer)
+	if err != nil {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return c.JSON(http.StatusUnauthorized, oauthError{Code: "invalid_token", Description: err.Error()})
+	}
+	tenantID := claims.Tenant
+	if tenantID == "" {
+		tenantID = repo.DefaultTenant
+	}
+	if !a.knownTenant(tenantID) {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return c.JSON(http.StatusUnauthorized, oauthError{Code: "invalid_token", Description: "unknown tenant " + tenantID})
+	}
+	c.Set(tenantKey, tenantID)
+	user, err := a.users(c).FindOne(claims.Subject)
+	if notFound(err) {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return c.JSON(http.StatusUnauthorized, oauthError{Code: "invalid_token", Description: "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, oauthError{Code: "server_error", Description: err.Error()})
+	}
+	return c.JSON(http.StatusOK, UserInfo{Subject: user.ID, Profile: oidc.ProfileOf(user, strings.Fields(claims.Scope))})
+}
+
+// oauthClient finds the client of the request and checks that the redirect
+// URI is one of its own. Errors are never reported to the redirect URI, as
+// it can not be trusted.
+func (a *App) oauthClient(request authorizationRequest) (repo.OAuthClient, error) {
+	if request.ClientID == "" {
+		return repo.OAuthClient{}, fmt.Errorf("%w: missing client_id", errInvalidAuthorization)
+	}
+	client, err := a.oidc.Clients.FindOne(request.ClientID)
+	if notFound(err) {
+		return repo.OAuthClient{}, fmt.Errorf("%w: unknown client", errInvalidAuthorization)
+	}
+	if err != nil {
+		return repo.OAuthClient{}, err
+	}
+	if !slices.Contains(client.RedirectURIs, request.RedirectURI) {
+		return repo.OAuthClient{}, fmt.Errorf("%w: redirect_uri is not registered for the client", errInvalidAuthorization)
+	}
+	return client, nil
+}
+
+// consentClient validates the authorization request the user is asked to
+// consent to, the client must belong to the tenant of the user.
+func (a *App) consentClient(c echo.Context, request authorizationRequest) (repo.OAuthClient, error) {
+	client, err := a.oauthClient(request)
+	if err != nil {
+		return repo.OAuthClient{}, err
+	}
+	if client.TenantID != tenantFrom(c) {
+		return repo.OAuthClient{}, fmt.Errorf("%w: the client belongs to another tenant", errInvalidAuthorization)
+	}
+	if oauthErr := request.check(); oauthErr != nil {
+		return repo.OAuthClient{}, fmt.Errorf("%w: %s", errInvalidAuthorization, oauthErr.Description)
+	}
+	return client, nil
+}
+
+func consentFailed(c echo.Context, err error) error {
+	if errors.Is(err, errInvalidAuthorization) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid authorization request", Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find client", Error: err.Error()})
+}
+
+func oidcNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "OpenID Connect is not configured", Error: "missing issuer"})
+}
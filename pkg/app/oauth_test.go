@@ -0,0 +1,415 @@
+package app
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+const testRedirectURI = "https://wiki.example.com/callback"
+
+// providerServer serves an app acting as OpenID Connect provider for the
+// client with the secret, an empty secret makes it public.
+func providerServer(t *testing.T, ctrl *gomock.Controller, users repo.UserRepository, codes repo.AuthorizationCodeRepository, secret string) *httptest.Server {
+	e := echo.New()
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	key, err := oidc.GenerateKey()
+	require.NoError(t, err)
+	signer, err := oidc.NewSigner(server.URL, key)
+	require.NoError(t, err)
+	client := repo.OAuthClient{ID: "client-1", TenantID: repo.DefaultTenant, Name: "Wiki", RedirectURIs: []string{testRedirectURI}}
+	if secret != "" {
+		client.SecretHash = token.HashOpaque(secret)
+	}
+	clients := repo.NewMockOAuthClientRepository(ctrl)
+	clients.EXPECT().FindOne("client-1").Return(client, nil).AnyTimes()
+	clients.EXPECT().FindOne(gomock.Any()).Return(repo.OAuthClient{}, mongo.ErrNoDocuments).AnyTimes()
+	NewApp(e, users, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOIDC(OIDC{
+		Signer:     signer,
+		Clients:    clients,
+		Codes:      codes,
+		ConsentURL: server.URL + "/consent",
+		CodeTTL:    time.Minute,
+		TokenTTL:   time.Hour,
+	}))
+	return server
+}
+
+// relyingParty is an app signing users in with the provider, as a third
+// party library would.
+type relyingParty struct {
+	t        *testing.T
+	http     *http.Client
+	metadata ProviderMetadata
+	secret   string
+}
+
+func newRelyingParty(t *testing.T, issuer, secret string) *relyingParty {
+	rp := &relyingParty{t: t, secret: secret, http: &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+	res, err := rp.http.Get(issuer + "/.well-known/openid-configuration")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&rp.metadata))
+	require.Equal(t, issuer, rp.metadata.Issuer)
+	return rp
+}
+
+// login sends the user through the authorization endpoint and the consent
+// page, and returns the query the user is redirected back with.
+func (rp *relyingParty) login(user repo.User, verifier, nonce string, approve bool) url.Values {
+	sum := sha256.Sum256([]byte(verifier))
+	res, err := rp.http.Get(rp.metadata.AuthorizationEndpoint + "?" + url.Values{
+		"client_id":             {"client-1"},
+		"redirect_uri":          {testRedirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid email profile"},
+		"state":                 {"s-1"},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode())
+	require.NoError(rp.t, err)
+	res.Body.Close()
+	require.Equal(rp.t, http.StatusFound, res.StatusCode)
+	consent, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(rp.t, err)
+	require.Equal(rp.t, "/consent", consent.Path)
+
+	// The consent page asks the logged in user
+	consent.Path = "/oauth/consent"
+	req, err := http.NewRequest(http.MethodGet, consent.String(), nil)
+	require.NoError(rp.t, err)
+	authorize(rp.t, req, user)
+	res, err = rp.http.Do(req)
+	require.NoError(rp.t, err)
+	var described ConsentResponse
+	require.NoError(rp.t, json.NewDecoder(res.Body).Decode(&described))
+	res.Body.Close()
+	require.Equal(rp.t, ConsentResponse{ClientID: "client-1", Client: "Wiki", Scopes: []string{"openid", "email", "profile"}}, described)
+
+	body, err := json.Marshal(ConsentRequest{Approve: approve})
+	require.NoError(rp.t, err)
+	req, err = http.NewRequest(http.MethodPost, consent.String(), strings.NewReader(string(body)))
+	require.NoError(rp.t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(rp.t, req, user)
+	res, err = rp.http.Do(req)
+	require.NoError(rp.t, err)
+	defer res.Body.Close()
+	require.Equal(rp.t, http.StatusOK, res.StatusCode)
+	var redirect RedirectResponse
+	require.NoError(rp.t, json.NewDecoder(res.Body).Decode(&redirect))
+	callback, err := url.Parse(redirect.RedirectTo)
+	require.NoError(rp.t, err)
+	require.True(rp.t, strings.HasPrefix(redirect.RedirectTo, testRedirectURI+"?"))
+	require.Equal(rp.t, "s-1", callback.Query().Get("state"))
+	return callback.Query()
+}
+
+// exchange redeems the code, authenticating with the secret in the basic
+// authorization header if the client has one.
+func (rp *relyingParty) exchange(code, verifier string) (int, []byte) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}
+	if rp.secret == "" {
+		form.Set("client_id", "client-1")
+	}
+	req, err := http.NewRequest(http.MethodPost, rp.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	require.NoError(rp.t, err)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if rp.secret != "" {
+		req.SetBasicAuth("client-1", rp.secret)
+	}
+	res, err := rp.http.Do(req)
+	require.NoError(rp.t, err)
+	defer res.Body.Close()
+	var body json.RawMessage
+	require.NoError(rp.t, json.NewDecoder(res.Body).Decode(&body))
+	return res.StatusCode, body
+}
+
+// verify checks the ID token with the keys the provider publishes.
+func (rp *relyingParty) verify(idToken string) *oidc.IDClaims {
+	res, err := rp.http.Get(rp.metadata.JWKSURI)
+	require.NoError(rp.t, err)
+	defer res.Body.Close()
+	var jwks oidc.JWKSet
+	require.NoError(rp.t, json.NewDecoder(res.Body).Decode(&jwks))
+	claims := &oidc.IDClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(signed *jwt.Token) (interface{}, error) {
+		for _, jwk := range jwks.Keys {
+			if jwk.KeyID == signed.Header["kid"] {
+				modulus, _ := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+				exponent, _ := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+			}
+		}
+		return nil, jwt.ErrTokenUnverifiable
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(rp.metadata.Issuer), jwt.WithAudience("client-1"))
+	require.NoError(rp.t, err)
+	return claims
+}
+
+func (rp *relyingParty) userInfo(accessToken string) (int, UserInfo) {
+	req, err := http.NewRequest(http.MethodGet, rp.metadata.UserinfoEndpoint, nil)
+	require.NoError(rp.t, err)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	res, err := rp.http.Do(req)
+	require.NoError(rp.t, err)
+	defer res.Body.Close()
+	var info UserInfo
+	if res.StatusCode == http.StatusOK {
+		require.NoError(rp.t, json.NewDecoder(res.Body).Decode(&info))
+	}
+	return res.StatusCode, info
+}
+
+func TestOIDCLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", FirstName: "Fo", LastName: "Bo", EmailVerified: true}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	codes := repo.NewMockAuthorizationCodeRepository(ctrl)
+	var issued repo.AuthorizationCode
+	codes.EXPECT().Create(gomock.Any()).DoAndReturn(func(code repo.AuthorizationCode) error {
+		issued = code
+		return nil
+	})
+	server := providerServer(t, ctrl, db, codes, "wiki-secret")
+	rp := newRelyingParty(t, server.URL, "wiki-secret")
+	verifier := strings.Repeat("v", 64)
+
+	callback := rp.login(user, verifier, "n-1", true)
+	require.Equal(t, token.HashOpaque(callback.Get("code")), issued.ID)
+	gomock.InOrder(
+		codes.EXPECT().Consume(issued.ID, gomock.Any()).Return(issued, nil),
+		codes.EXPECT().Consume(issued.ID, gomock.Any()).Return(repo.AuthorizationCode{}, mongo.ErrNoDocuments),
+	)
+	code, body := rp.exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusOK, code)
+	var tokens TokenResponse
+	require.NoError(t, json.Unmarshal(body, &tokens))
+	require.Equal(t, "Bearer", tokens.TokenType)
+	require.Equal(t, "openid email profile", tokens.Scope)
+
+	claims := rp.verify(tokens.IDToken)
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, "n-1", claims.Nonce)
+	require.Equal(t, "fo@bo.com", claims.Email)
+	require.True(t, *claims.EmailVerified)
+	require.Equal(t, "Fo", claims.GivenName)
+	require.Equal(t, "Bo", claims.FamilyName)
+	require.NotNil(t, claims.AuthTime)
+
+	status, info := rp.userInfo(tokens.AccessToken)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "user-1", info.Subject)
+	require.Equal(t, "Fo Bo", info.Name)
+	// ID tokens are meant for the client only
+	status, _ = rp.userInfo(tokens.IDToken)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// Codes are single-use
+	code, body = rp.exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.JSONEq(t, `{"error": "invalid_grant", "error_description": "unknown, used or expired code"}`, string(body))
+
+}
+
+func TestOIDCLoginPKCE(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	user := repo.User{ID: "user-1", Email: "fo@bo.com"}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	codes := repo.NewMockAuthorizationCodeRepository(ctrl)
+	var issued []repo.AuthorizationCode
+	codes.EXPECT().Create(gomock.Any()).DoAndReturn(func(code repo.AuthorizationCode) error {
+		issued = append(issued, code)
+		return nil
+	}).Times(2)
+	server := providerServer(t, ctrl, db, codes, "")
+	rp := newRelyingParty(t, server.URL, "")
+	verifier := strings.Repeat("v", 64)
+
+	// A wrong verifier uses the code up
+	callback := rp.login(user, verifier, "n-1", true)
+	gomock.InOrder(
+		codes.EXPECT().Consume(issued[0].ID, gomock.Any()).Return(issued[0], nil),
+		codes.EXPECT().Consume(issued[0].ID, gomock.Any()).Return(repo.AuthorizationCode{}, mongo.ErrNoDocuments),
+	)
+	code, body := rp.exchange(callback.Get("code"), strings.Repeat("w", 64))
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, string(body), "wrong code verifier")
+	code, _ = rp.exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// Public clients authenticate with the verifier alone
+	callback = rp.login(user, verifier, "n-2", true)
+	codes.EXPECT().Consume(issued[1].ID, gomock.Any()).Return(issued[1], nil)
+	code, _ = rp.exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusOK, code)
+
+	callback = rp.login(user, verifier, "n-3", false)
+	require.Equal(t, "access_denied", callback.Get("error"))
+	require.Empty(t, callback.Get("code"))
+
+}
+
+func TestOIDCClientSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	user := repo.User{ID: "user-1"}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	codes := repo.NewMockAuthorizationCodeRepository(ctrl)
+	codes.EXPECT().Create(gomock.Any()).Return(nil)
+	server := providerServer(t, ctrl, db, codes, "wiki-secret")
+	verifier := strings.Repeat("v", 64)
+
+	callback := newRelyingParty(t, server.URL, "wiki-secret").login(user, verifier, "n-1", true)
+	code, body := newRelyingParty(t, server.URL, "guessed").exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Contains(t, string(body), "invalid_client")
+	// Confidential clients need their secret
+	code, _ = newRelyingParty(t, server.URL, "").exchange(callback.Get("code"), verifier)
+	require.Equal(t, http.StatusUnauthorized, code)
+
+}
+
+func TestAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	server := providerServer(t, ctrl, knownUsers(ctrl), repo.NewMockAuthorizationCodeRepository(ctrl), "wiki-secret")
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authorizeWith := func(params url.Values) *http.Response {
+		res, err := client.Get(server.URL + "/oauth/authorize?" + params.Encode())
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+	valid := func() url.Values {
+		return url.Values{
+			"client_id":             {"client-1"},
+			"redirect_uri":          {testRedirectURI},
+			"response_type":         {"code"},
+			"scope":                 {"openid"},
+			"state":                 {"s-1"},
+			"code_challenge":        {"challenge"},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	// Never redirected to unknown clients or unregistered URIs
+	params := valid()
+	params.Set("client_id", "client-2")
+	require.Equal(t, http.StatusBadRequest, authorizeWith(params).StatusCode)
+	params = valid()
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+	require.Equal(t, http.StatusBadRequest, authorizeWith(params).StatusCode)
+
+	for param, oauthErr := range map[string]string{"code_challenge_method": "invalid_request", "scope": "invalid_scope", "response_type": "unsupported_response_type"} {
+		params = valid()
+		params.Set(param, "plain")
+		res := authorizeWith(params)
+		require.Equal(t, http.StatusFound, res.StatusCode)
+		location, err := url.Parse(res.Header.Get("Location"))
+		require.NoError(t, err)
+		require.Equal(t, oauthErr, location.Query().Get("error"))
+		require.Equal(t, "s-1", location.Query().Get("state"))
+	}
+
+}
+
+func TestOAuthClients(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	clients := repo.NewMockOAuthClientRepository(ctrl)
+	var stored []repo.OAuthClient
+	clients.EXPECT().Create(gomock.Any()).DoAndReturn(func(client repo.OAuthClient) (repo.OAuthClient, error) {
+		require.Equal(t, repo.DefaultTenant, client.TenantID)
+		require.Equal(t, "admin-1", client.CreatedBy)
+		client.ID = "client-1"
+		stored = append(stored, client)
+		return client, nil
+	}).Times(2)
+	clients.EXPECT().Find(repo.DefaultTenant, 0, OAuthClientPageLimit).DoAndReturn(func(string, int, int) ([]repo.OAuthClient, error) {
+		return stored, nil
+	})
+	clients.EXPECT().Delete("client-1", repo.DefaultTenant).Return(nil)
+	clients.EXPECT().Delete("client-2", repo.DefaultTenant).Return(mongo.ErrNoDocuments)
+	key, err := oidc.GenerateKey()
+	require.NoError(t, err)
+	signer, err := oidc.NewSigner("http://localhost:8080", key)
+	require.NoError(t, err)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleAdmin), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOIDC(OIDC{Signer: signer, Clients: clients}))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/admin/oauth/clients", `{"name": "Wiki", "redirectUris": ["https://wiki.example.com/callback"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created OAuthClientResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Secret)
+	require.Equal(t, token.HashOpaque(created.Secret), stored[0].SecretHash)
+	rec = send(http.MethodPost, "/admin/oauth/clients", `{"name": "SPA", "redirectUris": ["https://spa.example.com/"], "public": true}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.NotContains(t, rec.Body.String(), "secret")
+	require.True(t, stored[1].Public())
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/oauth/clients", `{"name": "Wiki", "redirectUris": ["https://wiki.example.com/#callback"]}`).Code)
+	require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/oauth/clients", `{"name": "Wiki", "redirectUris": []}`).Code)
+
+	rec = send(http.MethodGet, "/admin/oauth/clients", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), stored[0].SecretHash)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/oauth/clients/client-1", "").Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/admin/oauth/clients/client-2", "").Code)
+
+}
+
+func TestOIDCNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	rec := httptest.NewRecorder()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var OAuthClientPageLimit = 100
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirectUris" validate:"required,min=1,max=10,dive,url"`
+	// Public clients, such as single page apps, get no secret.
+	Public bool `json:"public"`
+}
+
+// OAuthClientResponse carries the secret of the client, which is only shown once.
+type OAuthClientResponse struct {
+	repo.OAuthClient
+	Secret string `json:"secret,omitempty"`
+}
+
+// CreateOAuthClient registers an app the users of the tenant can sign in to.
+func (a *App) CreateOAuthClient(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	createRequest := new(CreateOAuthClientRequest)
+	if err := c.Bind(createRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(createRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	for _, redirectURI := range createRequest.RedirectURIs {
+		if parsed, err := url.Parse(redirectURI); err != nil || parsed.Fragment != "" {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid redirect URI", Error: "redirect URIs must not have a fragment"})
+		}
+	}
+
+	var secret string
+	client := repo.OAuthClient{
+		TenantID:     tenantFrom(c),
+		Name:         createRequest.Name,
+		RedirectURIs: createRequest.RedirectURIs,
+		CreatedBy:    userFrom(c).ID,
+		CreatedAt:    time.Now(),
+	}
+	if !createRequest.Public {
+		var err error
+		if secret, err = token.NewOpaque(); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to generate secret", Error: err.Error()})
+		}
+		client.SecretHash = token.HashOpaque(secret)
+	}
+	client, err := a.oidc.Clients.Create(client)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save client", Error: err.Error()})
+	}
+	a.log.Info("Registered OAuth client", zap.String("clientId", client.ID), zap.String("by", userFrom(c).ID))
+	return c.JSON(http.StatusCreated, OAuthClientResponse{OAuthClient: client, Secret: secret})
+}
+
+func (a *App) GetOAuthClients(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	offset, limit, err := pagination(c, OAuthClientPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+	clients, err := a.oidc.Clients.Find(tenantFrom(c), offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list clients", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, clients)
+}
+
+// DeleteOAuthClient removes a client, codes it was handed can no longer be
+// exchanged. Tokens it holds stay valid until they expire.
+func (a *App) DeleteOAuthClient(c echo.Context) error {
+	if a.oidc.Signer == nil {
+		return oidcNotConfigured(c)
+	}
+	err := a.oidc.Clients.Delete(c.Param("id"), tenantFrom(c))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Client not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete client", Error: err.Error()})
+	}
+	a.log.Info("Deleted OAuth client", zap.String("clientId", c.Param("id")), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
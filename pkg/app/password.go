@@ -0,0 +1,122 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+type PasswordPolicyResponse struct {
+	password.Policy
+	RejectBreached bool `json:"rejectBreached"`
+}
+
+// GetPasswordPolicy describes the password rules so that clients can hint at
+// them before submitting a password.
+func (a *App) GetPasswordPolicy(c echo.Context) error {
+	return c.JSON(http.StatusOK, PasswordPolicyResponse{Policy: a.policy, RejectBreached: a.policy.Breached != nil})
+}
+
+// ChangePassword replaces the password of the logged in user. Access tokens
+// issued before are revoked, the response carries a new one for the caller.
+func (a *App) ChangePassword(c echo.Context) error {
+	changeRequest := new(ChangePasswordRequest)
+	if err := c.Bind(changeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(changeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	user := userFrom(c)
+
+	// Guessing the current password with a stolen access token counts towards the lockout
+	ip := c.RealIP()
+	wait, err := a.lockout.Check(account(c, user.Email), ip)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check login attempts", Error: err.Error()})
+	}
+	if wait > 0 {
+		return tooManyRequests(c, wait, "Too many failed login attempts")
+	}
+	match, _, err := a.hasher.Verify(changeRequest.CurrentPassword, *user.Password)
+	if err != nil {
+		a.log.Warn("Failed to verify password hash", zap.String("userId", user.ID), zap.Error(err))
+	}
+	if !match {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Invalid current password", Error: "Invalid current password"})
+	}
+	if err := a.lockout.Forgive(account(c, user.Email), ip); err != nil {
+		a.log.Warn("Failed to forgive login attempt", zap.Error(err))
+	}
+
+	if err := a.checkPassword(changeRequest.NewPassword, user.Email, user.FirstName, user.LastName); err != nil {
+		return passwordError(c, err)
+	}
+	if a.reusedPassword(user, changeRequest.NewPassword) {
+		return reusedPasswordError(c)
+	}
+	if err := a.updatePassword(c, user, changeRequest.NewPassword); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update password", Error: err.Error()})
+	}
+	return a.issueToken(c, user)
+}
+
+// checkPassword checks a new password against the policy, personal are the
+// email and names of its user.
+func (a *App) checkPassword(plain string, personal ...string) error {
+	return a.policy.Check(plain, personal...)
+}
+
+func passwordError(c echo.Context, err error) error {
+	if errors.Is(err, password.ErrWeakPassword) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Password does not follow the policy", Error: err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check password", Error: err.Error()})
+}
+
+// reusedPassword reports whether plain is one of the recent passwords of user
+// the policy forbids to choose again.
+func (a *App) reusedPassword(user repo.User, plain string) bool {
+	if a.policy.History <= 0 || user.Password == nil {
+		return false
+	}
+	hashes := []string{*user.Password}
+	history := user.PasswordHistory
+	if previous := a.policy.History - 1; len(history) > previous {
+		history = history[len(history)-previous:]
+	}
+	for _, hash := range append(hashes, history...) {
+		match, _, err := a.hasher.Verify(plain, hash)
+		if err != nil {
+			a.log.Warn("Failed to verify previous password hash", zap.String("userId", user.ID), zap.Error(err))
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePassword stores the new password and keeps as many previous hashes
+// as the policy needs. The user is logged out everywhere.
+func (a *App) updatePassword(c echo.Context, user repo.User, plain string) error {
+	if err := a.users(c).UpdatePassword(user.ID, plain, time.Now(), max(a.policy.History-1, 0)); err != nil {
+		return err
+	}
+	a.endSessions(user.ID)
+	return nil
+}
+
+func reusedPasswordError(c echo.Context) error {
+	return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Password does not follow the policy", Error: "password was used recently"})
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestGetPasswordPolicy(t *testing.T) {
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/password-policy", nil)
+	rec := httptest.NewRecorder()
+	policy := password.DefaultPolicy
+	policy.RequireDigit = true
+	NewApp(e, nil, zap.NewNop(), nil, WithPasswordPolicy(policy))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"minLength": 8, "maxLength": 128, "requireUpper": false, "requireLower": false,
+		"requireDigit": true, "requireSymbol": false, "rejectPersonal": true, "rejectCommon": true, "history": 5, "rejectBreached": false}`, rec.Body.String())
+
+}
+
+func TestCreateUserWeakPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	for _, plain := range []string{"password1", "foobar-secret", "1234567"} {
+		// Setup
+		userJson := `{"email": "foobar@bo.com", "firstName": "Foo", "lastName": "Bar", "password": "` + plain + `"}`
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(userJson))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		db := repo.NewMockUserRepository(ctrl)
+		app := NewApp(e, db, zap.NewNop(), nil)
+		err := app.CreateUser(c)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, rec.Code, plain)
+		require.Contains(t, rec.Body.String(), "Password does not follow the policy")
+	}
+
+}
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	hasher := password.NewHasher(password.NewArgon2id(password.Argon2idParams{Memory: 1024, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}))
+	current, err := hasher.Hash("current horse battery staple")
+	require.NoError(t, err)
+	previous, err := hasher.Hash("previous horse battery staple")
+	require.NoError(t, err)
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", Password: &current, PasswordHistory: []string{previous}}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	db.EXPECT().UpdatePassword("user-1", "correct horse battery staple", gomock.Any(), 4).Return(nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithPasswordHasher(hasher), WithLockout(noLockout(ctrl)))
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"currentPassword": "wrong", "newPassword": "correct horse battery staple"}`, http.StatusForbidden},
+		{`{"currentPassword": "current horse battery staple", "newPassword": "password1"}`, http.StatusBadRequest},
+		{`{"currentPassword": "current horse battery staple", "newPassword": "current horse battery staple"}`, http.StatusBadRequest},
+		{`{"currentPassword": "current horse battery staple", "newPassword": "previous horse battery staple"}`, http.StatusBadRequest},
+		{`{"currentPassword": "current horse battery staple", "newPassword": "correct horse battery staple"}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(tc.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, user)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, tc.code, rec.Code, tc.body)
+		if tc.code == http.StatusOK {
+			require.Contains(t, rec.Body.String(), `"token"`)
+		}
+	}
+
+}
+
+func TestChangePasswordUnauthenticated(t *testing.T) {
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(`{"currentPassword": "a", "newPassword": "b"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	NewApp(e, nil, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+const forgotAccepted = "If the email is registered, a password reset link has been sent"
+
+// ForgotPassword emails a password reset link and revokes the previous ones.
+// It responds the same and as fast whether or not the email is registered, the
+// link is sent after the response.
+func (a *App) ForgotPassword(c echo.Context) error {
+	forgotRequest := new(ForgotPasswordRequest)
+	if err := c.Bind(forgotRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(forgotRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+
+	// Every request counts, whether or not the email is registered
+	ip := c.RealIP()
+	wait, err := a.reset.Limiter.Check(account(c, forgotRequest.Email), ip)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check password reset requests", Error: err.Error()})
+	}
+	if wait > 0 {
+		return tooManyRequests(c, wait, "Too many password reset requests")
+	}
+
+	user, err := a.users(c).FindByEmail(forgotRequest.Email)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusAccepted, MessageResponse{Message: forgotAccepted})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	a.inBackground(func() {
+		if err := a.tokenRepo.DeleteByUser(user.ID, token.PurposeResetPassword); err != nil {
+			a.log.Error("Failed to revoke password reset tokens", zap.String("userId", user.ID), zap.Error(err))
+			return
+		}
+		if err := a.sendPasswordReset(user); err != nil {
+			a.log.Error("Failed to send password reset email", zap.String("userId", user.ID), zap.Error(err))
+		}
+	})
+	return c.JSON(http.StatusAccepted, MessageResponse{Message: forgotAccepted})
+}
+
+// ResetPassword sets a new password with a token from a reset link. Access
+// tokens issued before the reset are no longer accepted.
+func (a *App) ResetPassword(c echo.Context) error {
+	resetRequest := new(ResetPasswordRequest)
+	if err := c.Bind(resetRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(resetRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+
+	// The token is only consumed once the new password follows the policy,
+	// so that a rejected password does not waste the link.
+	now := time.Now()
+	hash := token.HashOpaque(resetRequest.Token)
+	resetToken, err := a.tokenRepo.FindOne(hash)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !resetToken.Usable(token.PurposeResetPassword, now)) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid reset token", Error: "token is unknown, expired or already used"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find reset token", Error: err.Error()})
+	}
+	user, err := a.users(c).FindOne(resetToken.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid reset token", Error: "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	if err := a.checkPassword(resetRequest.Password, user.Email, user.FirstName, user.LastName); err != nil {
+		return passwordError(c, err)
+	}
+	if a.reusedPassword(user, resetRequest.Password) {
+		return reusedPasswordError(c)
+	}
+	if _, err := a.tokenRepo.Consume(hash, token.PurposeResetPassword, now); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid reset token", Error: "token is unknown, expired or already used"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to consume reset token", Error: err.Error()})
+	}
+	if err := a.updatePassword(c, user, resetRequest.Password); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update password", Error: err.Error()})
+	}
+	// Whoever locked the account out by guessing can no longer use the old password
+	if err := a.lockout.Unlock(account(c, user.Email), ""); err != nil {
+		a.log.Warn("Failed to reset failed logins", zap.String("userId", user.ID), zap.Error(err))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *App) sendPasswordReset(user repo.User) error {
+	opaque, err := token.NewOpaque()
+	if err != nil {
+		return err
+	}
+	err = a.tokenRepo.Create(repo.OneTimeToken{
+		ID:        token.HashOpaque(opaque),
+		UserID:    user.ID,
+		Purpose:   token.PurposeResetPassword,
+		ExpiresAt: time.Now().Add(a.reset.TTL),
+	})
+	if err != nil {
+		return err
+	}
+	link, err := tokenLink(a.reset.URL, opaque, user.TenantID)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nopen the link below within %s to choose a new password:\n\n%s\n\n"+
+			"If you did not ask for a password reset you can ignore this email.\n",
+			user.FirstName, a.reset.TTL, link),
+	})
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	db.EXPECT().FindByEmail("unknown@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().DeleteByUser("user-1", token.PurposeResetPassword).Return(nil)
+	var stored repo.OneTimeToken
+	tokens.EXPECT().Create(gomock.Any()).DoAndReturn(func(t repo.OneTimeToken) error {
+		stored = t
+		return nil
+	})
+	mailer := mail.NewMockSender(ctrl)
+	var sent mail.Message
+	mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg mail.Message) error {
+		sent = msg
+		return nil
+	})
+	app := NewApp(e, db, zap.NewNop(), nil, WithOneTimeTokenRepository(tokens), WithMailer(mailer),
+		WithPasswordReset(PasswordReset{URL: "https://bo.com/reset", TTL: time.Hour, Limiter: lockout.NewLimiter(noAttempts(ctrl), lockout.QuotaPolicy(3, 20, time.Hour))}))
+	var bodies []string
+	for _, email := range []string{"fo@bo.com", "unknown@bo.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"email": "`+email+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		bodies = append(bodies, rec.Body.String())
+	}
+	require.Equal(t, bodies[0], bodies[1])
+	app.background.Wait()
+
+	// Only the hash of the emailed token is stored
+	link, err := url.Parse(strings.TrimSpace(strings.Split(sent.Body[strings.Index(sent.Body, "https://"):], "\n")[0]))
+	require.NoError(t, err)
+	require.Equal(t, token.HashOpaque(link.Query().Get("token")), stored.ID)
+	require.Equal(t, "user-1", stored.UserID)
+	require.Equal(t, token.PurposeResetPassword, stored.Purpose)
+
+}
+
+func TestForgotPasswordRateLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"email": "fo@bo.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().RecordFailure("account:fo@bo.com", gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 4, PreviousFailure: time.Now()}, nil)
+	attempts.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil)
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil,
+		WithPasswordReset(PasswordReset{Limiter: lockout.NewLimiter(attempts, lockout.QuotaPolicy(3, 20, time.Hour))}))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token": "opaque", "password": "correct horse battery staple"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	hash := token.HashOpaque("opaque")
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().FindOne(hash).Return(repo.OneTimeToken{ID: hash, UserID: "user-1", Purpose: token.PurposeResetPassword, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	tokens.EXPECT().Consume(hash, token.PurposeResetPassword, gomock.Any()).Return(repo.OneTimeToken{}, nil)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	db.EXPECT().UpdatePassword("user-1", "correct horse battery staple", gomock.Any(), 4).Return(nil)
+	NewApp(e, db, zap.NewNop(), nil, WithOneTimeTokenRepository(tokens), WithLockout(noLockout(ctrl)))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+}
+
+func TestResetPasswordWeak(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token": "opaque", "password": "password1"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	hash := token.HashOpaque("opaque")
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	// The token is not consumed
+	tokens.EXPECT().FindOne(hash).Return(repo.OneTimeToken{ID: hash, UserID: "user-1", Purpose: token.PurposeResetPassword, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	NewApp(e, db, zap.NewNop(), nil, WithOneTimeTokenRepository(tokens))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestResetPasswordUsedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token": "opaque", "password": "correct horse battery staple"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	usedAt := time.Now()
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().FindOne(gomock.Any()).Return(repo.OneTimeToken{UserID: "user-1", Purpose: token.PurposeResetPassword, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}, nil)
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithOneTimeTokenRepository(tokens))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
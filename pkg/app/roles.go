@@ -0,0 +1,132 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type UserResponse struct {
+	repo.User
+	// Groups are the names of the groups of the user, whose roles they inherit.
+	Groups      []string          `json:"groups"`
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// GetRoles lists the roles that can be granted and their permissions.
+func (a *App) GetRoles(c echo.Context) error {
+	return c.JSON(http.StatusOK, a.roles)
+}
+
+// GetUser returns a user with their groups and the permissions granted by
+// their own roles and those of their groups.
+func (a *App) GetUser(c echo.Context) error {
+	user, err := a.users(c).FindOne(c.Param("id"))
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	response, err := a.userResponse(c, user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find groups", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (a *App) userResponse(c echo.Context, user repo.User) (UserResponse, error) {
+	groups, err := a.groupsOf(c, user.ID)
+	if err != nil {
+		return UserResponse{}, err
+	}
+	return UserResponse{User: user, Groups: groupNames(groups), Permissions: a.roles.Permissions(inheritedRoles(user, groups))}, nil
+}
+
+// GrantRole adds a role to a user.
+func (a *App) GrantRole(c echo.Context) error {
+	roleRequest := new(RoleRequest)
+	if err := c.Bind(roleRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(roleRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if !a.roles.Known(roleRequest.Role) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Unknown role", Error: "unknown role " + roleRequest.Role})
+	}
+	err := a.users(c).GrantRole(c.Param("id"), roleRequest.Role)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to grant role", Error: err.Error()})
+	}
+	a.log.Info("Granted role", zap.String("userId", c.Param("id")), zap.String("role", roleRequest.Role), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeRole removes a role from a user. Administrators can not revoke their
+// own admin role, so that the last one can not lock everybody out.
+func (a *App) RevokeRole(c echo.Context) error {
+	id, role := c.Param("id"), c.Param("role")
+	if id == userFrom(c).ID && role == rbac.RoleAdmin {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Can not revoke your own admin role", Error: "ask another administrator"})
+	}
+	err := a.users(c).RevokeRole(id, role)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke role", Error: err.Error()})
+	}
+	a.log.Info("Revoked role", zap.String("userId", id), zap.String("role", role), zap.String("by", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BootstrapAdmins grants the admin role to the users with the given emails at
+// startup, so that the first administrator of a tenant does not need another
+// one. Emails of users of other tenants than the default one are written as
+// tenant/email. Only verified emails are trusted, others are skipped like
+// unknown ones.
+func BootstrapAdmins(tenants repo.UserTenants, log *zap.Logger, emails ...string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		tenantID, tenantEmail, found := strings.Cut(email, "/")
+		if !found {
+			tenantID, tenantEmail = repo.DefaultTenant, email
+		}
+		userRepo := tenants.ForTenant(tenantID)
+		user, err := userRepo.FindByEmail(tenantEmail)
+		if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !user.EmailVerified) {
+			log.Warn("Skipped admin without a verified account", zap.String("email", email))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := userRepo.GrantRole(user.ID, rbac.RoleAdmin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notFound reports whether err means there is no user or group with the requested ID.
+func notFound(err error) bool {
+	return errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, primitive.ErrInvalidHex)
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestGetUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/user-2", nil)
+	authorize(t, req, repo.User{ID: "support-1"})
+	rec := httptest.NewRecorder()
+	users := repo.NewMockUserRepository(ctrl)
+	users.EXPECT().FindOne("support-1").Return(repo.User{ID: "support-1", Roles: []string{rbac.RoleSupport}}, nil)
+	users.EXPECT().FindOne("user-2").Return(repo.User{ID: "user-2", Email: "fo@bo.com", Roles: []string{rbac.RoleModerator}}, nil)
+	NewApp(e, users, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var user UserResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	require.Equal(t, "fo@bo.com", user.Email)
+	require.Equal(t, []string{rbac.RoleModerator}, user.Roles)
+	require.Equal(t, []rbac.Permission{rbac.JokesModerate}, user.Permissions)
+
+}
+
+func TestGrantRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	users := usersWithRoles(ctrl, rbac.RoleAdmin)
+	users.EXPECT().GrantRole("user-2", rbac.RoleModerator).Return(nil)
+	users.EXPECT().GrantRole("user-3", rbac.RoleModerator).Return(mongo.ErrNoDocuments)
+	NewApp(e, users, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	grant := func(id, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/"+id+"/roles", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, grant("user-2", `{"role": "moderator"}`))
+	require.Equal(t, http.StatusNotFound, grant("user-3", `{"role": "moderator"}`))
+	require.Equal(t, http.StatusBadRequest, grant("user-2", `{"role": "root"}`))
+	require.Equal(t, http.StatusBadRequest, grant("user-2", `{}`))
+
+}
+
+func TestGrantRoleRequiresPermission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/user-2/roles", strings.NewReader(`{"role": "admin"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "support-1"})
+	rec := httptest.NewRecorder()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleSupport), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+}
+
+func TestRevokeRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	users := usersWithRoles(ctrl, rbac.RoleAdmin)
+	users.EXPECT().RevokeRole("admin-2", rbac.RoleAdmin).Return(nil)
+	NewApp(e, users, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	revoke := func(id, role string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/admin/users/"+id+"/roles/"+role, nil)
+		authorize(t, req, repo.User{ID: "admin-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, revoke("admin-2", rbac.RoleAdmin))
+	require.Equal(t, http.StatusConflict, revoke("admin-1", rbac.RoleAdmin))
+
+}
+
+func TestBootstrapAdmins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	users := repo.NewMockUserRepository(ctrl)
+	users.EXPECT().FindByEmail("admin@bo.com").Return(repo.User{ID: "admin-1", EmailVerified: true}, nil)
+	users.EXPECT().FindByEmail("unverified@bo.com").Return(repo.User{ID: "user-2"}, nil)
+	users.EXPECT().FindByEmail("unknown@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+	users.EXPECT().GrantRole("admin-1", rbac.RoleAdmin).Return(nil)
+	acmeUsers := repo.NewMockUserRepository(ctrl)
+	acmeUsers.EXPECT().FindByEmail("admin@acme.com").Return(repo.User{ID: "admin-2", EmailVerified: true}, nil)
+	acmeUsers.EXPECT().GrantRole("admin-2", rbac.RoleAdmin).Return(nil)
+	tenants := repo.NewMockUserTenants(ctrl)
+	tenants.EXPECT().ForTenant(repo.DefaultTenant).Return(users).Times(3)
+	tenants.EXPECT().ForTenant("acme").Return(acmeUsers)
+	err := BootstrapAdmins(tenants, zap.NewNop(), "admin@bo.com", " unverified@bo.com", "unknown@bo.com", "", "acme/admin@acme.com")
+	require.NoError(t, err)
+
+}
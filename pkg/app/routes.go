@@ -1,7 +1,81 @@
 package app
 
+import "github.com/Davut97/go-user/pkg/rbac"
+
 func (a *App) RegisterRoutes() {
+	a.e.Use(a.Tenant)
 	a.e.POST("/user", a.CreateUser)
+	a.e.POST("/user/verify", a.VerifyEmail)
+	a.e.POST("/user/verify/resend", a.ResendVerification)
+	a.e.POST("/user/invitations/accept", a.AcceptInvitation)
 	a.e.POST("/login", a.Login)
+	a.e.POST("/login/mfa", a.LoginMFA)
+	a.e.POST("/login/webauthn/begin", a.BeginWebAuthnLogin)
+	a.e.POST("/login/webauthn/finish", a.FinishWebAuthnLogin)
+	a.e.POST("/login/oidc/begin", a.BeginFederatedLogin)
+	a.e.POST("/login/oidc/finish", a.FinishFederatedLogin)
+	a.e.GET("/password-policy", a.GetPasswordPolicy)
+	a.e.POST("/password/forgot", a.ForgotPassword)
+	a.e.POST("/password/reset", a.ResetPassword)
+	a.e.GET("/me", a.GetMe, a.Authenticated)
+	a.e.POST("/me/password", a.ChangePassword, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/me/totp", a.EnrollTOTP, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.GET("/me/totp/qr", a.GetTOTPQRCode, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/me/totp/confirm", a.ConfirmTOTP, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.DELETE("/me/totp", a.DisableTOTP, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.GET("/me/webauthn", a.GetWebAuthnCredentials, a.Authenticated, a.Interactive)
+	a.e.POST("/me/webauthn/register/begin", a.BeginWebAuthnRegistration, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/me/webauthn/register/finish", a.FinishWebAuthnRegistration, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.DELETE("/me/webauthn/:id", a.DeleteWebAuthnCredential, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/me/api-keys", a.CreateAPIKey, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.GET("/me/api-keys", a.GetAPIKeys, a.Authenticated, a.Interactive)
+	a.e.DELETE("/me/api-keys/:id", a.DeleteAPIKey, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.GET("/me/logins", a.GetLogins, a.Authenticated, a.Interactive)
+	a.e.GET("/me/sessions", a.GetSessions, a.Authenticated, a.Interactive)
+	a.e.DELETE("/me/sessions", a.DeleteSessions, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.DELETE("/me/sessions/:id", a.DeleteSession, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.GET("/me/identities", a.GetIdentities, a.Authenticated, a.Interactive)
+	a.e.POST("/me/identities/begin", a.BeginIdentityLink, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/me/identities/finish", a.FinishIdentityLink, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.DELETE("/me/identities/:id", a.DeleteIdentity, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/admin/unlock", a.Unlock, a.Authenticated, a.Require(rbac.UsersWrite))
+	a.e.GET("/admin/roles", a.GetRoles, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.GET("/admin/users/:id", a.GetUser, a.Authenticated, a.Require(rbac.UsersRead))
+	a.e.POST("/admin/users/:id/impersonate", a.Impersonate, a.Authenticated, a.Interactive, a.NotImpersonated, a.Require(rbac.UsersImpersonate))
+	a.e.POST("/admin/users/:id/roles", a.GrantRole, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.DELETE("/admin/users/:id/roles/:role", a.RevokeRole, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.POST("/admin/groups", a.CreateGroup, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.GET("/admin/groups", a.GetGroups, a.Authenticated, a.Require(rbac.UsersRead))
+	a.e.GET("/admin/groups/:id", a.GetGroup, a.Authenticated, a.Require(rbac.UsersRead))
+	a.e.PUT("/admin/groups/:id", a.UpdateGroup, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.DELETE("/admin/groups/:id", a.DeleteGroup, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.GET("/admin/groups/:id/members", a.GetGroupMembers, a.Authenticated, a.Require(rbac.UsersRead))
+	a.e.POST("/admin/groups/:id/members", a.AddGroupMember, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.DELETE("/admin/groups/:id/members/:userId", a.RemoveGroupMember, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.POST("/admin/groups/:id/roles", a.GrantGroupRole, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.DELETE("/admin/groups/:id/roles/:role", a.RevokeGroupRole, a.Authenticated, a.Require(rbac.RolesManage))
+	a.e.POST("/admin/invitations", a.CreateInvitation, a.Authenticated, a.Require(rbac.UsersWrite))
+	a.e.GET("/admin/invitations", a.GetInvitations, a.Authenticated, a.Require(rbac.UsersRead))
+	a.e.DELETE("/admin/invitations/:id", a.RevokeInvitation, a.Authenticated, a.Require(rbac.UsersWrite))
+	a.e.POST("/admin/oauth/clients", a.CreateOAuthClient, a.Authenticated, a.Require(rbac.ClientsManage))
+	a.e.GET("/admin/oauth/clients", a.GetOAuthClients, a.Authenticated, a.Require(rbac.ClientsManage))
+	a.e.DELETE("/admin/oauth/clients/:id", a.DeleteOAuthClient, a.Authenticated, a.Require(rbac.ClientsManage))
+	a.e.GET("/admin/audit", a.GetAuditLog, a.Authenticated, a.Require(rbac.AuditRead))
+	a.e.GET("/.well-known/openid-configuration", a.GetProviderMetadata)
+	a.e.GET("/oauth/jwks", a.GetJWKS)
+	a.e.GET("/oauth/authorize", a.Authorize)
+	a.e.GET("/oauth/consent", a.GetConsent, a.Authenticated, a.Interactive)
+	a.e.POST("/oauth/consent", a.Consent, a.Authenticated, a.Interactive, a.NotImpersonated)
+	a.e.POST("/oauth/token", a.Token)
+	a.e.GET("/oauth/userinfo", a.GetUserInfo)
+	a.e.POST("/oauth/userinfo", a.GetUserInfo)
 	a.e.GET("/jokes", a.GetJokes)
+	a.e.GET("/jokes/top", a.GetTopJokes)
+	a.e.GET("/jokes/daily", a.GetDailyJoke)
+	a.e.GET("/jokes/stream", a.StreamJokes)
+	a.e.POST("/jokes/:id/vote", a.VoteJoke, a.Authenticated)
+	a.e.POST("/jokes/submissions", a.SubmitJoke, a.Authenticated)
+	a.e.GET("/jokes/submissions", a.GetSubmissions, a.Authenticated, a.Require(rbac.JokesModerate))
+	a.e.POST("/jokes/submissions/:id/approve", a.ApproveSubmission, a.Authenticated, a.Require(rbac.JokesModerate))
+	a.e.POST("/jokes/submissions/:id/reject", a.RejectSubmission, a.Authenticated, a.Require(rbac.JokesModerate))
 }
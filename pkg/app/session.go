@@ -0,0 +1,135 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var errRevokedSession = errors.New("session revoked")
+
+// lastSeenPrecision is how often the last seen time of a session is updated,
+// so that not every request writes to the database.
+const lastSeenPrecision = time.Minute
+
+// SessionResponse marks the session the request was made with.
+type SessionResponse struct {
+	repo.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the devices the user is logged in on.
+func (a *App) GetSessions(c echo.Context) error {
+	if a.sessionRepo == nil {
+		return sessionsNotConfigured(c)
+	}
+	sessions, err := a.sessionRepo.FindByUser(userFrom(c).ID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list sessions", Error: err.Error()})
+	}
+	current := claimsFrom(c).Session
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == current})
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteSession logs the user out on one device, the access token of the
+// session is rejected from then on.
+func (a *App) DeleteSession(c echo.Context) error {
+	if a.sessionRepo == nil {
+		return sessionsNotConfigured(c)
+	}
+	err := a.sessionRepo.Delete(c.Param("id"), userFrom(c).ID)
+	if notFound(err) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Session not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke session", Error: err.Error()})
+	}
+	a.log.Info("Revoked session", zap.String("sessionId", c.Param("id")), zap.String("userId", userFrom(c).ID))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteSessions logs the user out everywhere, the current session included.
+func (a *App) DeleteSessions(c echo.Context) error {
+	if a.sessionRepo == nil {
+		return sessionsNotConfigured(c)
+	}
+	user := userFrom(c)
+	revoked, err := a.sessionRepo.DeleteByUser(user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke sessions", Error: err.Error()})
+	}
+	a.log.Info("Revoked all sessions", zap.String("userId", user.ID), zap.Int64("sessions", revoked))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// startSession records a login from the device of the request and returns
+// the ID of its session, which is empty when sessions are not configured.
+func (a *App) startSession(c echo.Context, user repo.User) (string, error) {
+	if a.sessionRepo == nil {
+		return "", nil
+	}
+	now := time.Now()
+	session, err := a.sessionRepo.Create(repo.Session{
+		TenantID:   tenantFrom(c),
+		UserID:     user.ID,
+		UserAgent:  c.Request().UserAgent(),
+		IP:         c.RealIP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(a.tokens.TTL()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+// checkSession reports errRevokedSession unless the session the claims name
+// still exists. Tokens issued before there were sessions name none and are
+// only revoked by a password change.
+func (a *App) checkSession(claims *token.Claims, at time.Time) error {
+	if claims.Session == "" || a.sessionRepo == nil {
+		return nil
+	}
+	session, err := a.sessionRepo.FindOne(claims.Session)
+	if notFound(err) {
+		return fmt.Errorf("%w: the session was logged out", errRevokedSession)
+	}
+	if err != nil {
+		return err
+	}
+	if session.UserID != claims.Subject || !at.Before(session.ExpiresAt) {
+		return fmt.Errorf("%w: the session was logged out", errRevokedSession)
+	}
+	if at.Sub(session.LastSeenAt) >= lastSeenPrecision {
+		if err := a.sessionRepo.Touch(session.ID, at); err != nil {
+			a.log.Warn("Failed to record session use", zap.String("sessionId", session.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// endSessions logs the user out everywhere after a password change, the
+// tokens are rejected anyway but should no longer be listed.
+func (a *App) endSessions(userID string) {
+	if a.sessionRepo == nil {
+		return
+	}
+	if _, err := a.sessionRepo.DeleteByUser(userID); err != nil {
+		a.log.Warn("Failed to revoke sessions", zap.String("userId", userID), zap.Error(err))
+	}
+}
+
+func sessionsNotConfigured(c echo.Context) error {
+	return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Sessions are not configured", Error: "missing session repository"})
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	bcrypt := password.NewBcrypt(4)
+	hash, err := bcrypt.Hash("1234567898", "")
+	require.NoError(t, err)
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", Password: &hash}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(user, nil).AnyTimes()
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	now := time.Now()
+	laptop := repo.Session{ID: "session-1", UserID: "user-1", UserAgent: "laptop", LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	phone := repo.Session{ID: "session-2", UserID: "user-1", UserAgent: "phone", LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	store := repo.NewMockSessionRepository(ctrl)
+	store.EXPECT().Create(gomock.Any()).DoAndReturn(func(session repo.Session) (repo.Session, error) {
+		require.Equal(t, "user-1", session.UserID)
+		require.Equal(t, "laptop", session.UserAgent)
+		session.ID = laptop.ID
+		return session, nil
+	})
+	gomock.InOrder(
+		store.EXPECT().FindOne(laptop.ID).Return(laptop, nil).Times(4),
+		store.EXPECT().FindOne(laptop.ID).Return(repo.Session{}, mongo.ErrNoDocuments),
+	)
+	store.EXPECT().FindOne(phone.ID).Return(repo.Session{}, mongo.ErrNoDocuments)
+	store.EXPECT().FindByUser("user-1", gomock.Any()).Return([]repo.Session{laptop, phone}, nil)
+	gomock.InOrder(
+		store.EXPECT().Delete(phone.ID, "user-1").Return(nil),
+		store.EXPECT().Delete(phone.ID, "user-1").Return(mongo.ErrNoDocuments),
+	)
+	store.EXPECT().DeleteByUser("user-1").Return(int64(1), nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)),
+		WithPasswordHasher(password.NewHasher(bcrypt)), WithSessionRepository(store))
+	request := func(method, path, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("User-Agent", "laptop")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	laptopToken := response.Token
+	phoneToken, err := testIssuer.IssueSession(user, phone.ID)
+	require.NoError(t, err)
+
+	// Assertions
+	rec = request(http.MethodGet, "/me/sessions", laptopToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var sessions []SessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 2)
+	require.Equal(t, "laptop", sessions[0].UserAgent)
+	require.True(t, sessions[0].Current)
+	require.Equal(t, "phone", sessions[1].UserAgent)
+	require.False(t, sessions[1].Current)
+
+	require.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/me/sessions/"+phone.ID, laptopToken).Code)
+	require.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/me/sessions", phoneToken).Code)
+	require.Equal(t, http.StatusNotFound, request(http.MethodDelete, "/me/sessions/"+phone.ID, laptopToken).Code)
+
+	// Logging out everywhere revokes the current session as well
+	require.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/me/sessions", laptopToken).Code)
+	require.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/me/sessions", laptopToken).Code)
+
+}
+
+func TestSessionLastSeen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	now := time.Now()
+	seen := repo.Session{ID: "session-1", UserID: "user-1", LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	other := repo.Session{ID: "session-2", UserID: "user-2", LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	store := repo.NewMockSessionRepository(ctrl)
+	store.EXPECT().FindOne(seen.ID).Return(seen, nil)
+	store.EXPECT().FindOne(other.ID).Return(other, nil)
+	store.EXPECT().Touch(seen.ID, gomock.Any()).DoAndReturn(func(_ string, at time.Time) error {
+		require.WithinDuration(t, time.Now(), at, time.Second)
+		return nil
+	})
+	store.EXPECT().FindByUser("user-1", gomock.Any()).Return([]repo.Session{seen}, nil)
+	e := echo.New()
+	NewApp(e, usersWithRoles(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSessionRepository(store))
+
+	// Assertions
+	accessToken, err := testIssuer.IssueSession(repo.User{ID: "user-1"}, seen.ID)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The session of another user is not accepted
+	accessToken, err = testIssuer.IssueSession(repo.User{ID: "user-1"}, other.ID)
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestSessionsNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	req := httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+
+	// Assertions
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+}
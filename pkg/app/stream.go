@@ -0,0 +1,87 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/labstack/echo/v4"
+)
+
+var StreamHeartbeat = 15 * time.Second
+
+type StreamSummary struct {
+	Requested int    `json:"requested"`
+	Delivered int    `json:"delivered"`
+	Failed    int    `json:"failed"`
+	Duration  string `json:"duration"`
+}
+
+// StreamJokes sends jokes as server-sent events as soon as each one is
+// fetched, followed by a summary event. Comments are sent as heartbeats while
+// waiting and outstanding fetches are cancelled when the client goes away.
+func (a *App) StreamJokes(c echo.Context) error {
+	client, err := a.jokeClient(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid safe parameter", Error: err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	start := time.Now()
+	results := joke.Stream(ctx, client, a.jokeLimit)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+
+	summary := StreamSummary{Requested: a.jokeLimit}
+	served := []joke.Joke{}
+	defer func() {
+		a.saveServed(served)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case result, ok := <-results:
+			if !ok {
+				summary.Duration = time.Since(start).String()
+				return writeEvent(res, "summary", summary)
+			}
+			if result.Err != nil {
+				summary.Failed++
+				continue
+			}
+			summary.Delivered++
+			served = append(served, result.Joke)
+			if err := writeEvent(res, "joke", result.Joke); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(res *echo.Response, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/joke"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestStreamJokes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/stream", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	jokeClient := joke.NewMockJokeClient(ctrl)
+	jokeClient.EXPECT().GetJokeContext(gomock.Any()).Return(joke.Joke{ID: "joke-1", Value: "Chuck Norris"}, nil).Times(JokeLimit - 1)
+	jokeClient.EXPECT().GetJokeContext(gomock.Any()).Return(joke.Joke{}, errors.New("error"))
+	jokeRepo := repo.NewMockJokeRepository(ctrl)
+	jokeRepo.EXPECT().Save(gomock.Len(JokeLimit - 1)).Return(nil)
+	app := NewApp(e, nil, zap.NewNop(), jokeClient, WithJokeRepository(jokeRepo))
+	err := app.StreamJokes(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	body := rec.Body.String()
+	require.Equal(t, JokeLimit-1, strings.Count(body, "event: joke\n"))
+	require.Contains(t, body, `data: {"categories":null,"icon_url":"","id":"joke-1","url":"","value":"Chuck Norris"}`)
+	require.Contains(t, body, `event: summary`)
+	require.Contains(t, body, `"delivered":9,"failed":1`)
+
+}
+
+func TestStreamJokesLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/stream", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	jokeClient := joke.NewMockJokeClient(ctrl)
+	jokeClient.EXPECT().GetJokeContext(gomock.Any()).Return(joke.Joke{ID: "joke-1", Value: "Chuck Norris"}, nil).Times(3)
+	jokeRepo := repo.NewMockJokeRepository(ctrl)
+	jokeRepo.EXPECT().Save(gomock.Len(3)).Return(nil)
+	app := NewApp(e, nil, zap.NewNop(), jokeClient, WithJokeRepository(jokeRepo), WithJokeLimit(3))
+	err := app.StreamJokes(c)
+	require.NoError(t, err)
+	body := rec.Body.String()
+	require.Equal(t, 3, strings.Count(body, "event: joke\n"))
+	require.Contains(t, body, `"requested":3,"delivered":3,"failed":0`)
+
+}
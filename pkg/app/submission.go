@@ -0,0 +1,116 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+)
+
+var SubmissionPageLimit = 100
+
+type SubmitJokeRequest struct {
+	Value      string   `json:"value" validate:"required,max=1000"`
+	Categories []string `json:"categories" validate:"max=3,dive,required,max=30"`
+}
+
+type ModerateRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+func (a *App) SubmitJoke(c echo.Context) error {
+	submitRequest := new(SubmitJokeRequest)
+	if err := c.Bind(submitRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(submitRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+
+	submission, err := a.submissionRepo.Create(repo.Submission{
+		TenantID:   tenantFrom(c),
+		UserID:     claimsFrom(c).Subject,
+		Value:      submitRequest.Value,
+		Categories: submitRequest.Categories,
+		Status:     repo.SubmissionPending,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save submission", Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, submission)
+}
+
+// GetSubmissions lists the submissions of the tenant by ?status= (pending by default) with ?offset= and ?limit= paging.
+func (a *App) GetSubmissions(c echo.Context) error {
+	status := repo.SubmissionStatus(c.QueryParam("status"))
+	switch status {
+	case "":
+		status = repo.SubmissionPending
+	case repo.SubmissionPending, repo.SubmissionApproved, repo.SubmissionRejected:
+	default:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid status", Error: "status must be pending, approved or rejected"})
+	}
+	offset, limit, err := pagination(c, SubmissionPageLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid pagination", Error: err.Error()})
+	}
+
+	submissions, err := a.submissionRepo.FindByStatus(tenantFrom(c), status, offset, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to list submissions", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, submissions)
+}
+
+func (a *App) ApproveSubmission(c echo.Context) error {
+	return a.moderateSubmission(c, repo.SubmissionApproved)
+}
+
+func (a *App) RejectSubmission(c echo.Context) error {
+	return a.moderateSubmission(c, repo.SubmissionRejected)
+}
+
+func (a *App) moderateSubmission(c echo.Context, status repo.SubmissionStatus) error {
+	moderateRequest := new(ModerateRequest)
+	if err := c.Bind(moderateRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(moderateRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+
+	id := c.Param("id")
+	submission, err := a.submissionRepo.Moderate(id, tenantFrom(c), status, claimsFrom(c).Subject, moderateRequest.Reason)
+	if notFound(err) {
+		// Either the submission does not exist or it was moderated already
+		if _, findErr := a.submissionRepo.FindOne(id, tenantFrom(c)); findErr == nil {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "Submission already moderated", Error: err.Error()})
+		}
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Submission not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to moderate submission", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, submission)
+}
+
+// pagination reads the offset and limit query parameters, limit defaults to
+// and may not exceed max.
+func pagination(c echo.Context, max int) (offset, limit int, err error) {
+	limit = max
+	if o := c.QueryParam("offset"); o != "" {
+		if offset, err = strconv.Atoi(o); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative number")
+		}
+	}
+	if l := c.QueryParam("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > max {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(max))
+		}
+	}
+	return offset, limit, nil
+}
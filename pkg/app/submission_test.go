@@ -0,0 +1,151 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestSubmitJoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/submissions", strings.NewReader(`{"value": "Chuck Norris can unit test entire applications with a single assert."}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "user-1", Email: "fo@bo.com"})
+	rec := httptest.NewRecorder()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(submission repo.Submission) (repo.Submission, error) {
+		require.Equal(t, "user-1", submission.UserID)
+		require.Equal(t, repo.SubmissionPending, submission.Status)
+		require.Equal(t, repo.DefaultTenant, submission.TenantID)
+		submission.ID = "submission-1"
+		return submission, nil
+	})
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Contains(t, rec.Body.String(), `"status":"pending"`)
+
+}
+
+func TestSubmitJoke400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/submissions", strings.NewReader(`{"value": ""}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "user-1", Email: "fo@bo.com"})
+	rec := httptest.NewRecorder()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(repo.NewMockSubmissionRepository(ctrl)))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestGetSubmissionsRequiresAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/submissions", nil)
+	authorize(t, req, repo.User{ID: "user-1", Email: "fo@bo.com"})
+	rec := httptest.NewRecorder()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(repo.NewMockSubmissionRepository(ctrl)))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+}
+
+func TestGetSubmissions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/submissions?status=approved&offset=10&limit=5", nil)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().FindByStatus(repo.DefaultTenant, repo.SubmissionApproved, 10, 5).Return([]repo.Submission{{ID: "submission-1"}}, nil)
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleModerator), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
+
+func TestGetSubmissionsTenant(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/jokes/submissions", nil)
+	authorize(t, req, repo.User{ID: "admin-1", TenantID: "acme", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	tenants := repo.NewMockUserTenants(ctrl)
+	tenants.EXPECT().ForTenant("acme").Return(usersWithRoles(ctrl, rbac.RoleModerator)).AnyTimes()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	// Moderators only see the submissions of their tenant
+	submissionRepo.EXPECT().FindByStatus("acme", repo.SubmissionPending, 0, SubmissionPageLimit).Return([]repo.Submission{}, nil)
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo),
+		WithTenancy(Tenancy{Users: tenants, Tenants: []string{"acme"}, Header: "X-Tenant-ID"}))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
+
+func TestApproveSubmission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/submissions/submission-1/approve", nil)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().Moderate("submission-1", repo.DefaultTenant, repo.SubmissionApproved, "admin-1", "").
+		Return(repo.Submission{ID: "submission-1", Status: repo.SubmissionApproved}, nil)
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleModerator), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
+
+func TestRejectSubmission409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/submissions/submission-1/reject", strings.NewReader(`{"reason": "not funny"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().Moderate("submission-1", repo.DefaultTenant, repo.SubmissionRejected, "admin-1", "not funny").Return(repo.Submission{}, mongo.ErrNoDocuments)
+	submissionRepo.EXPECT().FindOne("submission-1", repo.DefaultTenant).Return(repo.Submission{ID: "submission-1", Status: repo.SubmissionApproved}, nil)
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleModerator), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+}
+
+func TestApproveSubmission404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jokes/submissions/not-an-id/approve", strings.NewReader(`{}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().Moderate("not-an-id", repo.DefaultTenant, repo.SubmissionApproved, "admin-1", "").Return(repo.Submission{}, primitive.ErrInvalidHex)
+	submissionRepo.EXPECT().FindOne("not-an-id", repo.DefaultTenant).Return(repo.Submission{}, primitive.ErrInvalidHex)
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleModerator), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithSubmissionRepository(submissionRepo))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+)
+
+// Tenant resolves the tenant of a request from the tenant header, requests
+// without one belong to the default tenant. Authenticated replaces it with
+// the tenant of the access token.
+func (a *App) Tenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tenantID := c.Request().Header.Get(a.tenancy.Header)
+		if tenantID == "" {
+			tenantID = repo.DefaultTenant
+		}
+		if !a.knownTenant(tenantID) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Unknown tenant", Error: "unknown tenant " + tenantID})
+		}
+		c.Set(tenantKey, tenantID)
+		return next(c)
+	}
+}
+
+func (a *App) knownTenant(tenantID string) bool {
+	if tenantID == repo.DefaultTenant {
+		return true
+	}
+	if a.tenancy.Users == nil {
+		return false
+	}
+	for _, known := range a.tenancy.Tenants {
+		if known == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// users returns the repository of the users of the request's tenant, handlers
+// never see the users of other tenants.
+func (a *App) users(c echo.Context) repo.UserRepository {
+	tenantID := tenantFrom(c)
+	if tenantID == repo.DefaultTenant {
+		return a.userRepo
+	}
+	return a.tenancy.Users.ForTenant(tenantID)
+}
+
+func tenantFrom(c echo.Context) string {
+	tenantID, _ := c.Get(tenantKey).(string)
+	if tenantID == "" {
+		return repo.DefaultTenant
+	}
+	return tenantID
+}
+
+// account qualifies an email with the tenant of the request for the login
+// limits, as emails are only unique per tenant.
+func account(c echo.Context, email string) string {
+	if tenantID := tenantFrom(c); email != "" && tenantID != repo.DefaultTenant {
+		return tenantID + "/" + email
+	}
+	return email
+}
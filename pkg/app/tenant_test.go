@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestTenantLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	hash, err := password.NewDefaultHasher().Hash("1234567898")
+	require.NoError(t, err)
+	acmeUsers := repo.NewMockUserRepository(ctrl)
+	acmeUsers.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1", TenantID: "acme", Email: "fo@bo.com", Password: &hash}, nil)
+	tenants := repo.NewMockUserTenants(ctrl)
+	tenants.EXPECT().ForTenant("acme").Return(acmeUsers)
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	// Failures are counted per tenant, as emails are only unique per tenant
+	attempts.EXPECT().RecordFailure("account:acme/fo@bo.com", gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil)
+	attempts.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil)
+	attempts.EXPECT().Reset("account:acme/fo@bo.com").Return(nil)
+	attempts.EXPECT().Forgive(gomock.Any()).Return(nil)
+	e := echo.New()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer),
+		WithLockout(lockout.NewLimiter(attempts, lockout.DefaultPolicy)), WithTenancy(Tenancy{Users: tenants, Tenants: []string{"acme"}, Header: "X-Tenant-ID"}))
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Tenant-ID", "acme")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	claims, err := testIssuer.Verify(login.Token)
+	require.NoError(t, err)
+	require.Equal(t, "acme", claims.Tenant)
+
+}
+
+func TestTenantFromToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	acmeUsers := repo.NewMockUserRepository(ctrl)
+	acmeUsers.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1", TenantID: "acme"}, nil).Times(2)
+	tenants := repo.NewMockUserTenants(ctrl)
+	tenants.EXPECT().ForTenant("acme").Return(acmeUsers).Times(2)
+	e := echo.New()
+	app := NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer),
+		WithTenancy(Tenancy{Users: tenants, Tenants: []string{"acme", "globex"}, Header: "X-Tenant-ID"}))
+	handler := func(c echo.Context) error {
+		require.Equal(t, "acme", tenantFrom(c))
+		return c.NoContent(http.StatusNoContent)
+	}
+	get := func(tenantID string) int {
+		req := httptest.NewRequest(http.MethodGet, "/password-policy", nil)
+		if tenantID != "" {
+			req.Header.Set("X-Tenant-ID", tenantID)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		authorize(t, req, repo.User{ID: "user-1", TenantID: "acme"})
+		require.NoError(t, app.Tenant(app.Authenticated(handler))(c))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, get(""))
+	require.Equal(t, http.StatusNoContent, get("acme"))
+	// A token can not be used for another tenant
+	require.Equal(t, http.StatusUnauthorized, get("globex"))
+	require.Equal(t, http.StatusBadRequest, get("initech"))
+
+}
+
+func TestTenantUnknown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Tenant-ID", "acme")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Tokens of tenants that are no longer served are rejected
+	req = httptest.NewRequest(http.MethodPost, "/me/totp", nil)
+	authorize(t, req, repo.User{ID: "user-1", TenantID: "acme"})
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/secret"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/pkg/totp"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// MFAChallengeTTL is how long the second login step can be completed after the password was verified.
+	MFAChallengeTTL = 5 * time.Minute
+	// RecoveryCodeCount is the number of recovery codes handed out when TOTP is enabled.
+	RecoveryCodeCount = 10
+	// QRCodeSize is the width and height of the enrollment QR code in pixels.
+	QRCodeSize = 256
+)
+
+// TwoFactor configures TOTP two-factor authentication.
+type TwoFactor struct {
+	// Issuer names the service in authenticator apps.
+	Issuer string
+	// Box encrypts the TOTP secrets stored with the users, enrollment is
+	// disabled without it.
+	Box *secret.Box
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	// Code is either a TOTP code or a recovery code.
+	Code string `json:"code" validate:"required"`
+}
+
+// EnrollTOTP creates a TOTP secret for the logged in user, it is only used
+// once confirmed with a first code.
+func (a *App) EnrollTOTP(c echo.Context) error {
+	if a.twoFactor.Box == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Two-factor authentication is not configured", Error: "missing TOTP encryption key"})
+	}
+	user := userFrom(c)
+	if user.TOTPEnabled {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Two-factor authentication is already enabled", Error: "disable it first"})
+	}
+	plain, err := totp.GenerateSecret()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to generate TOTP secret", Error: err.Error()})
+	}
+	sealed, err := a.twoFactor.Box.Seal([]byte(plain), []byte(user.ID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to encrypt TOTP secret", Error: err.Error()})
+	}
+	if err := a.users(c).SetTOTPSecret(user.ID, sealed); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "Two-factor authentication is already enabled", Error: "disable it first"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to store TOTP secret", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, TOTPEnrollmentResponse{Secret: plain, URI: totp.URI(a.twoFactor.Issuer, user.Email, plain)})
+}
+
+// GetTOTPQRCode returns the QR code of the unconfirmed TOTP secret as a PNG image.
+func (a *App) GetTOTPQRCode(c echo.Context) error {
+	user := userFrom(c)
+	if user.TOTPSecret == "" || user.TOTPEnabled {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "No pending TOTP enrollment", Error: "enroll first"})
+	}
+	plain, err := a.totpSecret(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to decrypt TOTP secret", Error: err.Error()})
+	}
+	image, err := totp.QRCode(totp.URI(a.twoFactor.Issuer, user.Email, plain), QRCodeSize)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create QR code", Error: err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, "image/png", image)
+}
+
+// ConfirmTOTP enables TOTP with a first valid code and hands out the recovery
+// codes, which are only stored as hashes.
+func (a *App) ConfirmTOTP(c echo.Context) error {
+	codeRequest := new(TOTPCodeRequest)
+	if err := c.Bind(codeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(codeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	user := userFrom(c)
+	if user.TOTPSecret == "" || user.TOTPEnabled {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "No pending TOTP enrollment", Error: "enroll first"})
+	}
+	plain, err := a.totpSecret(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to decrypt TOTP secret", Error: err.Error()})
+	}
+	counter, err := totp.Validate(plain, codeRequest.Code, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid code", Error: err.Error()})
+	}
+	codes, err := totp.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to generate recovery codes", Error: err.Error()})
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = token.HashOpaque(totp.NormalizeRecoveryCode(code))
+	}
+	if err := a.users(c).EnableTOTP(user.ID, counter, hashes); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "No pending TOTP enrollment", Error: "enroll first"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to enable TOTP", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP turns two-factor authentication off after checking a TOTP or recovery code.
+func (a *App) DisableTOTP(c echo.Context) error {
+	codeRequest := new(TOTPCodeRequest)
+	if err := c.Bind(codeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(codeRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	user := userFrom(c)
+	if !user.TOTPEnabled {
+		return c.JSON(http.StatusConflict, ErrorResponse{Message: "Two-factor authentication is not enabled", Error: "enroll first"})
+	}
+
+	// Guessing codes with a stolen access token counts towards the lockout
+	ip := c.RealIP()
+	wait, err := a.lockout.Check(account(c, user.Email), ip)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check login attempts", Error: err.Error()})
+	}
+	if wait > 0 {
+		return tooManyRequests(c, wait, "Too many failed login attempts")
+	}
+	valid, err := a.verifySecondFactor(c, user, codeRequest.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to verify code", Error: err.Error()})
+	}
+	if !valid {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Invalid code", Error: "Invalid code"})
+	}
+	if err := a.lockout.Forgive(account(c, user.Email), ip); err != nil {
+		a.log.Warn("Failed to forgive login attempt", zap.Error(err))
+	}
+	if err := a.users(c).DisableTOTP(user.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to disable TOTP", Error: err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// LoginMFA completes a login of a user with two-factor authentication,
+// exchanging the challenge token handed out by Login and a valid code for an
+// access token.
+func (a *App) LoginMFA(c echo.Context) error {
+	mfaRequest := new(MFALoginRequest)
+	if err := c.Bind(mfaRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(mfaRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	claims, err := a.tokens.VerifyOneTime(mfaRequest.MFAToken, token.PurposeMFA)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid MFA token", Error: err.Error()})
+	}
+	user, err := a.users(c).FindOne(claims.Subject)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid MFA token", Error: "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+
+	ip := c.RealIP()
+	wait, err := a.lockout.Check(account(c, user.Email), ip)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check login attempts", Error: err.Error()})
+	}
+	if wait > 0 {
+		a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginPassword, Outcome: repo.LoginLocked})
+		return tooManyRequests(c, wait, "Too many failed login attempts")
+	}
+	valid, err := a.verifySecondFactor(c, user, mfaRequest.Code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to verify code", Error: err.Error()})
+	}
+	if !valid {
+		a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginPassword, Outcome: repo.LoginFailed, MFA: true})
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid code", Error: "Invalid code"})
+	}
+	// The challenge is only used up by a valid code, so that a typo does not
+	// require entering the password again.
+	if _, err := a.tokenRepo.Consume(claims.ID, token.PurposeMFA, time.Now()); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid MFA token", Error: "token was already used"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to consume MFA token", Error: err.Error()})
+	}
+	if err := a.lockout.Success(account(c, user.Email), ip); err != nil {
+		a.log.Warn("Failed to reset failed logins", zap.Error(err))
+	}
+	a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginPassword, Outcome: repo.LoginSucceeded, MFA: true})
+	return a.issueToken(c, user)
+}
+
+// mfaChallenge answers a login with a correct password of a user with
+// two-factor authentication with a single-use token for the second step.
+func (a *App) mfaChallenge(c echo.Context, user repo.User) error {
+	signed, claims, err := a.tokens.IssueOneTime(user.ID, token.PurposeMFA, MFAChallengeTTL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to issue MFA token", Error: err.Error()})
+	}
+	err = a.tokenRepo.Create(repo.OneTimeToken{
+		ID:        claims.ID,
+		UserID:    user.ID,
+		Purpose:   token.PurposeMFA,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to store MFA token", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, LoginResponse{MFARequired: true, MFAToken: signed})
+}
+
+// verifySecondFactor checks a TOTP code, which can not be used twice, or uses
+// up a recovery code.
+func (a *App) verifySecondFactor(c echo.Context, user repo.User, code string) (bool, error) {
+	var err error
+	if totp.IsCode(code) {
+		err = a.useTOTPCode(c, user, code)
+	} else {
+		err = a.users(c).UseRecoveryCode(user.ID, token.HashOpaque(totp.NormalizeRecoveryCode(code)))
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, totp.ErrInvalidCode) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// useTOTPCode returns mongo.ErrNoDocuments when a valid code was already used.
+func (a *App) useTOTPCode(c echo.Context, user repo.User, code string) error {
+	plain, err := a.totpSecret(user)
+	if err != nil {
+		return err
+	}
+	counter, err := totp.Validate(plain, code, time.Now())
+	if err != nil {
+		return err
+	}
+	return a.users(c).UseTOTPCounter(user.ID, counter)
+}
+
+func (a *App) totpSecret(user repo.User) (string, error) {
+	if a.twoFactor.Box == nil {
+		return "", errors.New("missing TOTP encryption key")
+	}
+	plain, err := a.twoFactor.Box.Open(user.TOTPSecret, []byte(user.ID))
+	return string(plain), err
+}
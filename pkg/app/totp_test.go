@@ -0,0 +1,242 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/secret"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/pkg/totp"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func testBox(t *testing.T) *secret.Box {
+	box, err := secret.NewBox(bytes.Repeat([]byte{7}, 32))
+	require.NoError(t, err)
+	return box
+}
+
+// sealedUser returns a user with the given TOTP secret sealed by box.
+func sealedUser(t *testing.T, box *secret.Box, plain string, enabled bool) repo.User {
+	sealed, err := box.Seal([]byte(plain), []byte("user-1"))
+	require.NoError(t, err)
+	return repo.User{ID: "user-1", Email: "fo@bo.com", TOTPSecret: sealed, TOTPEnabled: enabled}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	var sealed string
+	db.EXPECT().SetTOTPSecret("user-1", gomock.Any()).DoAndReturn(func(id, s string) error {
+		sealed = s
+		return nil
+	})
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	req := httptest.NewRequest(http.MethodPost, "/me/totp", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var enrollment TOTPEnrollmentResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &enrollment))
+	require.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/go-user:fo@bo.com?"))
+	// Only the encrypted secret is stored
+	require.NotContains(t, sealed, enrollment.Secret)
+	opened, err := box.Open(sealed, []byte("user-1"))
+	require.NoError(t, err)
+	require.Equal(t, enrollment.Secret, string(opened))
+
+}
+
+func TestEnrollTOTPNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	req := httptest.NewRequest(http.MethodPost, "/me/totp", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+}
+
+func TestGetTOTPQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(sealedUser(t, box, "JBSWY3DPEHPK3PXP", false), nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	req := httptest.NewRequest(http.MethodGet, "/me/totp/qr", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	_, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	plain, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(sealedUser(t, box, plain, false), nil).Times(2)
+	var hashes []string
+	db.EXPECT().EnableTOTP("user-1", gomock.Any(), gomock.Len(RecoveryCodeCount)).DoAndReturn(func(id string, counter int64, codes []string) error {
+		hashes = codes
+		return nil
+	})
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	confirm := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/me/totp/confirm", strings.NewReader(`{"code": "`+code+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "user-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusBadRequest, confirm("000000").Code)
+	code, err := totp.Code(plain, time.Now())
+	require.NoError(t, err)
+	rec := confirm(code)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var recovery RecoveryCodesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recovery))
+	require.Len(t, recovery.RecoveryCodes, RecoveryCodeCount)
+	require.Equal(t, token.HashOpaque(totp.NormalizeRecoveryCode(recovery.RecoveryCodes[0])), hashes[0])
+
+}
+
+func TestLoginMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	plain, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	user := sealedUser(t, box, plain, true)
+	hash, err := password.NewDefaultHasher().Hash("1234567898")
+	require.NoError(t, err)
+	user.Password = &hash
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(user, nil)
+	db.EXPECT().FindOne("user-1").Return(user, nil).Times(3)
+	db.EXPECT().UseTOTPCounter("user-1", gomock.Any()).Return(nil)
+	db.EXPECT().UseRecoveryCode("user-1", token.HashOpaque("abcdefghij")).Return(mongo.ErrNoDocuments)
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().Create(gomock.Any()).Return(nil)
+	tokens.EXPECT().Consume(gomock.Any(), token.PurposeMFA, gomock.Any()).Return(repo.OneTimeToken{}, nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)),
+		WithOneTimeTokenRepository(tokens), WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The password alone gives no access token
+	rec := post("/login", `{"email": "fo@bo.com", "password": "1234567898"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var challenge LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &challenge))
+	require.True(t, challenge.MFARequired)
+	require.Empty(t, challenge.Token)
+	require.NotEmpty(t, challenge.MFAToken)
+
+	rec = post("/login/mfa", `{"mfaToken": "`+challenge.MFAToken+`", "code": "000000"}`)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = post("/login/mfa", `{"mfaToken": "`+challenge.MFAToken+`", "code": "ABCDE-FGHIJ"}`)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	code, err := totp.Code(plain, time.Now())
+	require.NoError(t, err)
+	rec = post("/login/mfa", `{"mfaToken": "`+challenge.MFAToken+`", "code": "`+code+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	claims, err := testIssuer.Verify(login.Token)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+
+}
+
+func TestDisableTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(sealedUser(t, box, "JBSWY3DPEHPK3PXP", true), nil)
+	db.EXPECT().UseRecoveryCode("user-1", token.HashOpaque("abcdefghij")).Return(nil)
+	db.EXPECT().DisableTOTP("user-1").Return(nil)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)), WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	req := httptest.NewRequest(http.MethodDelete, "/me/totp", strings.NewReader(`{"code": "abcde-fghij"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+}
+
+func TestDisableTOTPLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	box := testBox(t)
+	user := sealedUser(t, box, "JBSWY3DPEHPK3PXP", true)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).Times(2)
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	gomock.InOrder(
+		attempts.EXPECT().RecordFailure("account:"+user.Email, gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil),
+		attempts.EXPECT().RecordFailure("account:"+user.Email, gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 11, PreviousFailure: time.Now().Add(-810 * time.Second)}, nil),
+	)
+	attempts.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil).Times(2)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(lockout.NewLimiter(attempts, lockout.DefaultPolicy)),
+		WithTwoFactor(TwoFactor{Issuer: "go-user", Box: box}))
+	disable := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/me/totp", strings.NewReader(`{"code": "000000"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		authorize(t, req, repo.User{ID: "user-1"})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Assertions
+	require.Equal(t, http.StatusForbidden, disable().Code)
+	rec := disable()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "90", rec.Header().Get(echo.HeaderRetryAfter))
+
+}
@@ -1,23 +1,33 @@
 package app
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Davut97/go-user/repo"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 )
 
 type CreateUser struct {
 	Email     string `json:"email" validate:"required,email"`
 	FirstName string `json:"firstName" validate:"required"`
 	LastName  string `json:"lastName" validate:"required"`
-	Password  string `json:"password" validate:"required,min=8"`
+	Password  string `json:"password" validate:"required"`
 }
 
+// CreateUserResponse is the same whether or not the email was already
+// registered, so that signing up does not reveal which emails have accounts.
 type CreateUserResponse struct {
-	ID string `json:"id"`
+	Message string `json:"message"`
 }
 
+const signupAccepted = "If the email is not registered yet, the account has been created and a verification link has been sent"
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 	Error   string `json:"error"`
@@ -28,7 +38,18 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse carries the access token, or the token for the second login
+// step when MFARequired is set.
+type LoginResponse struct {
+	Token       string `json:"token,omitempty"`
+	MFARequired bool   `json:"mfaRequired,omitempty"`
+	MFAToken    string `json:"mfaToken,omitempty"`
+}
+
 func (a *App) CreateUser(c echo.Context) error {
+	if a.registration.InviteOnly {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Registration is closed", Error: "accounts are only created by invitation"})
+	}
 	user := new(CreateUser)
 	if err := c.Bind(user); err != nil {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
@@ -36,20 +57,45 @@ func (a *App) CreateUser(c echo.Context) error {
 	if err := c.Validate(user); err != nil {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
 	}
-	newUser := repo.User{
+	if err := a.checkPassword(user.Password, user.Email, user.FirstName, user.LastName); err != nil {
+		return passwordError(c, err)
+	}
+	_, err := a.createAccount(c, repo.User{
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Password:  &user.Password,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		a.log.Info("Signup with registered email", zap.String("email", user.Email))
+		a.sendSignupNotice(user.Email)
+		return c.JSON(http.StatusAccepted, CreateUserResponse{Message: signupAccepted})
 	}
-
-	// TODO: Check if user already exists
-	createdUser, err := a.userRepo.Create(newUser)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create user", Error: err.Error()})
 	}
 
-	return c.JSON(http.StatusCreated, CreateUserResponse{ID: createdUser.ID})
+	return c.JSON(http.StatusAccepted, CreateUserResponse{Message: signupAccepted})
+}
+
+// createAccount creates a user whose password was checked against the policy
+// already and sends a verification link unless the email is verified.
+func (a *App) createAccount(c echo.Context, user repo.User) (repo.User, error) {
+	// Create hashes the password before the unique email index rejects a
+	// duplicate, so both cases take about as long.
+	createdUser, err := a.users(c).Create(user)
+	if err != nil {
+		return repo.User{}, err
+	}
+	a.log.Info("User created", zap.String("userId", createdUser.ID))
+	if createdUser.EmailVerified {
+		return createdUser, nil
+	}
+	// The user can ask for another link, so a failure does not fail the signup
+	if err := a.sendVerification(createdUser); err != nil {
+		a.log.Warn("Failed to send verification email", zap.String("userId", createdUser.ID), zap.Error(err))
+	}
+	return createdUser, nil
 }
 
 func (a *App) Login(c echo.Context) error {
@@ -61,16 +107,86 @@ func (a *App) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
 	}
 
-	user, err := a.userRepo.FindByEmail(loginRequest.Email)
-	// TODO: Check if user not found
+	ip := c.RealIP()
+	wait, err := a.lockout.Check(account(c, loginRequest.Email), ip)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found", Error: err.Error()})
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to check login attempts", Error: err.Error()})
+	}
+	if wait > 0 {
+		a.recordLogin(c, repo.User{}, repo.LoginEvent{Email: loginRequest.Email, Method: repo.LoginPassword, Outcome: repo.LoginLocked})
+		return tooManyRequests(c, wait, "Too many failed login attempts")
 	}
 
-	if !repo.CheckPasswordHash(loginRequest.Password, *user.Password) {
+	// Unknown emails and wrong passwords get the same response, take as long
+	// and count the same towards the lockout, so neither reveals whether an
+	// account exists.
+	user, err := a.users(c).FindByEmail(loginRequest.Email)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	}
+	match := false
+	rehash := false
+	if err == nil {
+		match, rehash, err = a.hasher.Verify(loginRequest.Password, *user.Password)
+		if err != nil {
+			a.log.Warn("Failed to verify password hash", zap.String("userId", user.ID), zap.Error(err))
+		}
+	} else if err := a.hasher.VerifyDummy(loginRequest.Password); err != nil {
+		a.log.Warn("Failed to verify dummy password hash", zap.Error(err))
+	}
+	if !match {
+		a.recordLogin(c, user, repo.LoginEvent{Email: loginRequest.Email, Method: repo.LoginPassword, Outcome: repo.LoginFailed})
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid credentials", Error: "Invalid credentials"})
 	}
-	// TODO: Generate JWT token
-	return c.JSON(http.StatusOK, nil)
+	// Only told after the password matched, so it does not reveal whether an account exists
+	if a.verification.Required && !user.EmailVerified {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Email not verified", Error: "verify your email before logging in"})
+	}
+	if rehash {
+		// Update hashes the plain password again with the current algorithm and parameters
+		user.Password = &loginRequest.Password
+		if _, err := a.users(c).Update(user); err != nil {
+			a.log.Warn("Failed to rehash password", zap.String("userId", user.ID), zap.Error(err))
+		}
+	}
+	// Failures are only forgotten once the second factor was verified too,
+	// otherwise every correct password would allow guessing further codes.
+	if user.TOTPEnabled {
+		if err := a.lockout.Forgive(account(c, loginRequest.Email), ip); err != nil {
+			a.log.Warn("Failed to forgive login attempt", zap.Error(err))
+		}
+		a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginPassword, Outcome: repo.LoginMFARequired})
+		return a.mfaChallenge(c, user)
+	}
+	if err := a.lockout.Success(account(c, loginRequest.Email), ip); err != nil {
+		a.log.Warn("Failed to reset failed logins", zap.Error(err))
+	}
+	a.recordLogin(c, user, repo.LoginEvent{Method: repo.LoginPassword, Outcome: repo.LoginSucceeded})
+	return a.issueToken(c, user)
+
+}
+
+type UnlockRequest struct {
+	Email string `json:"email" validate:"required_without=IP,omitempty,email"`
+	IP    string `json:"ip" validate:"required_without=Email,omitempty,ip"`
+}
+
+// Unlock lets an administrator lift the login lockout of an account or IP address.
+func (a *App) Unlock(c echo.Context) error {
+	unlockRequest := new(UnlockRequest)
+	if err := c.Bind(unlockRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(unlockRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := a.lockout.Unlock(account(c, unlockRequest.Email), unlockRequest.IP); err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to unlock", Error: err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
 
+func tooManyRequests(c echo.Context, wait time.Duration, message string) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.JSON(http.StatusTooManyRequests, ErrorResponse{Message: message, Error: message})
 }
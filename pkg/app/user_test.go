@@ -6,10 +6,17 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Davut97/go-user/pkg/lockout"
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/rbac"
+	"github.com/Davut97/go-user/pkg/token"
 	"github.com/Davut97/go-user/repo"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 )
@@ -24,11 +31,43 @@ func TestCreateUser(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	db := repo.NewMockUserRepository(ctrl)
-	db.EXPECT().Create(gomock.Any()).Return(repo.User{}, nil)
-	app := NewApp(e, db, nil, nil)
+	db.EXPECT().Create(gomock.Any()).Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().Create(gomock.Any()).Return(nil)
+	mailer := mail.NewMockSender(ctrl)
+	mailer.EXPECT().Send(gomock.Any()).Return(nil)
+	app := NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOneTimeTokenRepository(tokens), WithMailer(mailer))
 	err := app.CreateUser(c)
 	require.NoError(t, err)
-	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+}
+
+func TestCreateUserExisting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	userJson := `{"email": "fo@bo.com", "firstName": "Foo", "lastName": "Bar", "password": "1234567898"}`
+	e := echo.New()
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().Create(gomock.Any()).Return(repo.User{ID: "user-1"}, nil)
+	db.EXPECT().Create(gomock.Any()).Return(repo.User{}, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}})
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().Create(gomock.Any()).Return(nil)
+	mailer := mail.NewMockSender(ctrl)
+	// The owner of the registered email is told about the attempt instead
+	mailer.EXPECT().Send(gomock.Any()).Return(nil).Times(2)
+	app := NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOneTimeTokenRepository(tokens), WithMailer(mailer))
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(userJson))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		err := app.CreateUser(e.NewContext(req, rec))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		bodies = append(bodies, rec.Body.String())
+	}
+	require.Equal(t, bodies[0], bodies[1])
 
 }
 
@@ -72,6 +111,20 @@ func passwordPointer(password string) *string {
 	return &password
 }
 
+// noAttempts records attempts without ever counting more than one.
+func noAttempts(ctrl *gomock.Controller) *repo.MockLoginAttemptRepository {
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil).AnyTimes()
+	attempts.EXPECT().Forgive(gomock.Any()).Return(nil).AnyTimes()
+	attempts.EXPECT().Reset(gomock.Any()).Return(nil).AnyTimes()
+	return attempts
+}
+
+// noLockout never delays logins while tracking them like the real limiter.
+func noLockout(ctrl *gomock.Controller) *lockout.Limiter {
+	return lockout.NewLimiter(noAttempts(ctrl), lockout.DefaultPolicy)
+}
+
 func TestCreateUserLogin(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	// Setup
@@ -82,14 +135,15 @@ func TestCreateUserLogin(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	db := repo.NewMockUserRepository(ctrl)
-	password, err := repo.HashPassword("1234567898")
+	password, err := password.NewDefaultHasher().Hash("1234567898")
 	require.NoError(t, err)
 	db.EXPECT().FindByEmail(gomock.Any()).Return(repo.User{Password: passwordPointer(password)}, nil)
 	logger := zap.NewNop()
-	app := NewApp(e, db, logger, nil)
+	app := NewApp(e, db, logger, nil, WithTokenIssuer(token.NewIssuer("secret", time.Hour)), WithLockout(noLockout(ctrl)))
 	err = app.Login(c)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"token"`)
 
 }
 
@@ -103,13 +157,111 @@ func TestCreateUserLogin401(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	db := repo.NewMockUserRepository(ctrl)
-	password, err := repo.HashPassword("aotherPassword")
+	password, err := password.NewDefaultHasher().Hash("aotherPassword")
 	require.NoError(t, err)
 	db.EXPECT().FindByEmail(gomock.Any()).Return(repo.User{Password: passwordPointer(password)}, nil)
 	logger := zap.NewNop()
-	app := NewApp(e, db, logger, nil)
+	app := NewApp(e, db, logger, nil, WithLockout(noLockout(ctrl)))
 	err = app.Login(c)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 
 }
+
+func TestLoginRehashesOutdatedPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	userJson := `{"email": "fo@bo.com", "password": "1234567898"}`
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(userJson))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	db := repo.NewMockUserRepository(ctrl)
+	legacy, err := password.NewBcrypt(4).Hash("1234567898", "")
+	require.NoError(t, err)
+	db.EXPECT().FindByEmail(gomock.Any()).Return(repo.User{ID: "user-1", Password: passwordPointer(legacy)}, nil)
+	db.EXPECT().Update(gomock.Any()).DoAndReturn(func(user repo.User) (repo.User, error) {
+		require.Equal(t, "user-1", user.ID)
+		require.Equal(t, "1234567898", *user.Password)
+		return user, nil
+	})
+	logger := zap.NewNop()
+	app := NewApp(e, db, logger, nil, WithTokenIssuer(token.NewIssuer("secret", time.Hour)), WithLockout(noLockout(ctrl)))
+	err = app.Login(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+}
+
+func TestLoginUnknownEmail401(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	userJson := `{"email": "fo@bo.com", "password": "1234567898"}`
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(userJson))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail(gomock.Any()).Return(repo.User{}, mongo.ErrNoDocuments)
+	logger := zap.NewNop()
+	app := NewApp(e, db, logger, nil, WithLockout(noLockout(ctrl)))
+	err := app.Login(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.JSONEq(t, `{"message": "Invalid credentials", "error": "Invalid credentials"}`, rec.Body.String())
+
+}
+
+func TestLoginLockedOut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	userJson := `{"email": "fo@bo.com", "password": "1234567898"}`
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(userJson))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().RecordFailure("account:fo@bo.com", gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 11, PreviousFailure: time.Now().Add(-810 * time.Second)}, nil)
+	attempts.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.LoginAttempts{Failures: 1}, nil)
+	db := repo.NewMockUserRepository(ctrl)
+	logger := zap.NewNop()
+	app := NewApp(e, db, logger, nil, WithLockout(lockout.NewLimiter(attempts, lockout.DefaultPolicy)))
+	err := app.Login(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "90", rec.Header().Get(echo.HeaderRetryAfter))
+
+}
+
+func TestUnlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/admin/unlock", strings.NewReader(`{"email": "fo@bo.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().Reset("account:fo@bo.com").Return(nil)
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleSupport), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(lockout.NewLimiter(attempts, lockout.DefaultPolicy)))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+}
+
+func TestUnlock400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/admin/unlock", strings.NewReader(`{}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	authorize(t, req, repo.User{ID: "admin-1", Email: "admin@bo.com"})
+	rec := httptest.NewRecorder()
+	NewApp(e, usersWithRoles(ctrl, rbac.RoleSupport), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
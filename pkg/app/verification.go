@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+const resendAccepted = "If the email is registered and not verified yet, a new verification link has been sent"
+
+// VerifyEmail marks the email of the user a verification token was sent to as verified.
+func (a *App) VerifyEmail(c echo.Context) error {
+	verifyRequest := new(VerifyEmailRequest)
+	if err := c.Bind(verifyRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(verifyRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	claims, err := a.tokens.VerifyOneTime(verifyRequest.Token, token.PurposeVerifyEmail)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid verification token", Error: err.Error()})
+	}
+	if _, err := a.tokenRepo.Consume(claims.ID, token.PurposeVerifyEmail, time.Now()); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid verification token", Error: "token was already used or revoked"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to consume verification token", Error: err.Error()})
+	}
+	if err := a.users(c).VerifyEmail(claims.Subject); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid verification token", Error: "user not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to verify email", Error: err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResendVerification sends a new verification link and revokes the previous
+// ones. It responds the same whether or not the email is registered.
+func (a *App) ResendVerification(c echo.Context) error {
+	resendRequest := new(ResendVerificationRequest)
+	if err := c.Bind(resendRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(resendRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	user, err := a.users(c).FindByEmail(resendRequest.Email)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find user", Error: err.Error()})
+	case !user.EmailVerified:
+		if err := a.tokenRepo.DeleteByUser(user.ID, token.PurposeVerifyEmail); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke verification tokens", Error: err.Error()})
+		}
+		if err := a.sendVerification(user); err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to send verification email", Error: err.Error()})
+		}
+	}
+	return c.JSON(http.StatusAccepted, MessageResponse{Message: resendAccepted})
+}
+
+func (a *App) sendVerification(user repo.User) error {
+	signed, claims, err := a.tokens.IssueOneTime(user.ID, token.PurposeVerifyEmail, a.verification.TTL)
+	if err != nil {
+		return err
+	}
+	err = a.tokenRepo.Create(repo.OneTimeToken{
+		ID:        claims.ID,
+		UserID:    user.ID,
+		Purpose:   token.PurposeVerifyEmail,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return err
+	}
+	link, err := tokenLink(a.verification.URL, signed, user.TenantID)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\nplease verify your email by opening the link below within %s:\n\n%s\n",
+			user.FirstName, a.verification.TTL, link),
+	})
+}
+
+// sendSignupNotice tells the owner of an already registered email about the
+// signup attempt, as the response to it does not.
+func (a *App) sendSignupNotice(email string) {
+	err := a.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Your account already exists",
+		Body: "Hello,\n\nsomeone tried to sign up with this email, which already has an account.\n" +
+			"If it was you, log in instead. If you did not verify your email yet you can request a new verification link.\n",
+	})
+	if err != nil {
+		a.log.Warn("Failed to send signup notice", zap.Error(err))
+	}
+}
+
+// tokenLink appends token as the token query parameter to page, and the
+// tenant as the tenant query parameter unless it is the default one.
+func tokenLink(page, signed, tenantID string) (string, error) {
+	link, err := url.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", signed)
+	if tenantID != "" && tenantID != repo.DefaultTenant {
+		query.Set("tenant", tenantID)
+	}
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
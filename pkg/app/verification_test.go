@@ -0,0 +1,131 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/mail"
+	"github.com/Davut97/go-user/pkg/password"
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	signed, claims, err := testIssuer.IssueOneTime("6553a1e1f1d2c3b4a5968778", token.PurposeVerifyEmail, time.Hour)
+	require.NoError(t, err)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/user/verify", strings.NewReader(`{"token": "`+signed+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().VerifyEmail("6553a1e1f1d2c3b4a5968778").Return(nil)
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().Consume(claims.ID, token.PurposeVerifyEmail, gomock.Any()).Return(repo.OneTimeToken{ID: claims.ID}, nil)
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOneTimeTokenRepository(tokens))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+}
+
+func TestVerifyEmailUsedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	signed, _, err := testIssuer.IssueOneTime("6553a1e1f1d2c3b4a5968778", token.PurposeVerifyEmail, time.Hour)
+	require.NoError(t, err)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/user/verify", strings.NewReader(`{"token": "`+signed+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo.OneTimeToken{}, mongo.ErrNoDocuments)
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOneTimeTokenRepository(tokens))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestVerifyEmailAccessToken(t *testing.T) {
+	// Setup
+	signed, err := testIssuer.Issue(repo.User{ID: "6553a1e1f1d2c3b4a5968778"})
+	require.NoError(t, err)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/user/verify", strings.NewReader(`{"token": "`+signed+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	NewApp(e, nil, zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+}
+
+func TestResendVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindByEmail("fo@bo.com").Return(repo.User{ID: "user-1", Email: "fo@bo.com"}, nil)
+	db.EXPECT().FindByEmail("verified@bo.com").Return(repo.User{ID: "user-2", Email: "verified@bo.com", EmailVerified: true}, nil)
+	db.EXPECT().FindByEmail("unknown@bo.com").Return(repo.User{}, mongo.ErrNoDocuments)
+	tokens := repo.NewMockOneTimeTokenRepository(ctrl)
+	tokens.EXPECT().DeleteByUser("user-1", token.PurposeVerifyEmail).Return(nil)
+	tokens.EXPECT().Create(gomock.Any()).Return(nil)
+	mailer := mail.NewMockSender(ctrl)
+	var sent mail.Message
+	mailer.EXPECT().Send(gomock.Any()).DoAndReturn(func(msg mail.Message) error {
+		sent = msg
+		return nil
+	})
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithOneTimeTokenRepository(tokens), WithMailer(mailer),
+		WithEmailVerification(Verification{URL: "https://bo.com/verify", TTL: time.Hour}))
+	var bodies []string
+	for _, email := range []string{"fo@bo.com", "verified@bo.com", "unknown@bo.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/user/verify/resend", strings.NewReader(`{"email": "`+email+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		bodies = append(bodies, rec.Body.String())
+	}
+	require.Equal(t, bodies[0], bodies[1])
+	require.Equal(t, bodies[0], bodies[2])
+
+	require.Equal(t, "fo@bo.com", sent.To)
+	link := sent.Body[strings.Index(sent.Body, "https://"):]
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	require.NoError(t, err)
+	claims, err := testIssuer.VerifyOneTime(parsed.Query().Get("token"), token.PurposeVerifyEmail)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+
+}
+
+func TestLoginUnverifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "fo@bo.com", "password": "1234567898"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	db := repo.NewMockUserRepository(ctrl)
+	hash, err := password.NewDefaultHasher().Hash("1234567898")
+	require.NoError(t, err)
+	db.EXPECT().FindByEmail(gomock.Any()).Return(repo.User{Password: &hash}, nil)
+	app := NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer), WithLockout(noLockout(ctrl)),
+		WithEmailVerification(Verification{TTL: time.Hour, Required: true}))
+	err = app.Login(c)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+}
@@ -0,0 +1,334 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/token"
+	"github.com/Davut97/go-user/repo"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	webAuthnRegistration = "webauthn-registration"
+	webAuthnLogin        = "webauthn-login"
+)
+
+// WebAuthn configures passkey registration and login.
+type WebAuthn struct {
+	// RP is the relying party the ceremonies are run for, passkeys are
+	// disabled without it.
+	RP          *webauthn.WebAuthn
+	Credentials repo.WebAuthnCredentialRepository
+	Sessions    repo.WebAuthnSessionRepository
+}
+
+type WebAuthnBeginResponse struct {
+	// Session identifies the ceremony when it is finished.
+	Session string `json:"session"`
+	// Options are passed to navigator.credentials.create or navigator.credentials.get.
+	Options interface{} `json:"options"`
+}
+
+type WebAuthnRegistrationRequest struct {
+	Session string `json:"session" validate:"required"`
+	Name    string `json:"name" validate:"max=64"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.create.
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type WebAuthnLoginRequest struct {
+	Session string `json:"session" validate:"required"`
+	// Credential is the PublicKeyCredential returned by navigator.credentials.get.
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// BeginWebAuthnRegistration starts registering a passkey for the logged in user.
+func (a *App) BeginWebAuthnRegistration(c echo.Context) error {
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	user := userFrom(c)
+	credentials, err := a.webAuthn.Credentials.FindByUser(user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find passkeys", Error: err.Error()})
+	}
+	owner := webAuthnUser{user: user, credentials: credentials}
+	var exclusions []protocol.CredentialDescriptor
+	for _, credential := range owner.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	// Resident keys are required, as logins do not ask for the email first.
+	creation, session, err := a.webAuthn.RP.BeginRegistration(owner,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to begin passkey registration", Error: err.Error()})
+	}
+	return a.beginWebAuthn(c, user.ID, webAuthnRegistration, creation.Response.Timeout, session, creation)
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's response to the
+// registration challenge and stores the new passkey.
+func (a *App) FinishWebAuthnRegistration(c echo.Context) error {
+	registrationRequest := new(WebAuthnRegistrationRequest)
+	if err := c.Bind(registrationRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(registrationRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	user := userFrom(c)
+	session, err := a.webAuthn.Sessions.Consume(registrationRequest.Session, webAuthnRegistration, time.Now())
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && session.UserID != user.ID) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid session", Error: "unknown or expired session"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find session", Error: err.Error()})
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(registrationRequest.Credential))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid credential", Error: err.Error()})
+	}
+	created, err := a.webAuthn.RP.CreateCredential(webAuthnUser{user: user}, sessionData(session, []byte(user.ID)), parsed)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid credential", Error: err.Error()})
+	}
+
+	credential := repo.WebAuthnCredential{
+		ID:              base64.RawURLEncoding.EncodeToString(created.ID),
+		UserID:          user.ID,
+		Name:            registrationRequest.Name,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+		CreatedAt:       time.Now().UTC(),
+	}
+	for _, transport := range created.Transport {
+		credential.Transports = append(credential.Transports, string(transport))
+	}
+	if err := a.webAuthn.Credentials.Create(credential); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Message: "Passkey already registered", Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to store passkey", Error: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, credential)
+}
+
+// GetWebAuthnCredentials lists the passkeys of the logged in user.
+func (a *App) GetWebAuthnCredentials(c echo.Context) error {
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	credentials, err := a.webAuthn.Credentials.FindByUser(userFrom(c).ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find passkeys", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, credentials)
+}
+
+// DeleteWebAuthnCredential removes a passkey of the logged in user.
+func (a *App) DeleteWebAuthnCredential(c echo.Context) error {
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	err := a.webAuthn.Credentials.Delete(c.Param("id"), userFrom(c).ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Message: "Passkey not found", Error: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete passkey", Error: err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BeginWebAuthnLogin starts a passkey login. No email is asked for, the
+// authenticator picks the credential, so the response does not reveal
+// whether an account exists.
+func (a *App) BeginWebAuthnLogin(c echo.Context) error {
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	assertion, session, err := a.webAuthn.RP.BeginDiscoverableLogin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to begin passkey login", Error: err.Error()})
+	}
+	return a.beginWebAuthn(c, "", webAuthnLogin, assertion.Response.Timeout, session, assertion)
+}
+
+// FinishWebAuthnLogin verifies the authenticator's signature of the login
+// challenge and issues an access token. A passkey replaces both the password
+// and the second factor.
+func (a *App) FinishWebAuthnLogin(c echo.Context) error {
+	loginRequest := new(WebAuthnLoginRequest)
+	if err := c.Bind(loginRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if err := c.Validate(loginRequest); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid request body", Error: err.Error()})
+	}
+	if a.webAuthn.RP == nil {
+		return c.JSON(http.StatusNotImplemented, ErrorResponse{Message: "Passkeys are not configured", Error: "missing WebAuthn relying party"})
+	}
+	session, err := a.webAuthn.Sessions.Consume(loginRequest.Session, webAuthnLogin, time.Now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid session", Error: "unknown or expired session"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find session", Error: err.Error()})
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(loginRequest.Credential))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid credential", Error: err.Error()})
+	}
+
+	var owner webAuthnUser
+	var lookupErr error
+	validated, err := a.webAuthn.RP.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		owner, lookupErr = a.findWebAuthnUser(c, rawID, userHandle)
+		return owner, lookupErr
+	}, sessionData(session, nil), parsed)
+	if lookupErr != nil && !errors.Is(lookupErr, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to find passkey", Error: lookupErr.Error()})
+	}
+	if err != nil {
+		a.recordLogin(c, owner.user, repo.LoginEvent{Method: repo.LoginWebAuthn, Outcome: repo.LoginFailed})
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid credentials", Error: "Invalid credentials"})
+	}
+	stored := owner.credentials[0]
+	if validated.Authenticator.CloneWarning {
+		a.log.Warn("Passkey signature counter did not increase", zap.String("userId", owner.user.ID), zap.String("credentialId", stored.ID),
+			zap.Uint32("stored", stored.SignCount), zap.Uint32("received", parsed.Response.AuthenticatorData.Counter))
+		a.recordLogin(c, owner.user, repo.LoginEvent{Method: repo.LoginWebAuthn, Outcome: repo.LoginFailed})
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid credentials", Error: "signature counter did not increase"})
+	}
+	err = a.webAuthn.Credentials.UpdateSignCount(stored.ID, stored.SignCount, validated.Authenticator.SignCount, validated.Flags.BackupState, time.Now().UTC())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid credentials", Error: "passkey was used concurrently"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update passkey", Error: err.Error()})
+	}
+	if a.verification.Required && !owner.user.EmailVerified {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Message: "Email not verified", Error: "verify your email before logging in"})
+	}
+	a.recordLogin(c, owner.user, repo.LoginEvent{Method: repo.LoginWebAuthn, Outcome: repo.LoginSucceeded, MFA: validated.Flags.UserVerified})
+	return a.issueToken(c, owner.user)
+}
+
+// beginWebAuthn stores the challenge of a ceremony for as long as the client
+// is told to wait for the authenticator.
+func (a *App) beginWebAuthn(c echo.Context, userID, purpose string, timeout int, session *webauthn.SessionData, options interface{}) error {
+	id, err := token.NewOpaque()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create session", Error: err.Error()})
+	}
+	err = a.webAuthn.Sessions.Create(repo.WebAuthnSession{
+		ID:               id,
+		UserID:           userID,
+		Purpose:          purpose,
+		Challenge:        session.Challenge,
+		UserVerification: string(session.UserVerification),
+		ExpiresAt:        time.Now().Add(time.Duration(timeout) * time.Millisecond),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to store session", Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, WebAuthnBeginResponse{Session: id, Options: options})
+}
+
+// findWebAuthnUser returns the owner of the credential used for a login, with
+// that credential only.
+func (a *App) findWebAuthnUser(c echo.Context, rawID, userHandle []byte) (webAuthnUser, error) {
+	credential, err := a.webAuthn.Credentials.FindOne(base64.RawURLEncoding.EncodeToString(rawID))
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	if credential.UserID != string(userHandle) {
+		return webAuthnUser{}, mongo.ErrNoDocuments
+	}
+	user, err := a.users(c).FindOne(credential.UserID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	return webAuthnUser{user: user, credentials: []repo.WebAuthnCredential{credential}}, nil
+}
+
+func sessionData(session repo.WebAuthnSession, userID []byte) webauthn.SessionData {
+	return webauthn.SessionData{
+		Challenge:        session.Challenge,
+		UserID:           userID,
+		UserVerification: protocol.UserVerificationRequirement(session.UserVerification),
+	}
+}
+
+// webAuthnUser adapts a user and their stored credentials to webauthn.User,
+// the user ID is used as the user handle.
+type webAuthnUser struct {
+	user        repo.User
+	credentials []repo.WebAuthnCredential
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName); name != "" {
+		return name
+	}
+	return u.user.Email
+}
+
+func (u webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		id, err := base64.RawURLEncoding.DecodeString(stored.ID)
+		if err != nil {
+			continue
+		}
+		credential := webauthn.Credential{
+			ID:              id,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		}
+		for _, transport := range stored.Transports {
+			credential.Transport = append(credential.Transport, protocol.AuthenticatorTransport(transport))
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
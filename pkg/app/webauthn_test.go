@@ -0,0 +1,275 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+const testOrigin = "http://localhost:8080"
+
+func testRelyingParty(t *testing.T) *webauthn.WebAuthn {
+	rp, err := webauthn.New(&webauthn.Config{RPID: "localhost", RPDisplayName: "go-user", RPOrigins: []string{testOrigin}})
+	require.NoError(t, err)
+	return rp
+}
+
+// softAuthenticator is a passkey authenticator holding a single ES256
+// credential, it answers the options returned by the begin endpoints.
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	counter    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &softAuthenticator{key: key, id: id}
+}
+
+type webAuthnOptions struct {
+	Session string `json:"session"`
+	Options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+// create answers registration options with a "none" attestation.
+func (s *softAuthenticator) create(t *testing.T, options webAuthnOptions) string {
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.Options.PublicKey.User.ID)
+	require.NoError(t, err)
+	s.userHandle = userHandle
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         int64(webauthncose.P256),
+		XCoord:        s.key.X.FillBytes(make([]byte, 32)),
+		YCoord:        s.key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+	authData := s.authData(0x40)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(s.id)))
+	authData = append(authData, s.id...)
+	authData = append(authData, publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{"fmt": "none", "attStmt": map[string]interface{}{}, "authData": authData})
+	require.NoError(t, err)
+	return s.credential(t, map[string]string{
+		"clientDataJSON":    s.clientData(t, "webauthn.create", options.Options.PublicKey.Challenge),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// get signs the challenge of login options.
+func (s *softAuthenticator) get(t *testing.T, options webAuthnOptions) string {
+	clientData := s.clientData(t, "webauthn.get", options.Options.PublicKey.Challenge)
+	decoded, err := base64.RawURLEncoding.DecodeString(clientData)
+	require.NoError(t, err)
+	authData := s.authData(0)
+	clientDataHash := sha256.Sum256(decoded)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	require.NoError(t, err)
+	return s.credential(t, map[string]string{
+		"clientDataJSON":    clientData,
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(s.userHandle),
+	})
+}
+
+// authData starts the authenticator data with the user present and verified
+// flags, the given flags and the counter.
+func (s *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte("localhost"))
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, 0x01|0x04|flags)
+	return binary.BigEndian.AppendUint32(authData, s.counter)
+}
+
+func (s *softAuthenticator) clientData(t *testing.T, ceremony, challenge string) string {
+	clientData, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": testOrigin})
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(clientData)
+}
+
+func (s *softAuthenticator) credential(t *testing.T, response map[string]string) string {
+	id := base64.RawURLEncoding.EncodeToString(s.id)
+	credential, err := json.Marshal(map[string]interface{}{"id": id, "rawId": id, "type": "public-key", "response": response})
+	require.NoError(t, err)
+	return string(credential)
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", EmailVerified: true}
+	db := repo.NewMockUserRepository(ctrl)
+	db.EXPECT().FindOne("user-1").Return(user, nil).AnyTimes()
+	var stored repo.WebAuthnCredential
+	credentials := repo.NewMockWebAuthnCredentialRepository(ctrl)
+	credentials.EXPECT().FindByUser("user-1").Return([]repo.WebAuthnCredential{}, nil)
+	credentials.EXPECT().Create(gomock.Any()).DoAndReturn(func(credential repo.WebAuthnCredential) error {
+		stored = credential
+		return nil
+	})
+	credentials.EXPECT().FindOne(gomock.Any()).DoAndReturn(func(id string) (repo.WebAuthnCredential, error) {
+		return stored, nil
+	}).Times(3)
+	credentials.EXPECT().UpdateSignCount(gomock.Any(), uint32(1), uint32(2), false, gomock.Any()).DoAndReturn(func(id string, from, to uint32, backupState bool, at time.Time) error {
+		stored.SignCount = to
+		return nil
+	})
+	var created repo.WebAuthnSession
+	sessions := repo.NewMockWebAuthnSessionRepository(ctrl)
+	sessions.EXPECT().Create(gomock.Any()).DoAndReturn(func(session repo.WebAuthnSession) error {
+		created = session
+		return nil
+	}).Times(4)
+	e := echo.New()
+	NewApp(e, db, zap.NewNop(), nil, WithTokenIssuer(testIssuer),
+		WithWebAuthn(WebAuthn{RP: testRelyingParty(t), Credentials: credentials, Sessions: sessions}))
+	post := func(path, body string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if authorized {
+			authorize(t, req, user)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	begin := func(path string, authorized bool) webAuthnOptions {
+		rec := post(path, "", authorized)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var options webAuthnOptions
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &options))
+		return options
+	}
+	authenticator := newSoftAuthenticator(t)
+
+	// Registration
+	authenticator.counter = 1
+	options := begin("/me/webauthn/register/begin", true)
+	require.Equal(t, []byte("user-1"), mustDecode(t, options.Options.PublicKey.User.ID))
+	sessions.EXPECT().Consume(options.Session, webAuthnRegistration, gomock.Any()).Return(created, nil)
+	rec := post("/me/webauthn/register/finish", `{"session": "`+options.Session+`", "name": "laptop", "credential": `+authenticator.create(t, options)+`}`, true)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(authenticator.id), stored.ID)
+	require.Equal(t, "user-1", stored.UserID)
+	require.Equal(t, "laptop", stored.Name)
+	require.Equal(t, uint32(1), stored.SignCount)
+
+	// Login
+	authenticator.counter = 2
+	options = begin("/login/webauthn/begin", false)
+	gomock.InOrder(
+		sessions.EXPECT().Consume(options.Session, webAuthnLogin, gomock.Any()).Return(created, nil),
+		sessions.EXPECT().Consume(options.Session, webAuthnLogin, gomock.Any()).Return(repo.WebAuthnSession{}, mongo.ErrNoDocuments),
+	)
+	rec = post("/login/webauthn/finish", `{"session": "`+options.Session+`", "credential": `+authenticator.get(t, options)+`}`, false)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login LoginResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	claims, err := testIssuer.Verify(login.Token)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+
+	// A challenge can not be answered twice
+	rec = post("/login/webauthn/finish", `{"session": "`+options.Session+`", "credential": `+authenticator.get(t, options)+`}`, false)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// A counter that did not increase hints at a cloned authenticator
+	options = begin("/login/webauthn/begin", false)
+	sessions.EXPECT().Consume(options.Session, webAuthnLogin, gomock.Any()).Return(created, nil)
+	rec = post("/login/webauthn/finish", `{"session": "`+options.Session+`", "credential": `+authenticator.get(t, options)+`}`, false)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Signatures of another key are rejected
+	options = begin("/login/webauthn/begin", false)
+	sessions.EXPECT().Consume(options.Session, webAuthnLogin, gomock.Any()).Return(created, nil)
+	forged := newSoftAuthenticator(t)
+	forged.id, forged.userHandle, forged.counter = authenticator.id, authenticator.userHandle, 3
+	rec = post("/login/webauthn/finish", `{"session": "`+options.Session+`", "credential": `+forged.get(t, options)+`}`, false)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestWebAuthnLoginUnknownCredential(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	credentials := repo.NewMockWebAuthnCredentialRepository(ctrl)
+	credentials.EXPECT().FindOne(gomock.Any()).Return(repo.WebAuthnCredential{}, mongo.ErrNoDocuments)
+	var created repo.WebAuthnSession
+	sessions := repo.NewMockWebAuthnSessionRepository(ctrl)
+	sessions.EXPECT().Create(gomock.Any()).DoAndReturn(func(session repo.WebAuthnSession) error {
+		created = session
+		return nil
+	})
+	e := echo.New()
+	NewApp(e, repo.NewMockUserRepository(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer),
+		WithWebAuthn(WebAuthn{RP: testRelyingParty(t), Credentials: credentials, Sessions: sessions}))
+	req := httptest.NewRequest(http.MethodPost, "/login/webauthn/begin", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var options webAuthnOptions
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &options))
+	sessions.EXPECT().Consume(options.Session, webAuthnLogin, gomock.Any()).Return(created, nil)
+
+	authenticator := newSoftAuthenticator(t)
+	authenticator.userHandle = []byte("user-1")
+	req = httptest.NewRequest(http.MethodPost, "/login/webauthn/finish", strings.NewReader(`{"session": "`+options.Session+`", "credential": `+authenticator.get(t, options)+`}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+}
+
+func TestWebAuthnNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	// Setup
+	e := echo.New()
+	NewApp(e, knownUsers(ctrl), zap.NewNop(), nil, WithTokenIssuer(testIssuer))
+	req := httptest.NewRequest(http.MethodPost, "/me/webauthn/register/begin", nil)
+	authorize(t, req, repo.User{ID: "user-1"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+}
+
+func mustDecode(t *testing.T, encoded string) []byte {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	return decoded
+}
@@ -8,18 +8,262 @@ type Config struct {
 	JokesURL           string
 	JokesLimit         int
 	JokesTimeout       int
-	BindAddress        string
+	JokesTopWindow     int
+	JokesDailyTimeZone string
+	// JokesBlocklistFile is an optional file of blocked words and /regex/ patterns.
+	JokesBlocklistFile string
+	// Comma separated categories that are filtered out, the safe ones only when ?safe=true.
+	JokesExcludedCategories     string
+	JokesSafeExcludedCategories string
+	// JokesSubmissionsShare is the share of /jokes results taken from approved user submissions.
+	JokesSubmissionsShare float64
+	BindAddress           string
+	JWTSecret             string
+	JWTExpiration         int
+	// TrustedProxies is a comma separated list of the reverse proxies, as CIDR
+	// ranges or addresses, whose X-Forwarded-For header names the client.
+	TrustedProxies string
+	// AdminEmails is a comma separated list of users granted the admin role at
+	// startup, once they verified their email. Users of other tenants than the
+	// default one are written as tenant/email.
+	AdminEmails string
+	// PasswordAlgorithm is either argon2id or bcrypt, hashes of the other one are upgraded on login.
+	PasswordAlgorithm string
+	// PasswordArgon2Memory is the argon2id memory in KiB.
+	PasswordArgon2Memory      int
+	PasswordArgon2Time        int
+	PasswordArgon2Parallelism int
+	PasswordBcryptCost        int
+	// PasswordPeppers are optional pepper keys written as id:base64key separated
+	// by commas, PasswordPeppersFile a file with one such key per line.
+	PasswordPeppers       string
+	PasswordPeppersFile   string
+	PasswordPepperCurrent string
+	// PasswordMinLength and PasswordMaxLength bound new passwords in characters,
+	// the PasswordRequire fields ask for at least one character of each class.
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	// PasswordHistory is how many recent passwords can not be chosen again.
+	PasswordHistory int
+	// PasswordBreachedFile optionally names the breached passwords, either a
+	// directory of Have I Been Pwned range files or a single sorted file of
+	// SHA-1 hashes.
+	PasswordBreachedFile string
+	// LoginFreeAttempts failures are allowed before delays starting at
+	// LoginBaseDelay seconds, doubling up to LoginMaxDelay seconds, are enforced.
+	LoginFreeAttempts int
+	LoginBaseDelay    int
+	LoginMaxDelay     int
+	// LoginLockoutThreshold failures per account, LoginIPLockoutThreshold per
+	// IP address, lock logins for LoginLockoutDuration minutes.
+	LoginLockoutThreshold   int
+	LoginIPLockoutThreshold int
+	LoginLockoutDuration    int
+	// LoginFailureWindow is how many minutes failures are remembered.
+	LoginFailureWindow int
+	// MailSender is either log, which only logs emails, or smtp.
+	MailSender   string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// EmailVerificationURL is the page verification links point to,
+	// EmailVerificationTTL how many hours they are valid.
+	EmailVerificationURL      string
+	EmailVerificationTTL      int
+	EmailVerificationRequired bool
+	// PasswordResetURL is the page reset links point to, PasswordResetTTL how
+	// many minutes they are valid.
+	PasswordResetURL string
+	PasswordResetTTL int
+	// PasswordResetPerEmail requests per email, PasswordResetPerIP per IP
+	// address, are allowed per hour.
+	PasswordResetPerEmail int
+	PasswordResetPerIP    int
+	// TOTPIssuer is shown next to the account in authenticator apps.
+	TOTPIssuer string
+	// TOTPEncryptionKey is a base64 encoded 32 byte key encrypting the TOTP
+	// secrets, two-factor enrollment is disabled without it.
+	TOTPEncryptionKey string
+	// WebAuthnRPID is the domain passkeys are bound to, WebAuthnRPOrigins the
+	// comma separated origins the ceremonies may be run from. Passkeys are
+	// disabled without an RP ID.
+	WebAuthnRPID          string
+	WebAuthnRPDisplayName string
+	WebAuthnRPOrigins     string
+	// Tenants is a comma separated list of tenants besides the default one,
+	// TenantHeader the header requests without an access token name theirs with.
+	Tenants      string
+	TenantHeader string
+	// RegistrationInviteOnly closes public signup, accounts are only created
+	// through invitations. InvitationURL is the page invitation links point
+	// to, InvitationTTL how many hours they are valid.
+	RegistrationInviteOnly bool
+	InvitationURL          string
+	InvitationTTL          int
+	// OIDCIssuer is the URL go-user is reachable at by other apps, acting as
+	// their OpenID Connect provider is disabled without it.
+	// OIDCSigningKeyFile is a PEM encoded RSA key signing their tokens, a key
+	// is generated at startup without it.
+	OIDCIssuer         string
+	OIDCSigningKeyFile string
+	// OIDCConsentURL is the page authorization requests are sent on to,
+	// OIDCTokenTTL how many minutes tokens handed to apps are valid.
+	OIDCConsentURL string
+	OIDCTokenTTL   int
+	// OIDCLoginIssuer is the external OpenID Connect provider users can log
+	// in with, federated login is disabled without it. go-user is registered
+	// there with the client ID and secret, the provider sends users back to
+	// OIDCLoginRedirectURL.
+	OIDCLoginIssuer       string
+	OIDCLoginClientID     string
+	OIDCLoginClientSecret string
+	OIDCLoginRedirectURL  string
+	// OIDCLoginScopes is a space separated list of scopes to ask for,
+	// OIDCLoginCreateUsers creates accounts for users logging in the first
+	// time.
+	OIDCLoginScopes      string
+	OIDCLoginCreateUsers bool
+	// LoginHistoryDays is how many days login attempts are kept.
+	// GeoIPFile is an optional IP to city database in the DB-IP lite CSV
+	// format, logins faster than LoginMaxSpeed km/h apart are flagged with it.
+	LoginHistoryDays int
+	GeoIPFile        string
+	LoginMaxSpeed    float64
+	// ImpersonationTTL is how many minutes impersonation tokens are valid.
+	ImpersonationTTL int
 }
 
 func GetConfig() (Config, error) {
 	viper.AutomaticEnv()
+	viper.SetDefault("JOKES_TOP_WINDOW", 168)
+	viper.SetDefault("JOKES_DAILY_TIMEZONE", "UTC")
+	viper.SetDefault("JOKES_EXCLUDED_CATEGORIES", "explicit")
+	viper.SetDefault("JOKES_SAFE_EXCLUDED_CATEGORIES", "explicit,political,religion")
+	viper.SetDefault("JOKES_SUBMISSIONS_SHARE", 0.2)
+	viper.SetDefault("JWT_EXPIRATION", 60)
+	viper.SetDefault("PASSWORD_ALGORITHM", "argon2id")
+	viper.SetDefault("PASSWORD_ARGON2_MEMORY", 64*1024)
+	viper.SetDefault("PASSWORD_ARGON2_TIME", 3)
+	viper.SetDefault("PASSWORD_ARGON2_PARALLELISM", 2)
+	viper.SetDefault("PASSWORD_BCRYPT_COST", 12)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 128)
+	viper.SetDefault("PASSWORD_HISTORY", 5)
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_BASE_DELAY", 1)
+	viper.SetDefault("LOGIN_MAX_DELAY", 60)
+	viper.SetDefault("LOGIN_LOCKOUT_THRESHOLD", 10)
+	viper.SetDefault("LOGIN_IP_LOCKOUT_THRESHOLD", 100)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", 15)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", 60)
+	viper.SetDefault("MAIL_SENDER", "log")
+	viper.SetDefault("MAIL_FROM", "go-user@localhost")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "http://localhost:8080/verify-email")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", 24)
+	viper.SetDefault("EMAIL_VERIFICATION_REQUIRED", true)
+	viper.SetDefault("PASSWORD_RESET_URL", "http://localhost:8080/reset-password")
+	viper.SetDefault("PASSWORD_RESET_TTL", 30)
+	viper.SetDefault("PASSWORD_RESET_PER_EMAIL", 3)
+	viper.SetDefault("PASSWORD_RESET_PER_IP", 20)
+	viper.SetDefault("TOTP_ISSUER", "go-user")
+	viper.SetDefault("WEBAUTHN_RP_ID", "localhost")
+	viper.SetDefault("WEBAUTHN_RP_DISPLAY_NAME", "go-user")
+	viper.SetDefault("WEBAUTHN_RP_ORIGINS", "http://localhost:8080")
+	viper.SetDefault("TENANT_HEADER", "X-Tenant-ID")
+	viper.SetDefault("INVITATION_URL", "http://localhost:8080/accept-invitation")
+	viper.SetDefault("INVITATION_TTL", 168)
+	viper.SetDefault("OIDC_CONSENT_URL", "http://localhost:8080/consent")
+	viper.SetDefault("OIDC_TOKEN_TTL", 60)
+	viper.SetDefault("OIDC_LOGIN_REDIRECT_URL", "http://localhost:8080/login/callback")
+	viper.SetDefault("OIDC_LOGIN_SCOPES", "openid email profile")
+	viper.SetDefault("OIDC_LOGIN_CREATE_USERS", true)
+	viper.SetDefault("LOGIN_HISTORY_DAYS", 90)
+	viper.SetDefault("LOGIN_MAX_SPEED", 1000)
+	viper.SetDefault("IMPERSONATION_TTL", 15)
 
 	return Config{
-		DBConnectionString: viper.GetString("DB_CONNECTION_STRING"),
-		DBName:             viper.GetString("DB_NAME"),
-		JokesURL:           viper.GetString("JOKES_URL"),
-		JokesLimit:         viper.GetInt("JOKES_LIMIT"),
-		JokesTimeout:       viper.GetInt("JOKES_TIMEOUT"),
-		BindAddress:        viper.GetString("BIND_ADDRESS"),
+		DBConnectionString:          viper.GetString("DB_CONNECTION_STRING"),
+		DBName:                      viper.GetString("DB_NAME"),
+		JokesURL:                    viper.GetString("JOKES_URL"),
+		JokesLimit:                  viper.GetInt("JOKES_LIMIT"),
+		JokesTimeout:                viper.GetInt("JOKES_TIMEOUT"),
+		JokesTopWindow:              viper.GetInt("JOKES_TOP_WINDOW"),
+		JokesDailyTimeZone:          viper.GetString("JOKES_DAILY_TIMEZONE"),
+		JokesBlocklistFile:          viper.GetString("JOKES_BLOCKLIST_FILE"),
+		JokesExcludedCategories:     viper.GetString("JOKES_EXCLUDED_CATEGORIES"),
+		JokesSafeExcludedCategories: viper.GetString("JOKES_SAFE_EXCLUDED_CATEGORIES"),
+		JokesSubmissionsShare:       viper.GetFloat64("JOKES_SUBMISSIONS_SHARE"),
+		BindAddress:                 viper.GetString("BIND_ADDRESS"),
+		TrustedProxies:              viper.GetString("TRUSTED_PROXIES"),
+		JWTSecret:                   viper.GetString("JWT_SECRET"),
+		JWTExpiration:               viper.GetInt("JWT_EXPIRATION"),
+		AdminEmails:                 viper.GetString("ADMIN_EMAILS"),
+		PasswordAlgorithm:           viper.GetString("PASSWORD_ALGORITHM"),
+		PasswordArgon2Memory:        viper.GetInt("PASSWORD_ARGON2_MEMORY"),
+		PasswordArgon2Time:          viper.GetInt("PASSWORD_ARGON2_TIME"),
+		PasswordArgon2Parallelism:   viper.GetInt("PASSWORD_ARGON2_PARALLELISM"),
+		PasswordBcryptCost:          viper.GetInt("PASSWORD_BCRYPT_COST"),
+		PasswordPeppers:             viper.GetString("PASSWORD_PEPPERS"),
+		PasswordPeppersFile:         viper.GetString("PASSWORD_PEPPERS_FILE"),
+		PasswordPepperCurrent:       viper.GetString("PASSWORD_PEPPER_CURRENT"),
+		PasswordMinLength:           viper.GetInt("PASSWORD_MIN_LENGTH"),
+		PasswordMaxLength:           viper.GetInt("PASSWORD_MAX_LENGTH"),
+		PasswordRequireUpper:        viper.GetBool("PASSWORD_REQUIRE_UPPER"),
+		PasswordRequireLower:        viper.GetBool("PASSWORD_REQUIRE_LOWER"),
+		PasswordRequireDigit:        viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		PasswordRequireSymbol:       viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+		PasswordHistory:             viper.GetInt("PASSWORD_HISTORY"),
+		PasswordBreachedFile:        viper.GetString("PASSWORD_BREACHED_FILE"),
+		LoginFreeAttempts:           viper.GetInt("LOGIN_FREE_ATTEMPTS"),
+		LoginBaseDelay:              viper.GetInt("LOGIN_BASE_DELAY"),
+		LoginMaxDelay:               viper.GetInt("LOGIN_MAX_DELAY"),
+		LoginLockoutThreshold:       viper.GetInt("LOGIN_LOCKOUT_THRESHOLD"),
+		LoginIPLockoutThreshold:     viper.GetInt("LOGIN_IP_LOCKOUT_THRESHOLD"),
+		LoginLockoutDuration:        viper.GetInt("LOGIN_LOCKOUT_DURATION"),
+		LoginFailureWindow:          viper.GetInt("LOGIN_FAILURE_WINDOW"),
+		MailSender:                  viper.GetString("MAIL_SENDER"),
+		MailFrom:                    viper.GetString("MAIL_FROM"),
+		SMTPHost:                    viper.GetString("SMTP_HOST"),
+		SMTPPort:                    viper.GetInt("SMTP_PORT"),
+		SMTPUsername:                viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:                viper.GetString("SMTP_PASSWORD"),
+		EmailVerificationURL:        viper.GetString("EMAIL_VERIFICATION_URL"),
+		EmailVerificationTTL:        viper.GetInt("EMAIL_VERIFICATION_TTL"),
+		EmailVerificationRequired:   viper.GetBool("EMAIL_VERIFICATION_REQUIRED"),
+		PasswordResetURL:            viper.GetString("PASSWORD_RESET_URL"),
+		PasswordResetTTL:            viper.GetInt("PASSWORD_RESET_TTL"),
+		PasswordResetPerEmail:       viper.GetInt("PASSWORD_RESET_PER_EMAIL"),
+		PasswordResetPerIP:          viper.GetInt("PASSWORD_RESET_PER_IP"),
+		TOTPIssuer:                  viper.GetString("TOTP_ISSUER"),
+		TOTPEncryptionKey:           viper.GetString("TOTP_ENCRYPTION_KEY"),
+		WebAuthnRPID:                viper.GetString("WEBAUTHN_RP_ID"),
+		WebAuthnRPDisplayName:       viper.GetString("WEBAUTHN_RP_DISPLAY_NAME"),
+		WebAuthnRPOrigins:           viper.GetString("WEBAUTHN_RP_ORIGINS"),
+		Tenants:                     viper.GetString("TENANTS"),
+		TenantHeader:                viper.GetString("TENANT_HEADER"),
+		RegistrationInviteOnly:      viper.GetBool("REGISTRATION_INVITE_ONLY"),
+		InvitationURL:               viper.GetString("INVITATION_URL"),
+		InvitationTTL:               viper.GetInt("INVITATION_TTL"),
+		OIDCIssuer:                  viper.GetString("OIDC_ISSUER"),
+		OIDCSigningKeyFile:          viper.GetString("OIDC_SIGNING_KEY_FILE"),
+		OIDCConsentURL:              viper.GetString("OIDC_CONSENT_URL"),
+		OIDCTokenTTL:                viper.GetInt("OIDC_TOKEN_TTL"),
+		OIDCLoginIssuer:             viper.GetString("OIDC_LOGIN_ISSUER"),
+		OIDCLoginClientID:           viper.GetString("OIDC_LOGIN_CLIENT_ID"),
+		OIDCLoginClientSecret:       viper.GetString("OIDC_LOGIN_CLIENT_SECRET"),
+		OIDCLoginRedirectURL:        viper.GetString("OIDC_LOGIN_REDIRECT_URL"),
+		OIDCLoginScopes:             viper.GetString("OIDC_LOGIN_SCOPES"),
+		OIDCLoginCreateUsers:        viper.GetBool("OIDC_LOGIN_CREATE_USERS"),
+		LoginHistoryDays:            viper.GetInt("LOGIN_HISTORY_DAYS"),
+		GeoIPFile:                   viper.GetString("GEOIP_FILE"),
+		LoginMaxSpeed:               viper.GetFloat64("LOGIN_MAX_SPEED"),
+		ImpersonationTTL:            viper.GetInt("IMPERSONATION_TTL"),
 	}, nil
 }
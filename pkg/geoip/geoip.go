@@ -0,0 +1,144 @@
+// Package geoip locates IP addresses with an offline database file, so that
+// the addresses users log in from never leave the server.
+package geoip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const earthRadius = 6371 // km
+
+// Location is where an IP address is, as far as the database knows.
+type Location struct {
+	Country   string  `json:"country" bson:"country"`
+	City      string  `json:"city,omitempty" bson:"city,omitempty"`
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	Longitude float64 `json:"longitude" bson:"longitude"`
+}
+
+// Distance returns the great-circle distance between two locations in
+// kilometers.
+func Distance(a, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat, dLon := lat2-lat1, (b.Longitude-a.Longitude)*math.Pi/180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DB looks addresses up in an IP to city database in the CSV format of the
+// DB-IP lite databases: one range per line with the first and last address,
+// continent, country, region, city, latitude and longitude, sorted by the
+// first address with the IPv4 ranges before the IPv6 ones. Like the breached
+// password list, the file is searched on disk and never loaded into memory.
+type DB struct {
+	file *os.File
+	size int64
+}
+
+func Open(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &DB{file: file, size: info.Size()}, nil
+}
+
+// Lookup binary searches the file for the range holding ip and reports
+// whether there is one.
+func (d *DB) Lookup(ip string) (Location, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}, false, nil
+	}
+	addr = addr.Unmap()
+	// Only lines starting in [lo, hi) are left to search, lo is always the start of a line.
+	lo, hi := int64(0), d.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := d.lineStart(mid)
+		if err != nil {
+			return Location{}, false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		line, err := d.line(start)
+		if err != nil {
+			return Location{}, false, err
+		}
+		first, last, location, err := parseRange(line)
+		if err != nil {
+			return Location{}, false, fmt.Errorf("invalid GeoIP range at offset %d: %w", start, err)
+		}
+		switch {
+		case addr.Less(first):
+			hi = mid
+		case last.Less(addr):
+			lo = start + int64(len(line))
+		default:
+			return location, true, nil
+		}
+	}
+	return Location{}, false, nil
+}
+
+func (d *DB) Close() error {
+	return d.file.Close()
+}
+
+func parseRange(line string) (first, last netip.Addr, location Location, err error) {
+	record, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return first, last, location, err
+	}
+	if len(record) < 8 {
+		return first, last, location, fmt.Errorf("expected 8 columns, got %d", len(record))
+	}
+	if first, err = netip.ParseAddr(record[0]); err != nil {
+		return first, last, location, err
+	}
+	if last, err = netip.ParseAddr(record[1]); err != nil {
+		return first, last, location, err
+	}
+	location.Country, location.City = record[3], record[5]
+	if location.Latitude, err = strconv.ParseFloat(record[6], 64); err != nil {
+		return first, last, location, err
+	}
+	location.Longitude, err = strconv.ParseFloat(record[7], 64)
+	return first, last, location, err
+}
+
+// lineStart returns the offset of the first line starting at or after offset.
+func (d *DB) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	skipped, err := d.line(offset - 1)
+	if err != nil {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// line returns the line from offset up to and including its new line.
+func (d *DB) line(offset int64) (string, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(d.file, offset, d.size-offset)).ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return line, err
+}
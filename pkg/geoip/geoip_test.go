@@ -0,0 +1,50 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testRanges = `"1.0.0.0","1.0.0.255","OC","AU","Queensland","South Brisbane","-27.4767","153.017"
+"2.16.0.0","2.16.255.255","EU","DE","Hesse","Frankfurt am Main","50.1109","8.68213"
+"8.8.8.0","8.8.8.255","NA","US","California","Mountain View","37.422","-122.085"
+"2001:4860::","2001:4860:ffff:ffff:ffff:ffff:ffff:ffff","NA","US","California","Mountain View","37.422","-122.085"
+`
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbip-city-lite.csv")
+	require.NoError(t, os.WriteFile(path, []byte(testRanges), 0o600))
+	db, err := Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for ip, city := range map[string]string{
+		"1.0.0.0":          "South Brisbane",
+		"2.16.12.1":        "Frankfurt am Main",
+		"8.8.8.255":        "Mountain View",
+		"::ffff:8.8.8.8":   "Mountain View",
+		"2001:4860:4860::": "Mountain View",
+	} {
+		location, found, err := db.Lookup(ip)
+		require.NoError(t, err)
+		require.True(t, found, ip)
+		require.Equal(t, city, location.City, ip)
+	}
+	for _, ip := range []string{"0.255.255.255", "1.0.1.0", "9.0.0.0", "::1", "not an ip"} {
+		_, found, err := db.Lookup(ip)
+		require.NoError(t, err)
+		require.False(t, found, ip)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	frankfurt := Location{Latitude: 50.1109, Longitude: 8.68213}
+	brisbane := Location{Latitude: -27.4767, Longitude: 153.017}
+
+	require.InDelta(t, 16090, Distance(frankfurt, brisbane), 50)
+	require.InDelta(t, Distance(frankfurt, brisbane), Distance(brisbane, frankfurt), 0.001)
+	require.Zero(t, Distance(frankfurt, frankfurt))
+}
@@ -0,0 +1,106 @@
+package joke
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/singleflight"
+)
+
+const DateLayout = "2006-01-02"
+
+var (
+	ErrInvalidDate = errors.New("invalid date")
+	ErrNoDailyJoke = errors.New("no joke of the day for this date")
+	ErrFutureDate  = errors.New("date is in the future")
+)
+
+// Daily picks one joke per calendar day in its time zone. The choice is
+// persisted so that restarts and other replicas serve the same joke, and
+// kept in memory so the store is only consulted once per day. Concurrent
+// requests for a day that is not cached yet share a single lookup.
+type Daily struct {
+	client   JokeClient
+	repo     repo.DailyJokeRepository
+	location *time.Location
+	now      func() time.Time
+	lookups  singleflight.Group
+
+	mu    sync.Mutex
+	cache map[string]repo.DailyJoke
+}
+
+func NewDaily(client JokeClient, dailyRepo repo.DailyJokeRepository, location *time.Location) *Daily {
+	return &Daily{
+		client:   client,
+		repo:     dailyRepo,
+		location: location,
+		now:      time.Now,
+		cache:    map[string]repo.DailyJoke{},
+	}
+}
+
+func (d *Daily) Today() (repo.DailyJoke, error) {
+	return d.ForDate(d.now().In(d.location).Format(DateLayout))
+}
+
+// ForDate returns the joke of the given day. A joke is only chosen for the
+// current day, earlier days without a joke return ErrNoDailyJoke.
+func (d *Daily) ForDate(date string) (repo.DailyJoke, error) {
+	day, err := time.ParseInLocation(DateLayout, date, d.location)
+	if err != nil {
+		return repo.DailyJoke{}, errors.Join(ErrInvalidDate, err)
+	}
+	today := d.now().In(d.location).Format(DateLayout)
+	if day.Format(DateLayout) > today {
+		return repo.DailyJoke{}, ErrFutureDate
+	}
+
+	if daily, ok := d.cached(date); ok {
+		return daily, nil
+	}
+	// The lock is not held while the store and the joke API are called, so
+	// a slow lookup only holds up the callers waiting for the same day
+	daily, err, _ := d.lookups.Do(date, func() (interface{}, error) {
+		if daily, ok := d.cached(date); ok {
+			return daily, nil
+		}
+		daily, err := d.repo.FindByDate(date)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if date != today {
+				return repo.DailyJoke{}, ErrNoDailyJoke
+			}
+			daily, err = d.choose(date)
+		}
+		if err != nil {
+			return repo.DailyJoke{}, err
+		}
+		d.mu.Lock()
+		d.cache[date] = daily
+		d.mu.Unlock()
+		return daily, nil
+	})
+	return daily.(repo.DailyJoke), err
+}
+
+func (d *Daily) cached(date string) (repo.DailyJoke, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	daily, ok := d.cache[date]
+	return daily, ok
+}
+
+func (d *Daily) choose(date string) (repo.DailyJoke, error) {
+	j, err := d.client.GetJoke()
+	if err != nil {
+		return repo.DailyJoke{}, err
+	}
+	return d.repo.CreateIfAbsent(repo.DailyJoke{
+		Date:      date,
+		Joke:      repo.Joke{ID: j.ID, Categories: j.Categories, IconURL: j.IconURL, URL: j.URL, Value: j.Value},
+		CreatedAt: d.now(),
+	})
+}
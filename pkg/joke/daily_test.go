@@ -0,0 +1,108 @@
+package joke
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestDaily(t *testing.T, client JokeClient, dailyRepo repo.DailyJokeRepository) *Daily {
+	location, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	daily := NewDaily(client, dailyRepo, location)
+	// 23:30 UTC is already the next day in Berlin
+	daily.now = func() time.Time { return time.Date(2023, 11, 14, 23, 30, 0, 0, time.UTC) }
+	return daily
+}
+
+func TestDailyTodayFetchesOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockJokeClient(ctrl)
+	client.EXPECT().GetJoke().Return(Joke{ID: "joke-1", Value: "funny"}, nil).Times(1)
+	dailyRepo := repo.NewMockDailyJokeRepository(ctrl)
+	dailyRepo.EXPECT().FindByDate("2023-11-15").Return(repo.DailyJoke{}, mongo.ErrNoDocuments).Times(1)
+	dailyRepo.EXPECT().CreateIfAbsent(gomock.Any()).DoAndReturn(func(daily repo.DailyJoke) (repo.DailyJoke, error) {
+		return daily, nil
+	}).Times(1)
+	daily := newTestDaily(t, client, dailyRepo)
+
+	first, err := daily.Today()
+	require.NoError(t, err)
+	require.Equal(t, "2023-11-15", first.Date)
+	require.Equal(t, "joke-1", first.Joke.ID)
+
+	second, err := daily.Today()
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
+
+func TestDailySlowFetch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	release := make(chan struct{})
+	fetching := make(chan struct{})
+	client := NewMockJokeClient(ctrl)
+	client.EXPECT().GetJoke().DoAndReturn(func() (Joke, error) {
+		close(fetching)
+		<-release
+		return Joke{ID: "joke-1"}, nil
+	}).Times(1)
+	dailyRepo := repo.NewMockDailyJokeRepository(ctrl)
+	dailyRepo.EXPECT().FindByDate("2023-11-15").Return(repo.DailyJoke{}, mongo.ErrNoDocuments).Times(1)
+	dailyRepo.EXPECT().CreateIfAbsent(gomock.Any()).DoAndReturn(func(daily repo.DailyJoke) (repo.DailyJoke, error) {
+		return daily, nil
+	}).Times(1)
+	stored := repo.DailyJoke{Date: "2023-11-01", Joke: repo.Joke{ID: "joke-2"}}
+	dailyRepo.EXPECT().FindByDate("2023-11-01").Return(stored, nil)
+	daily := newTestDaily(t, client, dailyRepo)
+
+	todays := make(chan repo.DailyJoke, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			got, _ := daily.Today()
+			todays <- got
+		}()
+	}
+	<-fetching
+	// Other days are served while the joke of today is being fetched
+	got, err := daily.ForDate("2023-11-01")
+	require.NoError(t, err)
+	require.Equal(t, stored, got)
+
+	close(release)
+	require.Equal(t, "joke-1", (<-todays).Joke.ID)
+	require.Equal(t, "joke-1", (<-todays).Joke.ID)
+}
+
+func TestDailyTodayUsesStoredJoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockJokeClient(ctrl)
+	dailyRepo := repo.NewMockDailyJokeRepository(ctrl)
+	stored := repo.DailyJoke{Date: "2023-11-15", Joke: repo.Joke{ID: "joke-2"}}
+	dailyRepo.EXPECT().FindByDate("2023-11-15").Return(stored, nil)
+	daily := newTestDaily(t, client, dailyRepo)
+
+	got, err := daily.Today()
+	require.NoError(t, err)
+	require.Equal(t, stored, got)
+}
+
+func TestDailyForDate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockJokeClient(ctrl)
+	dailyRepo := repo.NewMockDailyJokeRepository(ctrl)
+	dailyRepo.EXPECT().FindByDate("2023-11-01").Return(repo.DailyJoke{}, mongo.ErrNoDocuments)
+	daily := newTestDaily(t, client, dailyRepo)
+
+	_, err := daily.ForDate("2023-11-01")
+	require.ErrorIs(t, err, ErrNoDailyJoke)
+
+	_, err = daily.ForDate("2023-11-16")
+	require.ErrorIs(t, err, ErrFutureDate)
+
+	_, err = daily.ForDate("14.11.2023")
+	require.ErrorIs(t, err, ErrInvalidDate)
+}
@@ -0,0 +1,156 @@
+package joke
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var ErrFiltered = errors.New("no joke passed the content filter")
+
+// Filter decides whether a joke is fit to be served. A joke is rejected when
+// it contains a blocked word, matches a blocked pattern or belongs to an
+// excluded category.
+type Filter struct {
+	words      *regexp.Regexp
+	patterns   []*regexp.Regexp
+	categories map[string]bool
+}
+
+// NewFilter builds a filter from blocked words (matched case-insensitively as
+// whole words), blocked regular expressions and excluded categories.
+func NewFilter(words, patterns, categories []string) (*Filter, error) {
+	f := &Filter{categories: map[string]bool{}}
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) > 0 {
+		f.words = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	for _, category := range categories {
+		if category = strings.TrimSpace(category); category != "" {
+			f.categories[strings.ToLower(category)] = true
+		}
+	}
+	return f, nil
+}
+
+// LoadBlocklist reads a blocklist file with one entry per line. Entries
+// wrapped in slashes such as /sh[i1]t/ are regular expressions, all other
+// entries are words. Empty lines and lines starting with # are ignored.
+func LoadBlocklist(path string) (words, patterns []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/"):
+			patterns = append(patterns, line[1:len(line)-1])
+		default:
+			words = append(words, line)
+		}
+	}
+	return words, patterns, scanner.Err()
+}
+
+// WithCategories returns a copy of the filter that also excludes the given categories.
+func (f *Filter) WithCategories(categories ...string) *Filter {
+	stricter := &Filter{words: f.words, patterns: f.patterns, categories: map[string]bool{}}
+	for category := range f.categories {
+		stricter.categories[category] = true
+	}
+	for _, category := range categories {
+		if category = strings.TrimSpace(category); category != "" {
+			stricter.categories[strings.ToLower(category)] = true
+		}
+	}
+	return stricter
+}
+
+func (f *Filter) Allowed(joke Joke) bool {
+	for _, category := range joke.Categories {
+		if f.categories[strings.ToLower(category)] {
+			return false
+		}
+	}
+	if f.words != nil && f.words.MatchString(joke.Value) {
+		return false
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(joke.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilteringJokeClient wraps a JokeClient and replaces rejected jokes with
+// fresh ones so that callers still receive the number of jokes they asked for.
+type FilteringJokeClient struct {
+	client      JokeClient
+	filter      *Filter
+	maxAttempts int
+}
+
+func NewFilteringJokeClient(client JokeClient, filter *Filter) *FilteringJokeClient {
+	return &FilteringJokeClient{client: client, filter: filter, maxAttempts: 5}
+}
+
+func (c *FilteringJokeClient) GetJoke() (Joke, error) {
+	return c.GetJokeContext(context.Background())
+}
+
+func (c *FilteringJokeClient) GetJokeContext(ctx context.Context) (Joke, error) {
+	for i := 0; i < c.maxAttempts; i++ {
+		joke, err := c.client.GetJokeContext(ctx)
+		if err != nil {
+			return Joke{}, err
+		}
+		if c.filter.Allowed(joke) {
+			return joke, nil
+		}
+	}
+	return Joke{}, ErrFiltered
+}
+
+// GetJokes fetches replacements for filtered or duplicate jokes until limit is
+// reached or the attempts run out, in which case fewer jokes are returned.
+func (c *FilteringJokeClient) GetJokes(limit int) ([]Joke, error) {
+	jokes := make([]Joke, 0, limit)
+	seen := map[string]bool{}
+	for i := 0; i < c.maxAttempts && len(jokes) < limit; i++ {
+		fetched, err := c.client.GetJokes(limit - len(jokes))
+		if err != nil {
+			return nil, err
+		}
+		for _, joke := range fetched {
+			if len(jokes) == limit {
+				break
+			}
+			if seen[joke.ID] || !c.filter.Allowed(joke) {
+				continue
+			}
+			seen[joke.ID] = true
+			jokes = append(jokes, joke)
+		}
+	}
+	return jokes, nil
+}
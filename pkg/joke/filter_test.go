@@ -0,0 +1,69 @@
+package joke
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFilterAllowed(t *testing.T) {
+	filter, err := NewFilter([]string{"beer"}, []string{`d[a@]mn`}, []string{"explicit"})
+	require.NoError(t, err)
+
+	require.True(t, filter.Allowed(Joke{Value: "Chuck Norris counted to infinity. Twice."}))
+	require.True(t, filter.Allowed(Joke{Value: "Chuck Norris drinks root beers", Categories: []string{"food"}}))
+	require.False(t, filter.Allowed(Joke{Value: "Chuck Norris drinks BEER for breakfast"}))
+	require.False(t, filter.Allowed(Joke{Value: "d@mn, Chuck Norris"}))
+	require.False(t, filter.Allowed(Joke{Value: "Chuck Norris", Categories: []string{"Explicit"}}))
+
+	safe := filter.WithCategories("political")
+	require.False(t, safe.Allowed(Joke{Value: "Chuck Norris", Categories: []string{"political"}}))
+	require.True(t, filter.Allowed(Joke{Value: "Chuck Norris", Categories: []string{"political"}}))
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# words\nbeer\n\n/sh[i1]t/\n"), 0o600))
+
+	words, patterns, err := LoadBlocklist(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"beer"}, words)
+	require.Equal(t, []string{"sh[i1]t"}, patterns)
+}
+
+func TestFilteringJokeClientReplacesFilteredJokes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockJokeClient(ctrl)
+	gomock.InOrder(
+		client.EXPECT().GetJokes(3).Return([]Joke{
+			{ID: "1", Value: "clean"},
+			{ID: "2", Value: "dirty", Categories: []string{"explicit"}},
+			{ID: "3", Value: "clean"},
+		}, nil),
+		client.EXPECT().GetJokes(1).Return([]Joke{{ID: "1", Value: "clean"}}, nil),
+		client.EXPECT().GetJokes(1).Return([]Joke{{ID: "4", Value: "clean"}}, nil),
+	)
+	filter, err := NewFilter(nil, nil, []string{"explicit"})
+	require.NoError(t, err)
+
+	jokes, err := NewFilteringJokeClient(client, filter).GetJokes(3)
+	require.NoError(t, err)
+	require.Len(t, jokes, 3)
+	require.Equal(t, "1", jokes[0].ID)
+	require.Equal(t, "3", jokes[1].ID)
+	require.Equal(t, "4", jokes[2].ID)
+}
+
+func TestFilteringJokeClientGetJoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := NewMockJokeClient(ctrl)
+	client.EXPECT().GetJokeContext(gomock.Any()).Return(Joke{ID: "1", Value: "beer"}, nil).Times(5)
+	filter, err := NewFilter([]string{"beer"}, nil, nil)
+	require.NoError(t, err)
+
+	_, err = NewFilteringJokeClient(client, filter).GetJoke()
+	require.ErrorIs(t, err, ErrFiltered)
+}
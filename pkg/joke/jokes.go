@@ -1,20 +1,23 @@
 package joke
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
 )
 
 type Joke struct {
-	IconURL string `json:"icon_url"`
-	ID      string `json:"id"`
-	URL     string `json:"url"`
-	Value   string `json:"value"`
+	Categories []string `json:"categories"`
+	IconURL    string   `json:"icon_url"`
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	Value      string   `json:"value"`
 }
 
 type JokeClient interface {
 	GetJoke() (Joke, error)
+	GetJokeContext(ctx context.Context) (Joke, error)
 	GetJokes(limit int) ([]Joke, error)
 }
 
@@ -29,7 +32,11 @@ func NewChuckNorrisJokeClient(baseURL string, httpClient *http.Client, limit int
 }
 
 func (c *ChuckNorrisJokeClient) GetJoke() (Joke, error) {
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/jokes/random", nil)
+	return c.GetJokeContext(context.Background())
+}
+
+func (c *ChuckNorrisJokeClient) GetJokeContext(ctx context.Context) (Joke, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/jokes/random", nil)
 	if err != nil {
 		return Joke{}, err
 	}
@@ -47,14 +54,18 @@ func (c *ChuckNorrisJokeClient) GetJoke() (Joke, error) {
 	return joke, nil
 }
 
+// GetJokes fetches limit jokes concurrently, at most the limit the client was created with.
 func (c *ChuckNorrisJokeClient) GetJokes(limit int) ([]Joke, error) {
 	var mutex sync.Mutex
 	var jokes []Joke
 
+	if limit > c.limit {
+		limit = c.limit
+	}
 	wg := sync.WaitGroup{}
-	wg.Add(c.limit)
+	wg.Add(limit)
 
-	for i := 0; i < c.limit; i++ {
+	for i := 0; i < limit; i++ {
 		go func() {
 			joke, err := c.GetJoke()
 			if err != nil {
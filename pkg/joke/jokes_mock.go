@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./jokes.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./jokes.go -destination=./jokes_mock.go
+//
+// Package mock_joke is a generated GoMock package.
+package joke
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockJokeClient is a mock of JokeClient interface.
+type MockJokeClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockJokeClientMockRecorder
+}
+
+// MockJokeClientMockRecorder is the mock recorder for MockJokeClient.
+type MockJokeClientMockRecorder struct {
+	mock *MockJokeClient
+}
+
+// NewMockJokeClient creates a new mock instance.
+func NewMockJokeClient(ctrl *gomock.Controller) *MockJokeClient {
+	mock := &MockJokeClient{ctrl: ctrl}
+	mock.recorder = &MockJokeClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJokeClient) EXPECT() *MockJokeClientMockRecorder {
+	return m.recorder
+}
+
+// GetJoke mocks base method.
+func (m *MockJokeClient) GetJoke() (Joke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJoke")
+	ret0, _ := ret[0].(Joke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJoke indicates an expected call of GetJoke.
+func (mr *MockJokeClientMockRecorder) GetJoke() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJoke", reflect.TypeOf((*MockJokeClient)(nil).GetJoke))
+}
+
+// GetJokeContext mocks base method.
+func (m *MockJokeClient) GetJokeContext(ctx context.Context) (Joke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJokeContext", ctx)
+	ret0, _ := ret[0].(Joke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJokeContext indicates an expected call of GetJokeContext.
+func (mr *MockJokeClientMockRecorder) GetJokeContext(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJokeContext", reflect.TypeOf((*MockJokeClient)(nil).GetJokeContext), ctx)
+}
+
+// GetJokes mocks base method.
+func (m *MockJokeClient) GetJokes(limit int) ([]Joke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJokes", limit)
+	ret0, _ := ret[0].([]Joke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJokes indicates an expected call of GetJokes.
+func (mr *MockJokeClientMockRecorder) GetJokes(limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJokes", reflect.TypeOf((*MockJokeClient)(nil).GetJokes), limit)
+}
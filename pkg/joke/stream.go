@@ -0,0 +1,33 @@
+package joke
+
+import (
+	"context"
+	"sync"
+)
+
+// Result is the outcome of a single fetch made by Stream.
+type Result struct {
+	Joke Joke
+	Err  error
+}
+
+// Stream fetches limit jokes concurrently and delivers each result as soon as
+// its fetch completes. Cancelling ctx aborts the outstanding fetches. The
+// channel is closed once every fetch has finished.
+func Stream(ctx context.Context, client JokeClient, limit int) <-chan Result {
+	results := make(chan Result, limit)
+	wg := sync.WaitGroup{}
+	wg.Add(limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			defer wg.Done()
+			joke, err := client.GetJokeContext(ctx)
+			results <- Result{Joke: joke, Err: err}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,48 @@
+package joke
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first joke is fast, all others are slow
+		if calls.Add(1) > 1 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte(`{"id": "1", "value": "Chuck Norris"}`))
+	}))
+	defer server.Close()
+
+	results := Stream(context.Background(), NewChuckNorrisJokeClient(server.URL, server.Client(), 3), 3)
+	count := 0
+	for result := range results {
+		require.NoError(t, result.Err)
+		require.Equal(t, "Chuck Norris", result.Joke.Value)
+		count++
+	}
+	require.Equal(t, 3, count)
+}
+
+func TestStreamCancel(t *testing.T) {
+	// The upstream never answers, only cancelling the stream ends the fetches
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := Stream(ctx, NewChuckNorrisJokeClient(server.URL, server.Client(), 2), 2)
+	cancel()
+	for result := range results {
+		require.ErrorIs(t, result.Err, context.Canceled)
+	}
+}
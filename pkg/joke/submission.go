@@ -0,0 +1,95 @@
+package joke
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/Davut97/go-user/repo"
+)
+
+var ErrNoJokes = errors.New("no jokes available")
+
+// SubmissionJokeClient serves the approved jokes submitted by the users of
+// one tenant.
+type SubmissionJokeClient struct {
+	submissions repo.SubmissionRepository
+	tenantID    string
+}
+
+func NewSubmissionJokeClient(submissions repo.SubmissionRepository, tenantID string) *SubmissionJokeClient {
+	return &SubmissionJokeClient{submissions: submissions, tenantID: tenantID}
+}
+
+func (c *SubmissionJokeClient) GetJoke() (Joke, error) {
+	jokes, err := c.GetJokes(1)
+	if err != nil {
+		return Joke{}, err
+	}
+	if len(jokes) == 0 {
+		return Joke{}, ErrNoJokes
+	}
+	return jokes[0], nil
+}
+
+// GetJokeContext only checks ctx before reading from the repository.
+func (c *SubmissionJokeClient) GetJokeContext(ctx context.Context) (Joke, error) {
+	if err := ctx.Err(); err != nil {
+		return Joke{}, err
+	}
+	return c.GetJoke()
+}
+
+func (c *SubmissionJokeClient) GetJokes(limit int) ([]Joke, error) {
+	submissions, err := c.submissions.RandomApproved(c.tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	jokes := make([]Joke, 0, len(submissions))
+	for _, s := range submissions {
+		jokes = append(jokes, Joke{ID: s.ID, Categories: s.Categories, Value: s.Value})
+	}
+	return jokes, nil
+}
+
+// MixedJokeClient mixes jokes of a secondary source into the jokes of a
+// primary one. The secondary source supplies share of the jokes when it has
+// enough, the primary one supplies the rest.
+type MixedJokeClient struct {
+	primary   JokeClient
+	secondary JokeClient
+	share     float64
+}
+
+func NewMixedJokeClient(primary, secondary JokeClient, share float64) *MixedJokeClient {
+	return &MixedJokeClient{primary: primary, secondary: secondary, share: share}
+}
+
+func (c *MixedJokeClient) GetJoke() (Joke, error) {
+	return c.GetJokeContext(context.Background())
+}
+
+func (c *MixedJokeClient) GetJokeContext(ctx context.Context) (Joke, error) {
+	if rand.Float64() < c.share {
+		if joke, err := c.secondary.GetJokeContext(ctx); err == nil {
+			return joke, nil
+		}
+	}
+	return c.primary.GetJokeContext(ctx)
+}
+
+func (c *MixedJokeClient) GetJokes(limit int) ([]Joke, error) {
+	var jokes []Joke
+	if n := int(math.Round(float64(limit) * c.share)); n > 0 {
+		// The secondary source is optional, failing it only means more primary jokes.
+		jokes, _ = c.secondary.GetJokes(n)
+	}
+	primary, err := c.primary.GetJokes(limit - len(jokes))
+	if err != nil {
+		return nil, err
+	}
+	jokes = append(jokes, primary...)
+	rand.Shuffle(len(jokes), func(i, j int) { jokes[i], jokes[j] = jokes[j], jokes[i] })
+	return jokes, nil
+}
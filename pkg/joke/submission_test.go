@@ -0,0 +1,52 @@
+package joke
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSubmissionJokeClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	submissionRepo := repo.NewMockSubmissionRepository(ctrl)
+	submissionRepo.EXPECT().RandomApproved(repo.DefaultTenant, 2).Return([]repo.Submission{
+		{ID: "1", Value: "first", Status: repo.SubmissionApproved},
+		{ID: "2", Value: "second", Status: repo.SubmissionApproved},
+	}, nil)
+	submissionRepo.EXPECT().RandomApproved(repo.DefaultTenant, 1).Return([]repo.Submission{}, nil)
+	client := NewSubmissionJokeClient(submissionRepo, repo.DefaultTenant)
+
+	jokes, err := client.GetJokes(2)
+	require.NoError(t, err)
+	require.Equal(t, []Joke{{ID: "1", Value: "first"}, {ID: "2", Value: "second"}}, jokes)
+
+	_, err = client.GetJoke()
+	require.ErrorIs(t, err, ErrNoJokes)
+}
+
+func TestMixedJokeClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	primary := NewMockJokeClient(ctrl)
+	secondary := NewMockJokeClient(ctrl)
+	secondary.EXPECT().GetJokes(3).Return([]Joke{{ID: "s1"}}, nil)
+	primary.EXPECT().GetJokes(9).Return([]Joke{{ID: "p1"}, {ID: "p2"}}, nil)
+
+	jokes, err := NewMixedJokeClient(primary, secondary, 0.3).GetJokes(10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Joke{{ID: "s1"}, {ID: "p1"}, {ID: "p2"}}, jokes)
+}
+
+func TestMixedJokeClientSecondaryFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	primary := NewMockJokeClient(ctrl)
+	secondary := NewMockJokeClient(ctrl)
+	secondary.EXPECT().GetJokes(5).Return(nil, errors.New("error"))
+	primary.EXPECT().GetJokes(10).Return([]Joke{{ID: "p1"}}, nil)
+
+	jokes, err := NewMixedJokeClient(primary, secondary, 0.5).GetJokes(10)
+	require.NoError(t, err)
+	require.Equal(t, []Joke{{ID: "p1"}}, jokes)
+}
@@ -0,0 +1,137 @@
+package lockout
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+)
+
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before delays are enforced.
+	FreeAttempts int
+	// BaseDelay is the first delay, it doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Threshold is the number of failures per account, IPThreshold per IP
+	// address, that lock logins for Duration.
+	Threshold   int
+	IPThreshold int
+	Duration    time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+var DefaultPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	Threshold:    10,
+	IPThreshold:  100,
+	Duration:     15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// QuotaPolicy allows perAccount requests per account and perIP per IP address
+// within window, without delays in between. Every request counts.
+func QuotaPolicy(perAccount, perIP int, window time.Duration) Policy {
+	return Policy{
+		FreeAttempts: perAccount,
+		Threshold:    perAccount,
+		IPThreshold:  perIP,
+		Duration:     window,
+		Window:       window,
+	}
+}
+
+// Limiter tracks failed logins per account and per IP address and tells
+// when the next attempt is allowed. Accounts are tracked by the email used to
+// log in, whether or not a user with that email exists.
+type Limiter struct {
+	attempts repo.LoginAttemptRepository
+	policy   Policy
+	now      func() time.Time
+}
+
+func NewLimiter(attempts repo.LoginAttemptRepository, policy Policy) *Limiter {
+	return &Limiter{attempts: attempts, policy: policy, now: time.Now}
+}
+
+// Check counts an attempt to log in and returns how long the caller has to
+// wait before it may try, zero when it may try right away. The attempt counts
+// as a failure until Success or Forgive takes it back, and the wait is told
+// by the count the attempt was recorded with, so that parallel attempts can
+// not all pass before the first failure is recorded.
+func (l *Limiter) Check(email, ip string) (time.Duration, error) {
+	now := l.now()
+	var wait time.Duration
+	for key, threshold := range map[string]int{accountKey(email): l.policy.Threshold, ipKey(ip): l.policy.IPThreshold} {
+		attempts, err := l.attempts.RecordFailure(key, now, l.policy.Window)
+		if err != nil {
+			return 0, err
+		}
+		// The attempt has to wait out the delay earned by the ones before it
+		if w := attempts.PreviousFailure.Add(l.policy.delay(attempts.Failures-1, threshold)).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	return wait, nil
+}
+
+// Success takes back the attempt and forgets the failures of the account. The
+// failures of the IP address are kept, otherwise one valid account would
+// unlock guessing for all others.
+func (l *Limiter) Success(email, ip string) error {
+	if err := l.attempts.Reset(accountKey(email)); err != nil {
+		return err
+	}
+	return l.attempts.Forgive(ipKey(ip))
+}
+
+// Forgive takes back a valid attempt that does not end a login, such as a
+// correct password of a user who still has to enter a second factor. Earlier
+// failures are kept.
+func (l *Limiter) Forgive(email, ip string) error {
+	if err := l.attempts.Forgive(accountKey(email)); err != nil {
+		return err
+	}
+	return l.attempts.Forgive(ipKey(ip))
+}
+
+// Unlock forgets the failures of an account and an IP address, either may be empty.
+func (l *Limiter) Unlock(email, ip string) error {
+	if email != "" {
+		if err := l.attempts.Reset(accountKey(email)); err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		return l.attempts.Reset(ipKey(ip))
+	}
+	return nil
+}
+
+func (p Policy) delay(failures, threshold int) time.Duration {
+	if failures >= threshold {
+		return p.Duration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
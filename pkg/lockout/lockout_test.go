@@ -0,0 +1,79 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := DefaultPolicy
+	require.Equal(t, time.Duration(0), policy.delay(1, policy.Threshold))
+	require.Equal(t, time.Duration(0), policy.delay(3, policy.Threshold))
+	require.Equal(t, time.Second, policy.delay(4, policy.Threshold))
+	require.Equal(t, 2*time.Second, policy.delay(5, policy.Threshold))
+	require.Equal(t, 4*time.Second, policy.delay(6, policy.Threshold))
+	require.Equal(t, 15*time.Minute, policy.delay(10, policy.Threshold))
+	require.Equal(t, time.Minute, policy.delay(50, policy.IPThreshold))
+}
+
+func TestLimiterCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	now := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().RecordFailure("account:fo@bo.com", now, time.Hour).Return(repo.LoginAttempts{Failures: 1}, nil)
+	attempts.EXPECT().RecordFailure("ip:10.0.0.1", now, time.Hour).Return(repo.LoginAttempts{Failures: 50, PreviousFailure: now.Add(-time.Second)}, nil)
+	limiter := NewLimiter(attempts, DefaultPolicy)
+	limiter.now = func() time.Time { return now }
+
+	wait, err := limiter.Check("Fo@Bo.com", "10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute-time.Second, wait)
+}
+
+func TestLimiterCheckLocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	now := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	failures := 0
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().RecordFailure(gomock.Any(), now, time.Hour).DoAndReturn(func(key string, at time.Time, window time.Duration) (repo.LoginAttempts, error) {
+		if key != "account:fo@bo.com" {
+			return repo.LoginAttempts{Failures: 1}, nil
+		}
+		failures++
+		return repo.LoginAttempts{Failures: failures, PreviousFailure: now}, nil
+	}).Times(2 * 5)
+	limiter := NewLimiter(attempts, DefaultPolicy)
+	limiter.now = func() time.Time { return now }
+
+	// A burst only gets the free attempts, the count tells the others apart
+	for i := 1; i <= 5; i++ {
+		wait, err := limiter.Check("fo@bo.com", "10.0.0.1")
+		require.NoError(t, err)
+		require.Equal(t, i > DefaultPolicy.FreeAttempts+1, wait > 0, "attempt %d", i)
+	}
+}
+
+func TestLimiterSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	attempts := repo.NewMockLoginAttemptRepository(ctrl)
+	attempts.EXPECT().Reset("account:fo@bo.com").Return(nil)
+	attempts.EXPECT().Forgive("ip:10.0.0.1").Return(nil)
+	attempts.EXPECT().Forgive("account:fo@bo.com").Return(nil)
+	attempts.EXPECT().Forgive("ip:10.0.0.2").Return(nil)
+	limiter := NewLimiter(attempts, DefaultPolicy)
+
+	require.NoError(t, limiter.Success("Fo@Bo.com", "10.0.0.1"))
+	require.NoError(t, limiter.Forgive("fo@bo.com", "10.0.0.2"))
+}
+
+func TestQuotaPolicy(t *testing.T) {
+	policy := QuotaPolicy(3, 20, time.Hour)
+	require.Equal(t, time.Duration(0), policy.delay(2, policy.Threshold))
+	require.Equal(t, time.Hour, policy.delay(3, policy.Threshold))
+	require.Equal(t, time.Duration(0), policy.delay(19, policy.IPThreshold))
+	require.Equal(t, time.Hour, policy.delay(20, policy.IPThreshold))
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidHeader = errors.New("invalid mail header")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender only logs emails, it is meant for development where no mail
+// server is available.
+type LogSender struct {
+	log *zap.Logger
+}
+
+func NewLogSender(log *zap.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	s.log.Info("Email", zap.String("to", msg.To), zap.String("subject", msg.Subject), zap.String("body", msg.Body))
+	return nil
+}
+
+// validate rejects header values that would inject further headers.
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn accepts a single mail transaction and sends what it received on the channel.
+func smtpStandIn(t *testing.T) (string, int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var transcript strings.Builder
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+			case "EHLO", "HELO":
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return host, portNumber, received
+}
+
+func TestSMTPSender(t *testing.T) {
+	host, port, received := smtpStandIn(t)
+	sender := NewSMTPSender(host, port, "", "", "go-user@bo.com")
+
+	err := sender.Send(Message{To: "fo@bo.com", Subject: "Verify your email ✓", Body: "Hello\nClick the link"})
+	require.NoError(t, err)
+	transcript := <-received
+	require.Contains(t, transcript, "MAIL FROM:<go-user@bo.com>")
+	require.Contains(t, transcript, "RCPT TO:<fo@bo.com>")
+	require.Contains(t, transcript, "To: fo@bo.com\r\n")
+	require.Contains(t, transcript, "Subject: =?utf-8?q?Verify_your_email_=E2=9C=93?=\r\n")
+	require.Contains(t, transcript, "\r\n\r\nHello\r\nClick the link")
+}
+
+func TestSendRejectsHeaderInjection(t *testing.T) {
+	sender := NewSMTPSender("127.0.0.1", 1, "", "", "go-user@bo.com")
+
+	err := sender.Send(Message{To: "fo@bo.com\r\nBcc: all@bo.com", Subject: "Hi"})
+	require.ErrorIs(t, err, ErrInvalidHeader)
+	err = sender.Send(Message{To: "fo@bo.com", Subject: "Hi\nBcc: all@bo.com"})
+	require.ErrorIs(t, err, ErrInvalidHeader)
+}
@@ -0,0 +1,216 @@
+// Package oidc implements both sides of OpenID Connect: signing the tokens
+// handed out to other apps when go-user acts as their provider, and logging
+// users in with an external provider.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Scopes clients can ask for, openid is required.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// Profile holds the standard claims about a user the granted scopes allow.
+type Profile struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+}
+
+// ProfileOf returns the claims about user that scopes allow.
+func ProfileOf(user repo.User, scopes []string) Profile {
+	var profile Profile
+	if slices.Contains(scopes, ScopeEmail) {
+		verified := user.EmailVerified
+		profile.Email, profile.EmailVerified = user.Email, &verified
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		profile.GivenName, profile.FamilyName = user.FirstName, user.LastName
+		profile.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	return profile
+}
+
+// IDClaims are the claims of ID tokens, their audience is the client.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	Profile
+}
+
+// AccessClaims are the claims of access tokens for the userinfo endpoint,
+// their audience is the issuer itself.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+	// Tenant is the tenant of the user.
+	Tenant string `json:"tid,omitempty"`
+}
+
+// Signer signs ID and access tokens with RS256, other apps verify them with
+// the keys published by JWKS.
+type Signer struct {
+	issuer string
+	key    *rsa.PrivateKey
+	kid    string
+}
+
+// NewSigner signs tokens as issuer, the URL go-user is reachable at.
+func NewSigner(issuer string, key *rsa.PrivateKey) (*Signer, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &Signer{issuer: issuer, key: key, kid: base64.RawURLEncoding.EncodeToString(sum[:12])}, nil
+}
+
+// GenerateKey returns a new signing key.
+func GenerateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+// LoadKey reads a PEM encoded PKCS #1 or PKCS #8 RSA private key.
+func LoadKey(file string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", file)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA key", file)
+	}
+	return key, nil
+}
+
+func (s *Signer) Issuer() string {
+	return s.issuer
+}
+
+// IDToken signs an ID token about user for the client, nonce is echoed from
+// the authorization request.
+func (s *Signer) IDToken(user repo.User, clientID, nonce string, scopes []string, authTime time.Time, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return s.sign(IDClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Nonce:    nonce,
+		AuthTime: jwt.NewNumericDate(authTime),
+		Profile:  ProfileOf(user, scopes),
+	})
+}
+
+// AccessToken signs a token the client can read the userinfo of the user with.
+func (s *Signer) AccessToken(user repo.User, clientID string, scope string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return s.sign(AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{s.issuer},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		ClientID: clientID,
+		Scope:    scope,
+		Tenant:   user.TenantID,
+	})
+}
+
+// VerifyAccessToken accepts the access tokens signed by AccessToken only, ID
+// tokens are meant for the clients.
+func (s *Signer) VerifyAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return &s.key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}), jwt.WithIssuer(s.issuer), jwt.WithAudience(s.issuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (s *Signer) sign(claims jwt.Claims) (string, error) {
+	signed := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	signed.Header["kid"] = s.kid
+	return signed.SignedString(s.key)
+}
+
+// JWK is the public signing key as a JSON Web Key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the keys tokens can be verified with.
+func (s *Signer) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Name,
+		KeyID:     s.kid,
+		Modulus:   base64.RawURLEncoding.EncodeToString(s.key.PublicKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.PublicKey.E)).Bytes()),
+	}}}
+}
+
+// VerifyPKCE reports whether verifier is the one the S256 challenge was
+// derived from, see RFC 7636.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(Challenge(verifier)), []byte(challenge)) == 1
+}
@@ -0,0 +1,116 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newSigner(t *testing.T) *Signer {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	signer, err := NewSigner("http://localhost:8080", key)
+	require.NoError(t, err)
+	return signer
+}
+
+func TestIDToken(t *testing.T) {
+	signer := newSigner(t)
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", FirstName: "Fo", LastName: "Bo", EmailVerified: true}
+	signed, err := signer.IDToken(user, "client-1", "n-1", []string{ScopeOpenID, ScopeProfile}, time.Now(), time.Hour)
+	require.NoError(t, err)
+
+	// Verified with the published key only
+	jwk := signer.JWKS().Keys[0]
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	require.NoError(t, err)
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+	require.NoError(t, err)
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	claims := &IDClaims{}
+	parsed, err := jwt.ParseWithClaims(signed, claims, func(t *jwt.Token) (interface{}, error) {
+		return public, nil
+	}, jwt.WithAudience("client-1"), jwt.WithIssuer("http://localhost:8080"))
+	require.NoError(t, err)
+	require.Equal(t, jwk.KeyID, parsed.Header["kid"])
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, "n-1", claims.Nonce)
+	require.Equal(t, "Fo Bo", claims.Name)
+	require.Equal(t, "Bo", claims.FamilyName)
+	// Not granted the email scope
+	require.Empty(t, claims.Email)
+
+	// ID tokens are not access tokens
+	_, err = signer.VerifyAccessToken(signed)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAccessToken(t *testing.T) {
+	signer := newSigner(t)
+	signed, err := signer.AccessToken(repo.User{ID: "user-1", TenantID: "acme"}, "client-1", "openid email", time.Hour)
+	require.NoError(t, err)
+
+	claims, err := signer.VerifyAccessToken(signed)
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, "client-1", claims.ClientID)
+	require.Equal(t, "openid email", claims.Scope)
+	require.Equal(t, "acme", claims.Tenant)
+
+	_, err = newSigner(t).VerifyAccessToken(signed)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	expired, err := signer.AccessToken(repo.User{ID: "user-1"}, "client-1", "openid", -time.Minute)
+	require.NoError(t, err)
+	_, err = signer.VerifyAccessToken(expired)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestProfileOf(t *testing.T) {
+	user := repo.User{ID: "user-1", Email: "fo@bo.com", FirstName: "Fo"}
+	profile := ProfileOf(user, []string{ScopeOpenID, ScopeEmail, ScopeProfile})
+	require.Equal(t, "fo@bo.com", profile.Email)
+	require.False(t, *profile.EmailVerified)
+	require.Equal(t, "Fo", profile.Name)
+	require.Equal(t, Profile{}, ProfileOf(user, []string{ScopeOpenID}))
+}
+
+func TestLoadKey(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "key.pem")
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	loaded, err := LoadKey(file)
+	require.NoError(t, err)
+	require.True(t, key.Equal(loaded))
+
+	require.NoError(t, os.WriteFile(file, []byte("not a key"), 0o600))
+	_, err = LoadKey(file)
+	require.Error(t, err)
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	require.True(t, VerifyPKCE(verifier, challenge))
+	require.False(t, VerifyPKCE(strings.Repeat("w", 43), challenge))
+	// Too short to be a verifier
+	short := sha256.Sum256([]byte("v"))
+	require.False(t, VerifyPKCE("v", base64.RawURLEncoding.EncodeToString(short[:])))
+}
@@ -0,0 +1,130 @@
+// Package oidctest provides an OpenID Connect provider for tests of apps
+// logging users in with one.
+package oidctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/repo"
+)
+
+// Issuer serves discovery, keys and the token endpoint of a provider with a
+// single client. Users log in with Login instead of an authorization page.
+type Issuer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu     sync.Mutex
+	signer *oidc.Signer
+	grants map[string]grant
+}
+
+type grant struct {
+	user        repo.User
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// NewIssuer starts an issuer that is closed when the test ends.
+func NewIssuer(t testing.TB, clientID, clientSecret string) *Issuer {
+	issuer := &Issuer{ClientID: clientID, ClientSecret: clientSecret, grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	issuer.RotateKey(t)
+	return issuer
+}
+
+// RotateKey signs the following ID tokens with a new key.
+func (i *Issuer) RotateKey(t testing.TB) {
+	key, err := oidc.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := oidc.NewSigner(i.URL, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.signer = signer
+}
+
+// Login logs user in at the authorization URL and returns the query the user
+// is sent back to the redirect URI with, the code and the state.
+func (i *Issuer) Login(authURL string, user repo.User) (url.Values, error) {
+	link, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	query := link.Query()
+	if query.Get("client_id") != i.ClientID {
+		return nil, fmt.Errorf("unknown client %q", query.Get("client_id"))
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		return nil, fmt.Errorf("unsupported code challenge method %q", query.Get("code_challenge_method"))
+	}
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.grants[code] = grant{user: user, nonce: query.Get("nonce"), challenge: query.Get("code_challenge"), redirectURI: query.Get("redirect_uri")}
+	return url.Values{"code": {code}, "state": {query.Get("state")}}, nil
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	writeJSON(w, http.StatusOK, i.signer.JWKS())
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if clientID != i.ClientID || secret != i.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	grant, ok := i.grants[r.FormValue("code")]
+	delete(i.grants, r.FormValue("code"))
+	if !ok || grant.redirectURI != r.FormValue("redirect_uri") || !oidc.VerifyPKCE(r.FormValue("code_verifier"), grant.challenge) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	idToken, err := i.signer.IDToken(grant.user, i.ClientID, grant.nonce, oidc.Scopes, time.Now(), time.Minute)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
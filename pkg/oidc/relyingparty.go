@@ -0,0 +1,227 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrLoginFailed is returned when the provider rejects the code or hands out
+// an ID token that can not be trusted.
+var ErrLoginFailed = errors.New("external login failed")
+
+// RelyingPartyConfig names the external provider users log in with and how
+// go-user is registered there.
+type RelyingPartyConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page the provider sends users back to, it passes
+	// the code and state on to go-user.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Identity is what an external provider tells about a user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// RelyingParty logs users in with an external OpenID Connect provider using
+// the authorization code flow with PKCE. The provider is discovered on first
+// use and its keys are fetched again when a token names an unknown one.
+type RelyingParty struct {
+	config RelyingPartyConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *discovery
+	keys     map[string]*rsa.PublicKey
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewRelyingParty(config RelyingPartyConfig, client *http.Client) *RelyingParty {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &RelyingParty{config: config, client: client}
+}
+
+func (rp *RelyingParty) Issuer() string {
+	return rp.config.Issuer
+}
+
+// AuthCodeURL returns the page of the provider users log in at, state and
+// nonce are echoed back and verifier is the PKCE code verifier.
+func (rp *RelyingParty) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	metadata, err := rp.discover()
+	if err != nil {
+		return "", err
+	}
+	link, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("client_id", rp.config.ClientID)
+	query.Set("redirect_uri", rp.config.RedirectURL)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(rp.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// Exchange redeems the code and returns the identity in the verified ID
+// token, which must carry the nonce of the authorization request.
+func (rp *RelyingParty) Exchange(code, verifier, nonce string) (Identity, error) {
+	metadata, err := rp.discover()
+	if err != nil {
+		return Identity{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(rp.config.ClientID), url.QueryEscape(rp.config.ClientSecret))
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer res.Body.Close()
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return Identity{}, fmt.Errorf("decoding token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%w: %s %s", ErrLoginFailed, tokens.Error, tokens.ErrorDescription)
+	}
+
+	claims := &struct {
+		jwt.RegisteredClaims
+		Nonce         string `json:"nonce"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+	}{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return rp.key(kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}), jwt.WithIssuer(metadata.Issuer), jwt.WithAudience(rp.config.ClientID), jwt.WithExpirationRequired())
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrLoginFailed, err)
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce does not match", ErrLoginFailed)
+	}
+	return Identity{
+		Issuer:        metadata.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}, nil
+}
+
+func (rp *RelyingParty) discover() (*discovery, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.metadata != nil {
+		return rp.metadata, nil
+	}
+	metadata := &discovery{}
+	if err := rp.get(rp.config.Issuer+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", rp.config.Issuer, err)
+	}
+	if metadata.Issuer != rp.config.Issuer {
+		return nil, fmt.Errorf("discovering %s: provider names itself %s", rp.config.Issuer, metadata.Issuer)
+	}
+	rp.metadata = metadata
+	return metadata, nil
+}
+
+// key returns the signing key with the ID, fetching the keys of the provider
+// again if it is not known yet.
+func (rp *RelyingParty) key(kid string) (*rsa.PublicKey, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if key, ok := rp.keys[kid]; ok {
+		return key, nil
+	}
+	var jwks JWKSet
+	if err := rp.get(rp.metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching keys: %w", err)
+	}
+	rp.keys = map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "RSA" {
+			continue
+		}
+		modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+		if err != nil {
+			continue
+		}
+		exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+		if err != nil {
+			continue
+		}
+		rp.keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	}
+	if key, ok := rp.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (rp *RelyingParty) get(link string, v interface{}) error {
+	res, err := rp.client.Get(link)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Challenge derives the S256 PKCE challenge from verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
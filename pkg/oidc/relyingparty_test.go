@@ -0,0 +1,81 @@
+package oidc_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Davut97/go-user/pkg/oidc"
+	"github.com/Davut97/go-user/pkg/oidc/oidctest"
+	"github.com/Davut97/go-user/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelyingParty(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "go-user", "secret")
+	rp := oidc.NewRelyingParty(oidc.RelyingPartyConfig{
+		Issuer:       issuer.URL + "/",
+		ClientID:     "go-user",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/login/callback",
+		Scopes:       []string{"openid", "email"},
+	}, http.DefaultClient)
+	verifier := strings.Repeat("v", 43)
+	user := repo.User{ID: "ext-1", Email: "fo@bo.com", EmailVerified: true, FirstName: "Fo"}
+
+	authURL, err := rp.AuthCodeURL("s-1", "n-1", verifier)
+	require.NoError(t, err)
+	link, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, issuer.URL+"/authorize", link.Scheme+"://"+link.Host+link.Path)
+	require.Equal(t, "openid email", link.Query().Get("scope"))
+	require.Equal(t, oidc.Challenge(verifier), link.Query().Get("code_challenge"))
+
+	callback, err := issuer.Login(authURL, user)
+	require.NoError(t, err)
+	require.Equal(t, "s-1", callback.Get("state"))
+	identity, err := rp.Exchange(callback.Get("code"), verifier, "n-1")
+	require.NoError(t, err)
+	require.Equal(t, oidc.Identity{Issuer: issuer.URL, Subject: "ext-1", Email: "fo@bo.com", EmailVerified: true, GivenName: "Fo"}, identity)
+
+	// Codes are single-use
+	_, err = rp.Exchange(callback.Get("code"), verifier, "n-1")
+	require.ErrorIs(t, err, oidc.ErrLoginFailed)
+
+	// Keys are fetched again once the provider rotated them
+	issuer.RotateKey(t)
+	callback, err = issuer.Login(authURL, user)
+	require.NoError(t, err)
+	_, err = rp.Exchange(callback.Get("code"), verifier, "n-1")
+	require.NoError(t, err)
+
+	callback, err = issuer.Login(authURL, user)
+	require.NoError(t, err)
+	_, err = rp.Exchange(callback.Get("code"), verifier, "n-2")
+	require.ErrorIs(t, err, oidc.ErrLoginFailed)
+	callback, err = issuer.Login(authURL, user)
+	require.NoError(t, err)
+	_, err = rp.Exchange(callback.Get("code"), strings.Repeat("w", 43), "n-1")
+	require.ErrorIs(t, err, oidc.ErrLoginFailed)
+}
+
+func TestRelyingPartyWrongSecret(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "go-user", "secret")
+	rp := oidc.NewRelyingParty(oidc.RelyingPartyConfig{Issuer: issuer.URL, ClientID: "go-user", ClientSecret: "guessed"}, http.DefaultClient)
+	verifier := strings.Repeat("v", 43)
+
+	authURL, err := rp.AuthCodeURL("s-1", "n-1", verifier)
+	require.NoError(t, err)
+	callback, err := issuer.Login(authURL, repo.User{ID: "ext-1"})
+	require.NoError(t, err)
+	_, err = rp.Exchange(callback.Get("code"), verifier, "n-1")
+	require.ErrorIs(t, err, oidc.ErrLoginFailed)
+}
+
+func TestRelyingPartyUnavailable(t *testing.T) {
+	rp := oidc.NewRelyingParty(oidc.RelyingPartyConfig{Issuer: "http://127.0.0.1:1"}, http.DefaultClient)
+	_, err := rp.AuthCodeURL("s-1", "n-1", strings.Repeat("v", 43))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, oidc.ErrLoginFailed)
+}
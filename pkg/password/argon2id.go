@@ -0,0 +1,139 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidArgon2idHash = errors.New("invalid argon2id hash")
+
+type Argon2idParams struct {
+	// Memory is the amount of memory used in KiB.
+	Memory      uint32
+	Time        uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the recommendations of RFC 9106 for memory
+// constrained environments.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Time:        3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2id produces PHC strings such as
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash> with unpadded base64 salt and
+// hash. The pepper key ID is recorded in the keyid parameter.
+type Argon2id struct {
+	params Argon2idParams
+}
+
+type argon2idHash struct {
+	params Argon2idParams
+	keyID  string
+	salt   []byte
+	key    []byte
+}
+
+func NewArgon2id(params Argon2idParams) *Argon2id {
+	return &Argon2id{params: params}
+}
+
+func (a *Argon2id) Hash(password, keyID string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.params.Time, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+	return encodeArgon2id(argon2idHash{params: a.params, keyID: keyID, salt: salt, key: key}), nil
+}
+
+func (a *Argon2id) Verify(password, encoded string) (bool, error) {
+	h, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), h.salt, h.params.Time, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return subtle.ConstantTimeCompare(h.key, other) == 1, nil
+}
+
+func (a *Argon2id) KeyID(encoded string) string {
+	h, _ := decodeArgon2id(encoded)
+	return h.keyID
+}
+
+func (a *Argon2id) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) Outdated(encoded string) bool {
+	h, err := decodeArgon2id(encoded)
+	return err != nil || h.params != a.params
+}
+
+func encodeArgon2id(h argon2idHash) string {
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.params.Memory, h.params.Time, h.params.Parallelism)
+	if h.keyID != "" {
+		params += ",keyid=" + h.keyID
+	}
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(h.salt),
+		base64.RawStdEncoding.EncodeToString(h.key))
+}
+
+func decodeArgon2id(encoded string) (argon2idHash, error) {
+	var h argon2idHash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" || parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return h, ErrInvalidArgon2idHash
+	}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		if name == "keyid" {
+			h.keyID = value
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return h, ErrInvalidArgon2idHash
+		}
+		switch name {
+		case "m":
+			h.params.Memory = uint32(n)
+		case "t":
+			h.params.Time = uint32(n)
+		case "p":
+			if n > 255 {
+				return h, ErrInvalidArgon2idHash
+			}
+			h.params.Parallelism = uint8(n)
+		default:
+			return h, ErrInvalidArgon2idHash
+		}
+	}
+	if h.params.Memory == 0 || h.params.Time == 0 || h.params.Parallelism == 0 {
+		return h, ErrInvalidArgon2idHash
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return h, ErrInvalidArgon2idHash
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return h, ErrInvalidArgon2idHash
+	}
+	h.params.SaltLength = uint32(len(h.salt))
+	h.params.KeyLength = uint32(len(h.key))
+	return h, nil
+}
@@ -0,0 +1,83 @@
+package password
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultBcryptCost = 12
+	bcryptKeyID       = "$keyid="
+	bcryptPrehashed   = "$sha256"
+	bcryptMaxLength   = 72
+)
+
+// Bcrypt produces modular crypt strings such as $2a$12$<salt+hash>, followed
+// by $keyid=<id> when the password was peppered. Bcrypt refuses passwords
+// longer than 72 bytes, so longer ones are hashed with SHA-256 first and
+// marked with $sha256. Peppered passwords are always shorter.
+type Bcrypt struct {
+	cost int
+}
+
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
+func (b *Bcrypt) Hash(password, keyID string) (string, error) {
+	long := len(password) > bcryptMaxLength
+	if long {
+		password = prehash(password)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	if keyID != "" {
+		return string(bytes) + bcryptKeyID + keyID, nil
+	}
+	if long {
+		return string(bytes) + bcryptPrehashed, nil
+	}
+	return string(bytes), nil
+}
+
+func (b *Bcrypt) Verify(password, encoded string) (bool, error) {
+	encoded, prehashed := strings.CutSuffix(encoded, bcryptPrehashed)
+	if prehashed {
+		password = prehash(password)
+	}
+	encoded, _, _ = strings.Cut(encoded, bcryptKeyID)
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Bcrypt) KeyID(encoded string) string {
+	_, keyID, _ := strings.Cut(encoded, bcryptKeyID)
+	return keyID
+}
+
+func (b *Bcrypt) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b *Bcrypt) Outdated(encoded string) bool {
+	encoded, _ = strings.CutSuffix(encoded, bcryptPrehashed)
+	encoded, _, _ = strings.Cut(encoded, bcryptKeyID)
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.cost
+}
+
+// prehash returns the base64 encoded SHA-256 of password, which fits into
+// the 72 bytes bcrypt hashes.
+func prehash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sha1HexLen = 40
+	// rangePrefixLen is the length of the hash prefixes range files are named by.
+	rangePrefixLen = 5
+)
+
+// BreachedList is a local breached password list, which holds files open
+// until it is closed.
+type BreachedList interface {
+	Breached
+	io.Closer
+}
+
+// OpenBreached opens a directory of range files with OpenBreachedRanges and
+// a single file with OpenBreachedHashes.
+func OpenBreached(path string) (BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return OpenBreachedRanges(path)
+	}
+	return OpenBreachedHashes(path)
+}
+
+// BreachedHashes looks passwords up in a local copy of a breached password
+// list such as the single file download of Have I Been Pwned: the upper case
+// SHA-1 hashes of the passwords, optionally followed by :count, one per line
+// and sorted. The file is searched on disk, so it is never loaded into memory
+// and passwords never leave the server.
+type BreachedHashes struct {
+	file *os.File
+	size int64
+}
+
+func OpenBreachedHashes(path string) (*BreachedHashes, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &BreachedHashes{file: file, size: info.Size()}, nil
+}
+
+// Contains binary searches the file for the hash of password.
+func (b *BreachedHashes) Contains(password string) (bool, error) {
+	hash := sha1Hex(password)
+	// Only lines starting in [lo, hi) are left to search, lo is always the start of a line.
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := b.lineStart(mid)
+		if err != nil {
+			return false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		line, err := b.line(start)
+		if err != nil {
+			return false, err
+		}
+		lineHash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if len(lineHash) != sha1HexLen {
+			return false, fmt.Errorf("invalid breached password hash at offset %d", start)
+		}
+		switch strings.Compare(strings.ToUpper(lineHash), hash) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+func (b *BreachedHashes) Close() error {
+	return b.file.Close()
+}
+
+// lineStart returns the offset of the first line starting at or after offset.
+func (b *BreachedHashes) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	skipped, err := b.line(offset - 1)
+	if err != nil {
+		return 0, err
+	}
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// line returns the line from offset up to and including its new line.
+func (b *BreachedHashes) line(offset int64) (string, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(b.file, offset, b.size-offset)).ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return line, err
+}
+
+// BreachedRanges looks passwords up in a local copy of the k-anonymity range
+// files of Have I Been Pwned, as downloaded by its PwnedPasswordsDownloader:
+// a directory with one file per 5 character prefix of the upper case SHA-1
+// hashes, named like 21BD1.txt and holding the remaining 35 characters of the
+// hashes followed by :count, one per line. Only the file of the prefix of a
+// password is read.
+type BreachedRanges struct {
+	dir string
+}
+
+func OpenBreachedRanges(dir string) (*BreachedRanges, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory of range files", dir)
+	}
+	return &BreachedRanges{dir: dir}, nil
+}
+
+// Contains scans the range file of the hash prefix of password for its
+// suffix, a prefix without a file has no breached passwords.
+func (b *BreachedRanges) Contains(password string) (bool, error) {
+	hash := sha1Hex(password)
+	file, err := os.Open(filepath.Join(b.dir, hash[:rangePrefixLen]+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(suffix, hash[rangePrefixLen:]) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// Close does nothing, range files are only open while they are read.
+func (b *BreachedRanges) Close() error {
+	return nil
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,74 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreachedHashes(t *testing.T) {
+	breached := []string{"hunter2", "correct horse", "tr0ub4dor&3", "letmein", "p@ssw0rd", "dragon"}
+	var lines []string
+	for _, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")), 0o600))
+	hashes, err := OpenBreachedHashes(path)
+	require.NoError(t, err)
+	defer hashes.Close()
+
+	for _, password := range breached {
+		found, err := hashes.Contains(password)
+		require.NoError(t, err)
+		require.True(t, found, password)
+	}
+	for _, password := range []string{"", "correct horse battery staple", "hunter3"} {
+		found, err := hashes.Contains(password)
+		require.NoError(t, err)
+		require.False(t, found, password)
+	}
+
+	err = DefaultPolicy.Check("hunter2", "fo@bo.com")
+	require.NotErrorIs(t, err, ErrBreached)
+	policy := DefaultPolicy
+	policy.Breached = hashes
+	require.ErrorIs(t, policy.Check("tr0ub4dor&3"), ErrBreached)
+}
+
+func TestBreachedRanges(t *testing.T) {
+	breached := []string{"hunter2", "correct horse", "letmein"}
+	dir := t.TempDir()
+	for _, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+		lines := []string{strings.Repeat("0", 35) + ":1", hash[5:] + ":42", strings.Repeat("F", 35) + ":7"}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(strings.Join(lines, "\r\n")), 0o600))
+	}
+	ranges, err := OpenBreached(dir)
+	require.NoError(t, err)
+	require.IsType(t, &BreachedRanges{}, ranges)
+	defer ranges.Close()
+
+	for _, password := range breached {
+		found, err := ranges.Contains(password)
+		require.NoError(t, err)
+		require.True(t, found, password)
+	}
+	for _, password := range []string{"", "correct horse battery staple", "hunter3"} {
+		found, err := ranges.Contains(password)
+		require.NoError(t, err)
+		require.False(t, found, password)
+	}
+
+	_, err = OpenBreachedRanges(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
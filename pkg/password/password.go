@@ -0,0 +1,134 @@
+package password
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// Algorithm hashes passwords into one encoded format and verifies them.
+type Algorithm interface {
+	// Hash hashes password and records keyID, the ID of the pepper key the
+	// password was peppered with if any, in the encoded hash.
+	Hash(password, keyID string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// KeyID returns the pepper key ID recorded in encoded.
+	KeyID(encoded string) string
+	// Supports reports whether encoded was produced by this algorithm.
+	Supports(encoded string) bool
+	// Outdated reports whether encoded was produced with other parameters
+	// than the ones the algorithm currently hashes with.
+	Outdated(encoded string) bool
+}
+
+// Hasher hashes new passwords with the current algorithm and verifies hashes
+// of any of its algorithms, so that hashes made with an algorithm or
+// parameters that are no longer current keep working until they are replaced.
+type Hasher struct {
+	current    Algorithm
+	algorithms []Algorithm
+	peppers    *Peppers
+	dummy      *dummyHash
+}
+
+// dummyHash is a hash of a random password, made the first time it is needed.
+type dummyHash struct {
+	once    sync.Once
+	encoded string
+	err     error
+}
+
+func NewHasher(current Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{current: current, algorithms: append([]Algorithm{current}, legacy...), dummy: &dummyHash{}}
+}
+
+// NewDefaultHasher hashes with argon2id using DefaultArgon2idParams and still
+// verifies bcrypt hashes.
+func NewDefaultHasher() *Hasher {
+	return NewHasher(NewArgon2id(DefaultArgon2idParams), NewBcrypt(DefaultBcryptCost))
+}
+
+// NewHasherFor hashes with the named algorithm, either "argon2id" or
+// "bcrypt", and still verifies hashes of the other one.
+func NewHasherFor(algorithm string, params Argon2idParams, bcryptCost int) (*Hasher, error) {
+	argon2id, bcrypt := NewArgon2id(params), NewBcrypt(bcryptCost)
+	switch algorithm {
+	case "argon2id":
+		return NewHasher(argon2id, bcrypt), nil
+	case "bcrypt":
+		return NewHasher(bcrypt, argon2id), nil
+	}
+	return nil, fmt.Errorf("unknown password hashing algorithm %q", algorithm)
+}
+
+// WithPeppers returns a copy of the hasher that peppers new hashes with the
+// current key of peppers.
+func (h *Hasher) WithPeppers(peppers *Peppers) *Hasher {
+	return &Hasher{current: h.current, algorithms: h.algorithms, peppers: peppers, dummy: &dummyHash{}}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.peppers == nil {
+		return h.current.Hash(password, "")
+	}
+	peppered, err := h.peppers.apply(h.peppers.current, password)
+	if err != nil {
+		return "", err
+	}
+	return h.current.Hash(peppered, h.peppers.current)
+}
+
+// Verify checks password against encoded, peppering it with the key recorded
+// in encoded. When it matches, rehash reports whether encoded should be
+// replaced by a fresh hash of the password.
+func (h *Hasher) Verify(password, encoded string) (match, rehash bool, err error) {
+	for _, algorithm := range h.algorithms {
+		if !algorithm.Supports(encoded) {
+			continue
+		}
+		keyID := algorithm.KeyID(encoded)
+		if keyID != "" {
+			if h.peppers == nil {
+				return false, false, fmt.Errorf("%w: %q", ErrUnknownPepper, keyID)
+			}
+			if password, err = h.peppers.apply(keyID, password); err != nil {
+				return false, false, err
+			}
+		}
+		match, err = algorithm.Verify(password, encoded)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, algorithm != h.current || h.current.Outdated(encoded) || keyID != h.currentKeyID(), nil
+	}
+	return false, false, ErrUnsupportedHash
+}
+
+// VerifyDummy verifies password against a hash of a random password with the
+// current algorithm and pepper. It never matches and takes as long as Verify,
+// so that callers can hide whether there was a hash to verify at all.
+func (h *Hasher) VerifyDummy(password string) error {
+	h.dummy.once.Do(func() {
+		random := make([]byte, 32)
+		if _, h.dummy.err = rand.Read(random); h.dummy.err != nil {
+			return
+		}
+		h.dummy.encoded, h.dummy.err = h.Hash(base64.RawStdEncoding.EncodeToString(random))
+	})
+	if h.dummy.err != nil {
+		return h.dummy.err
+	}
+	_, _, err := h.Verify(password, h.dummy.encoded)
+	return err
+}
+
+func (h *Hasher) currentKeyID() string {
+	if h.peppers == nil {
+		return ""
+	}
+	return h.peppers.current
+}
@@ -0,0 +1,140 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testParams = Argon2idParams{Memory: 1024, Time: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	argon2id := NewArgon2id(testParams)
+	encoded, err := argon2id.Hash("correct horse battery staple", "")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	require.True(t, argon2id.Supports(encoded))
+	require.False(t, argon2id.Outdated(encoded))
+
+	match, err := argon2id.Verify("correct horse battery staple", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+
+	match, err = argon2id.Verify("wrong", encoded)
+	require.NoError(t, err)
+	require.False(t, match)
+
+	stronger := testParams
+	stronger.Time = 2
+	require.True(t, NewArgon2id(stronger).Outdated(encoded))
+
+	_, err = argon2id.Verify("x", "$argon2id$v=19$m=1024$broken")
+	require.ErrorIs(t, err, ErrInvalidArgon2idHash)
+}
+
+func TestArgon2idLongPassword(t *testing.T) {
+	argon2id := NewArgon2id(testParams)
+	long := strings.Repeat("a", 100)
+	encoded, err := argon2id.Hash(long, "")
+	require.NoError(t, err)
+
+	// Unlike bcrypt the bytes after the 72nd one count
+	match, err := argon2id.Verify(strings.Repeat("a", 99)+"b", encoded)
+	require.NoError(t, err)
+	require.False(t, match)
+}
+
+func TestBcryptLongPassword(t *testing.T) {
+	bcrypt := NewBcrypt(4)
+	long := strings.Repeat("a", 100)
+	encoded, err := bcrypt.Hash(long, "")
+	require.NoError(t, err)
+	require.True(t, bcrypt.Supports(encoded))
+	require.False(t, bcrypt.Outdated(encoded))
+
+	match, err := bcrypt.Verify(long, encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	// The bytes after the 72nd one count too
+	match, err = bcrypt.Verify(strings.Repeat("a", 99)+"b", encoded)
+	require.NoError(t, err)
+	require.False(t, match)
+}
+
+func TestHasherRehash(t *testing.T) {
+	legacy := NewBcrypt(4)
+	encoded, err := legacy.Hash("secret-password", "")
+	require.NoError(t, err)
+	hasher := NewHasher(NewArgon2id(testParams), legacy)
+
+	match, rehash, err := hasher.Verify("secret-password", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+
+	match, rehash, err = hasher.Verify("wrong-password", encoded)
+	require.NoError(t, err)
+	require.False(t, match)
+	require.False(t, rehash)
+
+	encoded, err = hasher.Hash("secret-password")
+	require.NoError(t, err)
+	match, rehash, err = hasher.Verify("secret-password", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, rehash)
+
+	_, _, err = hasher.Verify("secret-password", "plain")
+	require.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestBcryptCostOutdated(t *testing.T) {
+	hasher := NewHasher(NewBcrypt(5))
+	encoded, err := NewBcrypt(4).Hash("secret-password", "")
+	require.NoError(t, err)
+
+	match, rehash, err := hasher.Verify("secret-password", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+}
+
+func TestNewHasherFor(t *testing.T) {
+	hasher, err := NewHasherFor("bcrypt", testParams, 4)
+	require.NoError(t, err)
+	encoded, err := hasher.Hash("secret-password")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encoded, "$2a$04$"))
+
+	_, err = NewHasherFor("md5", testParams, 4)
+	require.Error(t, err)
+}
+
+func TestHasherVerifyDummy(t *testing.T) {
+	hasher := NewHasher(NewArgon2id(testParams))
+	require.NoError(t, hasher.VerifyDummy("secret-password"))
+	require.NoError(t, hasher.VerifyDummy(""))
+	require.True(t, strings.HasPrefix(hasher.dummy.encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+}
+
+func TestPolicy(t *testing.T) {
+	policy := DefaultPolicy
+	require.NoError(t, policy.Check("correct horse battery staple", "fo@bo.com", "Foo", "Bar"))
+	err := policy.Check("short", "fo@bo.com")
+	require.ErrorIs(t, err, ErrWeakPassword)
+	require.ErrorIs(t, err, ErrTooShort)
+	require.ErrorIs(t, policy.Check(strings.Repeat("a", 129)), ErrTooLong)
+	require.ErrorIs(t, policy.Check("Password123"), ErrCommon)
+	require.ErrorIs(t, policy.Check("lucky-fox-jumps", "lucky@bo.com"), ErrPersonal)
+	require.ErrorIs(t, policy.Check("my name is mcmuffin", "fo@bo.com", "Lucky", "McMuffin"), ErrPersonal)
+	// Lengths are counted in characters
+	require.NoError(t, policy.Check("ğüşiöçĞÜ"))
+
+	policy.RequireUpper, policy.RequireDigit, policy.RequireSymbol = true, true, true
+	err = policy.Check("correct horse battery staple")
+	require.ErrorIs(t, err, ErrMissingUpper)
+	require.ErrorIs(t, err, ErrMissingDigit)
+	require.ErrorIs(t, err, ErrMissingSymbol)
+	require.NoError(t, policy.Check("Correct horse battery staple 4!"))
+}
@@ -0,0 +1,91 @@
+package password
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrUnknownPepper = errors.New("unknown pepper key")
+	validKeyID       = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+)
+
+// Peppers holds the server side secrets that are mixed into passwords before
+// hashing. Every hash records the ID of the key it was peppered with so that
+// keys can be rotated: new hashes use the current key, older keys are kept to
+// verify existing hashes until they are upgraded on login.
+type Peppers struct {
+	current string
+	keys    map[string][]byte
+}
+
+func NewPeppers(current string, keys map[string][]byte) (*Peppers, error) {
+	for id, key := range keys {
+		if !validKeyID.MatchString(id) {
+			return nil, fmt.Errorf("invalid pepper key ID %q", id)
+		}
+		if len(key) < 16 {
+			return nil, fmt.Errorf("pepper key %q must be at least 16 bytes long", id)
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: current key %q", ErrUnknownPepper, current)
+	}
+	return &Peppers{current: current, keys: keys}, nil
+}
+
+// ParsePeppers parses keys written as id:base64key, separated by commas or new lines.
+func ParsePeppers(current, spec string) (*Peppers, error) {
+	keys := map[string][]byte{}
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if entry = strings.TrimSpace(entry); entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("pepper key %q is not written as id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("pepper key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return NewPeppers(current, keys)
+}
+
+// LoadPeppers reads keys from a file, such as a mounted secret, with one id:base64key per line.
+func LoadPeppers(current, path string) (*Peppers, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ParsePeppers(current, strings.Join(lines, "\n"))
+}
+
+// apply returns the base64 encoded HMAC-SHA256 of password under the key with the given ID.
+func (p *Peppers) apply(keyID, password string) (string, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownPepper, keyID)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = base64.StdEncoding.EncodeToString([]byte("old-pepper-key-0123456789"))
+	newKey = base64.StdEncoding.EncodeToString([]byte("new-pepper-key-0123456789"))
+)
+
+func TestPepperRotation(t *testing.T) {
+	for _, algorithm := range []Algorithm{NewArgon2id(testParams), NewBcrypt(4)} {
+		oldPeppers, err := ParsePeppers("v1", "v1:"+oldKey)
+		require.NoError(t, err)
+		oldHasher := NewHasher(algorithm).WithPeppers(oldPeppers)
+		encoded, err := oldHasher.Hash("secret-password")
+		require.NoError(t, err)
+		require.Equal(t, "v1", algorithm.KeyID(encoded))
+
+		// The pepper is a secret, the hash is useless without it
+		_, _, err = NewHasher(algorithm).Verify("secret-password", encoded)
+		require.ErrorIs(t, err, ErrUnknownPepper)
+
+		// After rotating to v2 the v1 hash still verifies but has to be replaced
+		peppers, err := ParsePeppers("v2", "v1:"+oldKey+",v2:"+newKey)
+		require.NoError(t, err)
+		hasher := NewHasher(algorithm).WithPeppers(peppers)
+		match, rehash, err := hasher.Verify("secret-password", encoded)
+		require.NoError(t, err)
+		require.True(t, match)
+		require.True(t, rehash)
+
+		match, _, err = hasher.Verify("wrong-password", encoded)
+		require.NoError(t, err)
+		require.False(t, match)
+
+		encoded, err = hasher.Hash("secret-password")
+		require.NoError(t, err)
+		require.Equal(t, "v2", algorithm.KeyID(encoded))
+		match, rehash, err = hasher.Verify("secret-password", encoded)
+		require.NoError(t, err)
+		require.True(t, match)
+		require.False(t, rehash)
+	}
+}
+
+func TestPepperAddedLater(t *testing.T) {
+	encoded, err := NewHasher(NewArgon2id(testParams)).Hash("secret-password")
+	require.NoError(t, err)
+	require.False(t, strings.Contains(encoded, "keyid"))
+
+	peppers, err := ParsePeppers("v1", "v1:"+oldKey)
+	require.NoError(t, err)
+	match, rehash, err := NewHasher(NewArgon2id(testParams)).WithPeppers(peppers).Verify("secret-password", encoded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+}
+
+func TestLoadPeppers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peppers")
+	require.NoError(t, os.WriteFile(path, []byte("# rotated 2023-11\nv1:"+oldKey+"\nv2:"+newKey+"\n"), 0o600))
+
+	peppers, err := LoadPeppers("v2", path)
+	require.NoError(t, err)
+	require.Len(t, peppers.keys, 2)
+
+	_, err = LoadPeppers("v3", path)
+	require.ErrorIs(t, err, ErrUnknownPepper)
+
+	_, err = ParsePeppers("v1", "v1:"+base64.StdEncoding.EncodeToString([]byte("short")))
+	require.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrWeakPassword is joined with every error about a password breaking the policy.
+	ErrWeakPassword    = errors.New("password does not follow the policy")
+	ErrTooShort        = errors.New("password is too short")
+	ErrTooLong         = errors.New("password is too long")
+	ErrMissingUpper    = errors.New("password must contain an upper case letter")
+	ErrMissingLower    = errors.New("password must contain a lower case letter")
+	ErrMissingDigit    = errors.New("password must contain a digit")
+	ErrMissingSymbol   = errors.New("password must contain a symbol")
+	ErrPersonal        = errors.New("password must not contain the email or name")
+	ErrCommon          = errors.New("password is too common")
+	ErrBreached        = errors.New("password appeared in a data breach")
+	minPersonalPartLen = 3
+)
+
+//go:embed common.txt
+var commonList string
+
+// common holds the bundled list of the most common passwords in lower case.
+var common = parseCommon(commonList)
+
+// Breached reports whether a password is known from data breaches.
+type Breached interface {
+	Contains(password string) (bool, error)
+}
+
+// Policy is the set of rules new passwords must follow. Lengths are counted
+// in characters, not bytes.
+type Policy struct {
+	MinLength     int  `json:"minLength"`
+	MaxLength     int  `json:"maxLength"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+	// RejectPersonal rejects passwords containing the email, its local part or the names of the user.
+	RejectPersonal bool `json:"rejectPersonal"`
+	// RejectCommon rejects passwords from the bundled list of common passwords.
+	RejectCommon bool `json:"rejectCommon"`
+	// History is the number of recent passwords, the current one included,
+	// that can not be chosen again. Checking it is up to the caller as it
+	// needs the stored hashes.
+	History int `json:"history"`
+	// Breached optionally rejects passwords known from data breaches.
+	Breached Breached `json:"-"`
+}
+
+// DefaultPolicy follows NIST SP 800-63B: a minimum length and a blocklist
+// rather than character class rules.
+var DefaultPolicy = Policy{
+	MinLength:      8,
+	MaxLength:      128,
+	RejectPersonal: true,
+	RejectCommon:   true,
+	History:        5,
+}
+
+// Check returns ErrWeakPassword joined with all the rules password breaks, nil
+// when it follows the policy. personal are the email and names of the user.
+func (p Policy) Check(password string, personal ...string) error {
+	var errs []error
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		errs = append(errs, fmt.Errorf("%w: at least %d characters", ErrTooShort, p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		errs = append(errs, fmt.Errorf("%w: at most %d characters", ErrTooLong, p.MaxLength))
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		errs = append(errs, ErrMissingUpper)
+	}
+	if p.RequireLower && !lower {
+		errs = append(errs, ErrMissingLower)
+	}
+	if p.RequireDigit && !digit {
+		errs = append(errs, ErrMissingDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		errs = append(errs, ErrMissingSymbol)
+	}
+	lowered := strings.ToLower(password)
+	if p.RejectPersonal && containsPersonal(lowered, personal) {
+		errs = append(errs, ErrPersonal)
+	}
+	if p.RejectCommon && common[lowered] {
+		errs = append(errs, ErrCommon)
+	}
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("check breached passwords: %w", err)
+		}
+		if breached {
+			errs = append(errs, ErrBreached)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(append([]error{ErrWeakPassword}, errs...)...)
+}
+
+func containsPersonal(password string, personal []string) bool {
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		parts := []string{value}
+		if local, _, found := strings.Cut(value, "@"); found {
+			parts = append(parts, local)
+		}
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= minPersonalPartLen && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseCommon(list string) map[string]bool {
+	passwords := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}
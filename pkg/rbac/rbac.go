@@ -0,0 +1,68 @@
+// Package rbac maps the roles of users to the permissions they grant.
+package rbac
+
+import "sort"
+
+// Permission allows a kind of operation, written as resource:action.
+type Permission string
+
+const (
+	UsersRead     Permission = "users:read"
+	UsersWrite    Permission = "users:write"
+	JokesModerate Permission = "jokes:moderate"
+	RolesManage   Permission = "roles:manage"
+	ClientsManage Permission = "clients:manage"
+	// UsersImpersonate allows acting as another user, which bypasses their
+	// credentials, so no default role but admin grants it.
+	UsersImpersonate Permission = "users:impersonate"
+	AuditRead        Permission = "audit:read"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleSupport   = "support"
+)
+
+// Roles maps role names to the permissions they grant.
+type Roles map[string][]Permission
+
+var DefaultRoles = Roles{
+	RoleAdmin:     {UsersRead, UsersWrite, JokesModerate, RolesManage, ClientsManage, UsersImpersonate, AuditRead},
+	RoleModerator: {JokesModerate},
+	RoleSupport:   {UsersRead, UsersWrite},
+}
+
+// Known reports whether role is defined.
+func (r Roles) Known(role string) bool {
+	_, ok := r[role]
+	return ok
+}
+
+// Allows reports whether any of the roles grants permission, unknown roles grant nothing.
+func (r Roles) Allows(roles []string, permission Permission) bool {
+	for _, role := range roles {
+		for _, granted := range r[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permissions returns the sorted permissions granted by the roles.
+func (r Roles) Permissions(roles []string) []Permission {
+	seen := map[Permission]bool{}
+	permissions := []Permission{}
+	for _, role := range roles {
+		for _, permission := range r[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
@@ -0,0 +1,23 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	require.True(t, DefaultRoles.Allows([]string{RoleAdmin}, RolesManage))
+	require.True(t, DefaultRoles.Allows([]string{"unknown", RoleModerator}, JokesModerate))
+	require.False(t, DefaultRoles.Allows([]string{RoleModerator}, UsersWrite))
+	require.False(t, DefaultRoles.Allows([]string{RoleSupport}, UsersImpersonate))
+	require.False(t, DefaultRoles.Allows([]string{"unknown"}, UsersRead))
+	require.False(t, DefaultRoles.Allows(nil, UsersRead))
+}
+
+func TestPermissions(t *testing.T) {
+	require.Equal(t, []Permission{JokesModerate, UsersRead, UsersWrite}, DefaultRoles.Permissions([]string{RoleSupport, RoleModerator, RoleSupport}))
+	require.Empty(t, DefaultRoles.Permissions(nil))
+	require.True(t, DefaultRoles.Known(RoleSupport))
+	require.False(t, DefaultRoles.Known("root"))
+}
@@ -0,0 +1,39 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	box, err := ParseBox(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"), []byte("user-1"))
+	require.NoError(t, err)
+	require.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+	again, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"), []byte("user-1"))
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again)
+
+	opened, err := box.Open(sealed, []byte("user-1"))
+	require.NoError(t, err)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", string(opened))
+
+	// Bound to the user it was sealed for
+	_, err = box.Open(sealed, []byte("user-2"))
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	other, err := NewBox(bytes.Repeat([]byte{8}, 32))
+	require.NoError(t, err)
+	_, err = other.Open(sealed, []byte("user-1"))
+	require.ErrorIs(t, err, ErrDecrypt)
+	_, err = box.Open("garbage", []byte("user-1"))
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = NewBox([]byte("short"))
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from access tokens.
+const APIKeyPrefix = "gu_"
+
+// lookupLength is the length of the hex encoded lookup following APIKeyPrefix.
+const lookupLength = 12
+
+// NewAPIKey returns a random API key and its lookup, the part of the key it
+// is found by. Keys are only stored as their HashOpaque, the lookup in clear.
+func NewAPIKey() (key, lookup string, err error) {
+	random := make([]byte, lookupLength/2)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	secret, err := NewOpaque()
+	if err != nil {
+		return "", "", err
+	}
+	lookup = hex.EncodeToString(random)
+	return APIKeyPrefix + lookup + "_" + secret, lookup, nil
+}
+
+// ParseAPIKey returns the lookup of an API key, ok is false when key does not
+// have the form of one.
+func ParseAPIKey(key string) (lookup string, ok bool) {
+	rest, found := strings.CutPrefix(key, APIKeyPrefix)
+	if !found || len(rest) <= lookupLength || rest[lookupLength] != '_' {
+		return "", false
+	}
+	lookup = rest[:lookupLength]
+	if _, err := hex.DecodeString(lookup); err != nil {
+		return "", false
+	}
+	return lookup, true
+}
@@ -0,0 +1,60 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Purposes of one-time tokens, a token is only accepted for the purpose it was issued for.
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+	PurposeMFA           = "mfa"
+	PurposeInvite        = "invite"
+)
+
+// OneTimeClaims identify a single-use token by its ID, the Subject is the ID
+// of the user it was issued for, or the invited email, and the Audience its purpose.
+type OneTimeClaims struct {
+	jwt.RegisteredClaims
+}
+
+// IssueOneTime signs a token that is valid for ttl and for purpose only.
+// Tokens are only single-use once their ID is recorded and consumed by the caller.
+func (i *Issuer) IssueOneTime(userID, purpose string, ttl time.Duration) (string, OneTimeClaims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", OneTimeClaims{}, err
+	}
+	now := time.Now()
+	claims := OneTimeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{purpose},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	return signed, claims, err
+}
+
+func (i *Issuer) VerifyOneTime(tokenString, purpose string) (*OneTimeClaims, error) {
+	claims := &OneTimeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return i.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithAudience(purpose), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	if claims.ID == "" || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing token or user ID", ErrInvalidToken)
+	}
+	return claims, nil
+}
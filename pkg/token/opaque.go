@@ -0,0 +1,25 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewOpaque returns a random token that only means something to the server
+// it was stored on, such as a password reset token.
+func NewOpaque() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// HashOpaque returns the hash opaque tokens are stored by, so that the stored
+// hashes can not be used as tokens.
+func HashOpaque(opaque string) string {
+	sum := sha256.Sum256([]byte(opaque))
+	return hex.EncodeToString(sum[:])
+}
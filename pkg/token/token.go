@@ -0,0 +1,100 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	Email string `json:"email"`
+	// Tenant is the tenant of the user, empty for the default tenant in tokens
+	// issued before there were tenants.
+	Tenant string `json:"tid,omitempty"`
+	// Groups are the names of the groups the user was a member of when the token was issued.
+	Groups []string `json:"groups,omitempty"`
+	// Session is the ID of the login session, empty in tokens issued before
+	// there were sessions.
+	Session string `json:"sid,omitempty"`
+	// Actor is the user acting as the subject, only set in impersonation tokens.
+	Actor *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Actor names who acts as the subject of a token, as in the act claim of
+// RFC 8693.
+type Actor struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Issuer signs and verifies the HS256 access tokens handed out on login.
+type Issuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewIssuer(secret string, ttl time.Duration) *Issuer {
+	return &Issuer{secret: []byte(secret), ttl: ttl}
+}
+
+// TTL is how long access tokens are valid.
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue signs an access token for the user, who is a member of groups.
+func (i *Issuer) Issue(user repo.User, groups ...string) (string, error) {
+	return i.IssueSession(user, "", groups...)
+}
+
+// IssueSession signs an access token for the user that names the session it
+// was issued for.
+func (i *Issuer) IssueSession(user repo.User, session string, groups ...string) (string, error) {
+	claims := newClaims(user, i.ttl, groups)
+	claims.Session = session
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+}
+
+// Impersonate signs an access token for the user that is valid for ttl and
+// names actor as the one acting as the user. It belongs to no session.
+func (i *Issuer) Impersonate(user, actor repo.User, ttl time.Duration, groups ...string) (string, Claims, error) {
+	claims := newClaims(user, ttl, groups)
+	claims.Actor = &Actor{Subject: actor.ID, Email: actor.Email}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	return signed, claims, err
+}
+
+func newClaims(user repo.User, ttl time.Duration, groups []string) Claims {
+	now := time.Now()
+	return Claims{
+		Email:  user.Email,
+		Tenant: user.TenantID,
+		Groups: groups,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+func (i *Issuer) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return i.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	// One-time tokens are signed with the same secret but never grant access
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+	return claims, nil
+}
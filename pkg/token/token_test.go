@@ -0,0 +1,131 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	issuer := NewIssuer("secret", time.Hour)
+	signed, err := issuer.Issue(repo.User{ID: "6553a1e1f1d2c3b4a5968778", Email: "fo@bo.com"}, "dev", "ops")
+	require.NoError(t, err)
+
+	claims, err := issuer.Verify(signed)
+	require.NoError(t, err)
+	require.Equal(t, "6553a1e1f1d2c3b4a5968778", claims.Subject)
+	require.Equal(t, "fo@bo.com", claims.Email)
+	require.Equal(t, []string{"dev", "ops"}, claims.Groups)
+}
+
+func TestIssueSession(t *testing.T) {
+	issuer := NewIssuer("secret", time.Hour)
+	signed, err := issuer.IssueSession(repo.User{ID: "1"}, "6553a1e1f1d2c3b4a5968779")
+	require.NoError(t, err)
+
+	claims, err := issuer.Verify(signed)
+	require.NoError(t, err)
+	require.Equal(t, "6553a1e1f1d2c3b4a5968779", claims.Session)
+	require.WithinDuration(t, time.Now().Add(issuer.TTL()), claims.ExpiresAt.Time, time.Second)
+}
+
+func TestImpersonate(t *testing.T) {
+	issuer := NewIssuer("secret", time.Hour)
+	signed, issued, err := issuer.Impersonate(repo.User{ID: "1", Email: "fo@bo.com"}, repo.User{ID: "2", Email: "admin@bo.com"}, time.Minute, "dev")
+	require.NoError(t, err)
+
+	claims, err := issuer.Verify(signed)
+	require.NoError(t, err)
+	require.Equal(t, "1", claims.Subject)
+	require.Equal(t, &Actor{Subject: "2", Email: "admin@bo.com"}, claims.Actor)
+	require.Equal(t, []string{"dev"}, claims.Groups)
+	require.Empty(t, claims.Session)
+	require.Equal(t, issued.ExpiresAt.Unix(), claims.ExpiresAt.Unix())
+	require.WithinDuration(t, time.Now().Add(time.Minute), claims.ExpiresAt.Time, time.Second)
+
+	signed, err = issuer.Issue(repo.User{ID: "1"})
+	require.NoError(t, err)
+	claims, err = issuer.Verify(signed)
+	require.NoError(t, err)
+	require.Nil(t, claims.Actor)
+}
+
+func TestVerifyWrongSecret(t *testing.T) {
+	signed, err := NewIssuer("secret", time.Hour).Issue(repo.User{ID: "1"})
+	require.NoError(t, err)
+
+	_, err = NewIssuer("other", time.Hour).Verify(signed)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyExpired(t *testing.T) {
+	issuer := NewIssuer("secret", -time.Minute)
+	signed, err := issuer.Issue(repo.User{ID: "1"})
+	require.NoError(t, err)
+
+	_, err = issuer.Verify(signed)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestOneTime(t *testing.T) {
+	issuer := NewIssuer("secret", time.Hour)
+	signed, issued, err := issuer.IssueOneTime("6553a1e1f1d2c3b4a5968778", PurposeVerifyEmail, time.Hour)
+	require.NoError(t, err)
+	require.NotEmpty(t, issued.ID)
+
+	claims, err := issuer.VerifyOneTime(signed, PurposeVerifyEmail)
+	require.NoError(t, err)
+	require.Equal(t, issued.ID, claims.ID)
+	require.Equal(t, "6553a1e1f1d2c3b4a5968778", claims.Subject)
+
+	// Neither an access token nor a token for another purpose
+	_, err = issuer.Verify(signed)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = issuer.VerifyOneTime(signed, "other")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	access, err := issuer.Issue(repo.User{ID: "1"})
+	require.NoError(t, err)
+	_, err = issuer.VerifyOneTime(access, PurposeVerifyEmail)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	expired, _, err := issuer.IssueOneTime("1", PurposeVerifyEmail, -time.Minute)
+	require.NoError(t, err)
+	_, err = issuer.VerifyOneTime(expired, PurposeVerifyEmail)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestOpaque(t *testing.T) {
+	first, err := NewOpaque()
+	require.NoError(t, err)
+	second, err := NewOpaque()
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.Len(t, first, 43)
+
+	require.Equal(t, HashOpaque(first), HashOpaque(first))
+	require.NotEqual(t, HashOpaque(first), HashOpaque(second))
+	require.NotContains(t, HashOpaque(first), first)
+}
+
+func TestAPIKey(t *testing.T) {
+	key, lookup, err := NewAPIKey()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key, APIKeyPrefix+lookup+"_"))
+	parsed, ok := ParseAPIKey(key)
+	require.True(t, ok)
+	require.Equal(t, lookup, parsed)
+	other, otherLookup, err := NewAPIKey()
+	require.NoError(t, err)
+	require.NotEqual(t, key, other)
+	require.NotEqual(t, lookup, otherLookup)
+
+	access, err := NewIssuer("secret", time.Hour).Issue(repo.User{ID: "1"})
+	require.NoError(t, err)
+	for _, invalid := range []string{access, "", APIKeyPrefix, APIKeyPrefix + lookup, APIKeyPrefix + "zzzzzzzzzzzz_secret", APIKeyPrefix + lookup + "-secret"} {
+		_, ok := ParseAPIKey(invalid)
+		require.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,154 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// TOTP codes as specified by RFC 6238 with the parameters authenticator apps
+// support everywhere: HMAC-SHA1, 6 digits and a 30 second period.
+const (
+	Digits     = 6
+	Period     = 30 * time.Second
+	secretSize = 20
+	// skew is the number of periods before and after the current one that are accepted.
+	skew    = 1
+	modulus = 1_000_000
+)
+
+var (
+	ErrInvalidCode   = errors.New("invalid TOTP code")
+	ErrInvalidSecret = errors.New("invalid TOTP secret")
+	encoding         = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret returns a random secret encoded with unpadded base32, as
+// otpauth URIs expect it.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// Code returns the code for the period t falls in.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return generate(key, counter(t)), nil
+}
+
+// Validate checks code against the periods around t and returns the counter
+// of the matching period. Callers should reject counters that are not
+// greater than the last one accepted, so that a code can not be replayed.
+func Validate(secret, code string, t time.Time) (int64, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	current := counter(t)
+	for c := current - skew; c <= current+skew; c++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, c)), []byte(code)) == 1 {
+			return c, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// URI returns the otpauth URI authenticator apps enroll the secret with.
+func URI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// QRCode encodes uri as a PNG QR code of size pixels.
+func QRCode(uri string, size int) ([]byte, error) {
+	return qrcode.Encode(uri, qrcode.Medium, size)
+}
+
+func counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+func generate(key []byte, c int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(c))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulus)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// GenerateRecoveryCodes returns n random single-use codes written as
+// xxxxx-xxxxx, to be used when the authenticator is not at hand.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	codes := make([]string, n)
+	random := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		code := make([]byte, len(random))
+		for j, b := range random {
+			code[j] = alphabet[b%byte(len(alphabet))]
+		}
+		codes[i] = string(code[:5]) + "-" + string(code[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode ignores the case, dashes and spaces users may type differently.
+func NormalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// IsCode reports whether code looks like a TOTP code rather than a recovery code.
+func IsCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
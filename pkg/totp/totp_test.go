@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/base32"
+	"image/png"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA1 test vectors of RFC 6238 appendix B, truncated to 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := Code(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	counter, err := Validate(secret, code, now)
+	require.NoError(t, err)
+	require.Equal(t, now.Unix()/30, counter)
+
+	// One period of clock drift is accepted
+	counter, err = Validate(secret, code, now.Add(Period))
+	require.NoError(t, err)
+	require.Equal(t, now.Unix()/30, counter)
+	_, err = Validate(secret, code, now.Add(2*Period))
+	require.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = Validate("not base32!", code, now)
+	require.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestURIAndQRCode(t *testing.T) {
+	uri := URI("go-user", "fo@bo.com", "JBSWY3DPEHPK3PXP")
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/go-user:fo@bo.com", parsed.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	require.Equal(t, "go-user", parsed.Query().Get("issuer"))
+
+	image, err := QRCode(uri, 256)
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(image))
+	require.NoError(t, err)
+	require.Equal(t, 256, decoded.Bounds().Dx())
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	require.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	require.NotEqual(t, codes[0], codes[1])
+
+	require.Equal(t, "abcdefghij", NormalizeRecoveryCode("ABCDE-fghij"))
+	require.Equal(t, "abcdefghij", NormalizeRecoveryCode("abcde fghij"))
+	require.True(t, IsCode("123456"))
+	require.True(t, IsCode("123 456"))
+	require.False(t, IsCode("abcde-fghij"))
+	require.False(t, IsCode("1234567"))
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKey lets scripts act as a user without logging in. Only the hash of the
+// key is stored, it is found by the Lookup part of the key.
+type APIKey struct {
+	ID       string `json:"id" bson:"_id,omitempty"`
+	TenantID string `json:"-" bson:"tenantId"`
+	UserID   string `json:"-" bson:"userId"`
+	Name     string `json:"name" bson:"name"`
+	Lookup   string `json:"lookup" bson:"lookup"`
+	Hash     string `json:"-" bson:"hash"`
+	// Scopes are the permissions the key is limited to.
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+type APIKeyRepository interface {
+	Create(key APIKey) (APIKey, error)
+	FindByLookup(lookup string) (APIKey, error)
+	FindByUser(userID string) ([]APIKey, error)
+	Delete(id, userID string) error
+	Touch(id string, at time.Time) error
+}
+
+type MongoAPIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAPIKeyRepository(collection *mongo.Collection) (*MongoAPIKeyRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"lookup": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoAPIKeyRepository{collection: collection}, err
+}
+
+func (r *MongoAPIKeyRepository) Create(key APIKey) (APIKey, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		return APIKey{}, err
+	}
+	key.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return key, nil
+}
+
+func (r *MongoAPIKeyRepository) FindByLookup(lookup string) (APIKey, error) {
+	ctx := context.Background()
+	var key APIKey
+	err := r.collection.FindOne(ctx, bson.M{"lookup": lookup}).Decode(&key)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+// FindByUser returns the keys of the user, oldest first.
+func (r *MongoAPIKeyRepository) FindByUser(userID string) ([]APIKey, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Delete revokes a key of the user, it returns mongo.ErrNoDocuments when the
+// user has no such key.
+func (r *MongoAPIKeyRepository) Delete(id, userID string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Touch records a use of the key at the given time.
+func (r *MongoAPIKeyRepository) Touch(id string, at time.Time) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"lastUsedAt": at}})
+	return err
+}
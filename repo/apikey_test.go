@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAPIKey(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	keyRepo, err := NewMongoAPIKeyRepository(db.Collection("api_keys"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expiresAt := now.Add(time.Hour)
+	userID := randomdata.Alphanumeric(24)
+	key, err := keyRepo.Create(APIKey{
+		TenantID:  DefaultTenant,
+		UserID:    userID,
+		Name:      "ci",
+		Lookup:    randomdata.Alphanumeric(12),
+		Hash:      randomdata.Alphanumeric(64),
+		Scopes:    []string{"users:read"},
+		CreatedAt: now,
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+	_, err = keyRepo.Create(APIKey{UserID: userID, Lookup: key.Lookup, CreatedAt: now})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	found, err := keyRepo.FindByLookup(key.Lookup)
+	require.NoError(t, err)
+	require.Equal(t, key, found)
+	keys, err := keyRepo.FindByUser(userID)
+	require.NoError(t, err)
+	require.Equal(t, []APIKey{key}, keys)
+
+	require.NoError(t, keyRepo.Touch(key.ID, now))
+	found, err = keyRepo.FindByLookup(key.Lookup)
+	require.NoError(t, err)
+	require.Equal(t, now, *found.LastUsedAt)
+
+	require.ErrorIs(t, keyRepo.Delete(key.ID, randomdata.Alphanumeric(24)), mongo.ErrNoDocuments)
+	require.NoError(t, keyRepo.Delete(key.ID, userID))
+	_, err = keyRepo.FindByLookup(key.Lookup)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttempts counts the failed logins for a key such as an account or an
+// IP address. PreviousFailure is the time of the failure before LastFailure.
+// Failures are forgotten once ExpiresAt has passed.
+type LoginAttempts struct {
+	Key             string    `json:"key" bson:"_id"`
+	Failures        int       `json:"failures" bson:"failures"`
+	LastFailure     time.Time `json:"lastFailure" bson:"lastFailure"`
+	PreviousFailure time.Time `json:"previousFailure" bson:"previousFailure,omitempty"`
+	ExpiresAt       time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+type LoginAttemptRepository interface {
+	Find(key string) (LoginAttempts, error)
+	RecordFailure(key string, at time.Time, window time.Duration) (LoginAttempts, error)
+	Forgive(key string) error
+	Reset(key string) error
+}
+
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptRepository(collection *mongo.Collection) (*MongoLoginAttemptRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoLoginAttemptRepository{collection: collection}, err
+}
+
+func (r *MongoLoginAttemptRepository) Find(key string) (LoginAttempts, error) {
+	ctx := context.Background()
+	var attempts LoginAttempts
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err != nil {
+		return LoginAttempts{}, err
+	}
+	return attempts, nil
+}
+
+// RecordFailure atomically counts a failure for key and returns the count
+// including it. Failures older than the window are dropped, even before the
+// TTL index removes the document.
+func (r *MongoLoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (LoginAttempts, error) {
+	ctx := context.Background()
+	current := bson.M{"$gt": bson.A{"$expiresAt", at}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				current,
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+				1,
+			}},
+			"previousFailure": bson.M{"$cond": bson.A{current, "$lastFailure", "$$REMOVE"}},
+			"lastFailure":     at,
+			"expiresAt":       at.Add(window),
+		}}},
+	}
+	var attempts LoginAttempts
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&attempts)
+	if err != nil {
+		return LoginAttempts{}, err
+	}
+	return attempts, nil
+}
+
+// Forgive takes back one failure of key.
+func (r *MongoLoginAttemptRepository) Forgive(key string) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key, "failures": bson.M{"$gt": 0}}, bson.M{
+		"$inc": bson.M{"failures": -1},
+	})
+	return err
+}
+
+func (r *MongoLoginAttemptRepository) Reset(key string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
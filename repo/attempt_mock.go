@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./attempt.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./attempt.go -destination=./attempt_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginAttemptRepository is a mock of LoginAttemptRepository interface.
+type MockLoginAttemptRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptRepositoryMockRecorder
+}
+
+// MockLoginAttemptRepositoryMockRecorder is the mock recorder for MockLoginAttemptRepository.
+type MockLoginAttemptRepositoryMockRecorder struct {
+	mock *MockLoginAttemptRepository
+}
+
+// NewMockLoginAttemptRepository creates a new mock instance.
+func NewMockLoginAttemptRepository(ctrl *gomock.Controller) *MockLoginAttemptRepository {
+	mock := &MockLoginAttemptRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptRepository) EXPECT() *MockLoginAttemptRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Find mocks base method.
+func (m *MockLoginAttemptRepository) Find(key string) (LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", key)
+	ret0, _ := ret[0].(LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Find(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Find), key)
+}
+
+// Forgive mocks base method.
+func (m *MockLoginAttemptRepository) Forgive(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Forgive", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Forgive indicates an expected call of Forgive.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Forgive(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Forgive", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Forgive), key)
+}
+
+// RecordFailure mocks base method.
+func (m *MockLoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration) (LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailure", key, at, window)
+	ret0, _ := ret[0].(LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordFailure indicates an expected call of RecordFailure.
+func (mr *MockLoginAttemptRepositoryMockRecorder) RecordFailure(key, at, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailure", reflect.TypeOf((*MockLoginAttemptRepository)(nil).RecordFailure), key, at, window)
+}
+
+// Reset mocks base method.
+func (m *MockLoginAttemptRepository) Reset(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Reset(key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Reset), key)
+}
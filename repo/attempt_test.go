@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttempts(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	attemptRepo, err := NewMongoLoginAttemptRepository(db.Collection("login_attempts"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	attempts, err := attemptRepo.RecordFailure("account:fo@bo.com", now, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 1, attempts.Failures)
+	attempts, err = attemptRepo.RecordFailure("account:fo@bo.com", now, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts.Failures)
+	attempts, err = attemptRepo.RecordFailure("account:fo@bo.com", now.Add(time.Minute), time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+	require.Equal(t, now, attempts.PreviousFailure)
+	require.Equal(t, now.Add(time.Minute), attempts.LastFailure)
+
+	require.NoError(t, attemptRepo.Forgive("account:fo@bo.com"))
+	attempts, err = attemptRepo.Find("account:fo@bo.com")
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts.Failures)
+
+	// Failures after the window start over
+	attempts, err = attemptRepo.RecordFailure("account:fo@bo.com", now.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 1, attempts.Failures)
+	require.True(t, attempts.PreviousFailure.IsZero())
+
+	require.NoError(t, attemptRepo.Reset("account:fo@bo.com"))
+	_, err = attemptRepo.Find("account:fo@bo.com")
+	require.Error(t, err)
+}
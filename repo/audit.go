@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Audited actions.
+const (
+	AuditImpersonate         = "impersonate"
+	AuditImpersonatedRequest = "impersonated_request"
+)
+
+// AuditEntry records an action ActorID took on the account of UserID, who is
+// the same user unless the actor impersonated them. Requests are recorded
+// with their method, path and response status. Entries are kept for good.
+type AuditEntry struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	TenantID  string    `json:"-" bson:"tenantId"`
+	At        time.Time `json:"at" bson:"at"`
+	Action    string    `json:"action" bson:"action"`
+	ActorID   string    `json:"actorId" bson:"actorId"`
+	UserID    string    `json:"userId" bson:"userId"`
+	Method    string    `json:"method,omitempty" bson:"method,omitempty"`
+	Path      string    `json:"path,omitempty" bson:"path,omitempty"`
+	Status    int       `json:"status,omitempty" bson:"status,omitempty"`
+	IP        string    `json:"ip" bson:"ip"`
+	UserAgent string    `json:"userAgent" bson:"userAgent"`
+}
+
+type AuditRepository interface {
+	Create(entry AuditEntry) (AuditEntry, error)
+	Find(tenantID, userID string, skip, limit int) ([]AuditEntry, error)
+}
+
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditRepository(collection *mongo.Collection) (*MongoAuditRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "userId", Value: 1}, {Key: "at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "actorId", Value: 1}, {Key: "at", Value: -1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoAuditRepository{collection: collection}, err
+}
+
+func (r *MongoAuditRepository) Create(entry AuditEntry) (AuditEntry, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	entry.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return entry, nil
+}
+
+// Find returns a page of the entries of the tenant, newest first. A userID
+// narrows them down to the entries the user took part in, as the actor or
+// as the one acted as.
+func (r *MongoAuditRepository) Find(tenantID, userID string, skip, limit int) ([]AuditEntry, error) {
+	ctx := context.Background()
+	filter := bson.M{"tenantId": tenantID}
+	if userID != "" {
+		filter["$or"] = bson.A{bson.M{"userId": userID}, bson.M{"actorId": userID}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
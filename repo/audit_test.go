@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	auditRepo, err := NewMongoAuditRepository(db.Collection("audit_log"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	tenantID := randomdata.Alphanumeric(8)
+	adminID, userID := randomdata.Alphanumeric(24), randomdata.Alphanumeric(24)
+	started, err := auditRepo.Create(AuditEntry{
+		TenantID:  tenantID,
+		At:        now.Add(-time.Minute),
+		Action:    AuditImpersonate,
+		ActorID:   adminID,
+		UserID:    userID,
+		IP:        "10.0.0.1",
+		UserAgent: "laptop",
+	})
+	require.NoError(t, err)
+	request, err := auditRepo.Create(AuditEntry{
+		TenantID: tenantID,
+		At:       now,
+		Action:   AuditImpersonatedRequest,
+		ActorID:  adminID,
+		UserID:   userID,
+		Method:   "GET",
+		Path:     "/me",
+		Status:   200,
+	})
+	require.NoError(t, err)
+	other, err := auditRepo.Create(AuditEntry{TenantID: tenantID, At: now, Action: AuditImpersonate, ActorID: adminID, UserID: randomdata.Alphanumeric(24)})
+	require.NoError(t, err)
+
+	entries, err := auditRepo.Find(tenantID, userID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []AuditEntry{request, started}, entries)
+	entries, err = auditRepo.Find(tenantID, adminID, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []AuditEntry{request, started}, entries)
+	entries, err = auditRepo.Find(tenantID, "", 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []AuditEntry{other}, entries)
+	entries, err = auditRepo.Find(randomdata.Alphanumeric(8), "", 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
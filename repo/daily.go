@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DailyJoke is the joke chosen for a calendar day, Date is formatted as YYYY-MM-DD.
+type DailyJoke struct {
+	Date      string    `json:"date" bson:"_id"`
+	Joke      Joke      `json:"joke" bson:"joke"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+type DailyJokeRepository interface {
+	FindByDate(date string) (DailyJoke, error)
+	CreateIfAbsent(daily DailyJoke) (DailyJoke, error)
+}
+
+type MongoDailyJokeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDailyJokeRepository(collection *mongo.Collection) *MongoDailyJokeRepository {
+	return &MongoDailyJokeRepository{collection: collection}
+}
+
+func (r *MongoDailyJokeRepository) FindByDate(date string) (DailyJoke, error) {
+	ctx := context.Background()
+	var daily DailyJoke
+	err := r.collection.FindOne(ctx, bson.M{"_id": date}).Decode(&daily)
+	if err != nil {
+		return DailyJoke{}, err
+	}
+	return daily, nil
+}
+
+// CreateIfAbsent stores the joke for its date unless another one was stored
+// first, in which case the already stored joke is returned.
+func (r *MongoDailyJokeRepository) CreateIfAbsent(daily DailyJoke) (DailyJoke, error) {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, daily)
+	if mongo.IsDuplicateKeyError(err) {
+		return r.FindByDate(daily.Date)
+	}
+	if err != nil {
+		return DailyJoke{}, err
+	}
+	return daily, nil
+}
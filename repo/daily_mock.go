@@ -0,0 +1,68 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./daily.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./daily.go -destination=./daily_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDailyJokeRepository is a mock of DailyJokeRepository interface.
+type MockDailyJokeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockDailyJokeRepositoryMockRecorder
+}
+
+// MockDailyJokeRepositoryMockRecorder is the mock recorder for MockDailyJokeRepository.
+type MockDailyJokeRepositoryMockRecorder struct {
+	mock *MockDailyJokeRepository
+}
+
+// NewMockDailyJokeRepository creates a new mock instance.
+func NewMockDailyJokeRepository(ctrl *gomock.Controller) *MockDailyJokeRepository {
+	mock := &MockDailyJokeRepository{ctrl: ctrl}
+	mock.recorder = &MockDailyJokeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDailyJokeRepository) EXPECT() *MockDailyJokeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateIfAbsent mocks base method.
+func (m *MockDailyJokeRepository) CreateIfAbsent(daily DailyJoke) (DailyJoke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIfAbsent", daily)
+	ret0, _ := ret[0].(DailyJoke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIfAbsent indicates an expected call of CreateIfAbsent.
+func (mr *MockDailyJokeRepositoryMockRecorder) CreateIfAbsent(daily any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIfAbsent", reflect.TypeOf((*MockDailyJokeRepository)(nil).CreateIfAbsent), daily)
+}
+
+// FindByDate mocks base method.
+func (m *MockDailyJokeRepository) FindByDate(date string) (DailyJoke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByDate", date)
+	ret0, _ := ret[0].(DailyJoke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByDate indicates an expected call of FindByDate.
+func (mr *MockDailyJokeRepositoryMockRecorder) FindByDate(date any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByDate", reflect.TypeOf((*MockDailyJokeRepository)(nil).FindByDate), date)
+}
@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyCreateIfAbsent(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	dailyRepo := NewMongoDailyJokeRepository(db.Collection("daily_jokes"))
+
+	first := DailyJoke{
+		Date:      "2023-11-14",
+		Joke:      Joke{ID: randomdata.Alphanumeric(22), Value: randomdata.Paragraph()},
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	created, err := dailyRepo.CreateIfAbsent(first)
+	require.NoError(t, err)
+	require.Equal(t, first, created)
+
+	// Another replica choosing a different joke for the same day gets the first one
+	second := DailyJoke{
+		Date:      "2023-11-14",
+		Joke:      Joke{ID: randomdata.Alphanumeric(22), Value: randomdata.Paragraph()},
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	created, err = dailyRepo.CreateIfAbsent(second)
+	require.NoError(t, err)
+	require.Equal(t, first.Joke, created.Joke)
+
+	found, err := dailyRepo.FindByDate("2023-11-14")
+	require.NoError(t, err)
+	require.Equal(t, first.Joke, found.Joke)
+
+	_, err = dailyRepo.FindByDate("2023-11-13")
+	require.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Group gathers users of a tenant, its members inherit the roles granted to it.
+type Group struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	TenantID    string    `json:"-" bson:"tenantId"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description" bson:"description"`
+	Roles       []string  `json:"roles" bson:"roles"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	// Members holds the IDs of the member users, it is only read a page at a
+	// time through Members and left empty otherwise.
+	Members []string `json:"-" bson:"members,omitempty"`
+}
+
+type GroupRepository interface {
+	Create(group Group) (Group, error)
+	FindOne(id, tenantID string) (Group, error)
+	Find(tenantID string, skip, limit int) ([]Group, error)
+	FindByMember(tenantID, userID string) ([]Group, error)
+	Update(group Group) (Group, error)
+	Delete(id, tenantID string) error
+	Members(id, tenantID string, skip, limit int) ([]string, error)
+	AddMember(id, tenantID, userID string) error
+	RemoveMember(id, tenantID, userID string) error
+	GrantRole(id, tenantID, role string) error
+	RevokeRole(id, tenantID, role string) error
+}
+
+type MongoGroupRepository struct {
+	collection *mongo.Collection
+}
+
+// withoutMembers leaves out the members, which can be too many to read at once.
+var withoutMembers = bson.M{"members": 0}
+
+func NewMongoGroupRepository(collection *mongo.Collection) (*MongoGroupRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenantId", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "members", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoGroupRepository{collection: collection}, err
+}
+
+// Create stores a group without members, the unique index rejects a name
+// already taken in the tenant.
+func (r *MongoGroupRepository) Create(group Group) (Group, error) {
+	ctx := context.Background()
+	group.Members = nil
+	if group.Roles == nil {
+		group.Roles = []string{}
+	}
+	doc, err := r.collection.InsertOne(ctx, group)
+	if err != nil {
+		return Group{}, err
+	}
+	group.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return group, nil
+}
+
+func (r *MongoGroupRepository) FindOne(id, tenantID string) (Group, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Group{}, err
+	}
+	var group Group
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID, "tenantId": tenantID}, options.FindOne().SetProjection(withoutMembers)).Decode(&group)
+	if err != nil {
+		return Group{}, err
+	}
+	return group, nil
+}
+
+// Find lists the groups of the tenant by name.
+func (r *MongoGroupRepository) Find(tenantID string, skip, limit int) ([]Group, error) {
+	opts := options.Find().SetProjection(withoutMembers).SetSort(bson.M{"name": 1}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	return r.find(bson.M{"tenantId": tenantID}, opts)
+}
+
+// FindByMember lists the groups of the tenant the user is a member of, by name.
+func (r *MongoGroupRepository) FindByMember(tenantID, userID string) ([]Group, error) {
+	opts := options.Find().SetProjection(withoutMembers).SetSort(bson.M{"name": 1})
+	return r.find(bson.M{"tenantId": tenantID, "members": userID}, opts)
+}
+
+func (r *MongoGroupRepository) find(filter bson.M, opts *options.FindOptions) ([]Group, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	groups := []Group{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Update changes the name and description of the group and returns it.
+func (r *MongoGroupRepository) Update(group Group) (Group, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(group.ID)
+	if err != nil {
+		return Group{}, err
+	}
+	var updated Group
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID, "tenantId": group.TenantID}, bson.M{
+		"$set": bson.M{"name": group.Name, "description": group.Description},
+	}, options.FindOneAndUpdate().SetProjection(withoutMembers).SetReturnDocument(options.After)).Decode(&updated)
+	if err != nil {
+		return Group{}, err
+	}
+	return updated, nil
+}
+
+// Delete removes the group, it returns mongo.ErrNoDocuments when the tenant
+// has no such group.
+func (r *MongoGroupRepository) Delete(id, tenantID string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "tenantId": tenantID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Members returns a page of the IDs of the members, in the order they were added.
+func (r *MongoGroupRepository) Members(id, tenantID string, skip, limit int) ([]string, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var group Group
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID, "tenantId": tenantID}, options.FindOne().SetProjection(bson.M{
+		"members": bson.M{"$slice": bson.A{skip, limit}},
+	})).Decode(&group)
+	if err != nil {
+		return nil, err
+	}
+	if group.Members == nil {
+		return []string{}, nil
+	}
+	return group.Members, nil
+}
+
+// AddMember adds the user to the group, adding them twice has no effect.
+func (r *MongoGroupRepository) AddMember(id, tenantID, userID string) error {
+	return r.updateOne(id, tenantID, bson.M{"$addToSet": bson.M{"members": userID}})
+}
+
+func (r *MongoGroupRepository) RemoveMember(id, tenantID, userID string) error {
+	return r.updateOne(id, tenantID, bson.M{"$pull": bson.M{"members": userID}})
+}
+
+// GrantRole adds role to the roles the members inherit, granting it twice has no effect.
+func (r *MongoGroupRepository) GrantRole(id, tenantID, role string) error {
+	return r.updateOne(id, tenantID, bson.M{"$addToSet": bson.M{"roles": role}})
+}
+
+func (r *MongoGroupRepository) RevokeRole(id, tenantID, role string) error {
+	return r.updateOne(id, tenantID, bson.M{"$pull": bson.M{"roles": role}})
+}
+
+// updateOne updates the group and returns mongo.ErrNoDocuments when the
+// tenant has no such group.
+func (r *MongoGroupRepository) updateOne(id, tenantID string, update bson.M) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "tenantId": tenantID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./group.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./group.go -destination=./group_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockGroupRepository is a mock of GroupRepository interface.
+type MockGroupRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockGroupRepositoryMockRecorder
+}
+
+// MockGroupRepositoryMockRecorder is the mock recorder for MockGroupRepository.
+type MockGroupRepositoryMockRecorder struct {
+	mock *MockGroupRepository
+}
+
+// NewMockGroupRepository creates a new mock instance.
+func NewMockGroupRepository(ctrl *gomock.Controller) *MockGroupRepository {
+	mock := &MockGroupRepository{ctrl: ctrl}
+	mock.recorder = &MockGroupRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGroupRepository) EXPECT() *MockGroupRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddMember mocks base method.
+func (m *MockGroupRepository) AddMember(id, tenantID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", id, tenantID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember.
+func (mr *MockGroupRepositoryMockRecorder) AddMember(id, tenantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockGroupRepository)(nil).AddMember), id, tenantID, userID)
+}
+
+// Create mocks base method.
+func (m *MockGroupRepository) Create(group Group) (Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", group)
+	ret0, _ := ret[0].(Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockGroupRepositoryMockRecorder) Create(group any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockGroupRepository)(nil).Create), group)
+}
+
+// Delete mocks base method.
+func (m *MockGroupRepository) Delete(id, tenantID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id, tenantID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockGroupRepositoryMockRecorder) Delete(id, tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockGroupRepository)(nil).Delete), id, tenantID)
+}
+
+// Find mocks base method.
+func (m *MockGroupRepository) Find(tenantID string, skip, limit int) ([]Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", tenantID, skip, limit)
+	ret0, _ := ret[0].([]Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockGroupRepositoryMockRecorder) Find(tenantID, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockGroupRepository)(nil).Find), tenantID, skip, limit)
+}
+
+// FindByMember mocks base method.
+func (m *MockGroupRepository) FindByMember(tenantID, userID string) ([]Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByMember", tenantID, userID)
+	ret0, _ := ret[0].([]Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByMember indicates an expected call of FindByMember.
+func (mr *MockGroupRepositoryMockRecorder) FindByMember(tenantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByMember", reflect.TypeOf((*MockGroupRepository)(nil).FindByMember), tenantID, userID)
+}
+
+// FindOne mocks base method.
+func (m *MockGroupRepository) FindOne(id, tenantID string) (Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id, tenantID)
+	ret0, _ := ret[0].(Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockGroupRepositoryMockRecorder) FindOne(id, tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockGroupRepository)(nil).FindOne), id, tenantID)
+}
+
+// GrantRole mocks base method.
+func (m *MockGroupRepository) GrantRole(id, tenantID, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", id, tenantID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockGroupRepositoryMockRecorder) GrantRole(id, tenantID, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockGroupRepository)(nil).GrantRole), id, tenantID, role)
+}
+
+// Members mocks base method.
+func (m *MockGroupRepository) Members(id, tenantID string, skip, limit int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Members", id, tenantID, skip, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Members indicates an expected call of Members.
+func (mr *MockGroupRepositoryMockRecorder) Members(id, tenantID, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Members", reflect.TypeOf((*MockGroupRepository)(nil).Members), id, tenantID, skip, limit)
+}
+
+// RemoveMember mocks base method.
+func (m *MockGroupRepository) RemoveMember(id, tenantID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", id, tenantID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember.
+func (mr *MockGroupRepositoryMockRecorder) RemoveMember(id, tenantID, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockGroupRepository)(nil).RemoveMember), id, tenantID, userID)
+}
+
+// RevokeRole mocks base method.
+func (m *MockGroupRepository) RevokeRole(id, tenantID, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", id, tenantID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockGroupRepositoryMockRecorder) RevokeRole(id, tenantID, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockGroupRepository)(nil).RevokeRole), id, tenantID, role)
+}
+
+// Update mocks base method.
+func (m *MockGroupRepository) Update(group Group) (Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", group)
+	ret0, _ := ret[0].(Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockGroupRepositoryMockRecorder) Update(group any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockGroupRepository)(nil).Update), group)
+}
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestGroup(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	groupRepo, err := NewMongoGroupRepository(db.Collection("groups"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	tenantID := randomdata.Alphanumeric(12)
+	group, err := groupRepo.Create(Group{TenantID: tenantID, Name: "ops", Description: "Operations", CreatedAt: now})
+	require.NoError(t, err)
+	require.Equal(t, []string{}, group.Roles)
+	// Names are unique per tenant
+	_, err = groupRepo.Create(Group{TenantID: tenantID, Name: "ops", CreatedAt: now})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+	_, err = groupRepo.Create(Group{TenantID: randomdata.Alphanumeric(12), Name: "ops", CreatedAt: now})
+	require.NoError(t, err)
+	other, err := groupRepo.Create(Group{TenantID: tenantID, Name: "dev", CreatedAt: now})
+	require.NoError(t, err)
+
+	found, err := groupRepo.FindOne(group.ID, tenantID)
+	require.NoError(t, err)
+	require.Equal(t, group, found)
+	_, err = groupRepo.FindOne(group.ID, randomdata.Alphanumeric(12))
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	groups, err := groupRepo.Find(tenantID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []Group{other, group}, groups)
+
+	group.Name, group.Description = "operations", "Keeps things running"
+	updated, err := groupRepo.Update(group)
+	require.NoError(t, err)
+	require.Equal(t, group, updated)
+
+	require.NoError(t, groupRepo.Delete(other.ID, tenantID))
+	require.ErrorIs(t, groupRepo.Delete(other.ID, tenantID), mongo.ErrNoDocuments)
+}
+
+func TestGroupMembers(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	groupRepo, err := NewMongoGroupRepository(db.Collection("groups"))
+	require.NoError(t, err)
+
+	tenantID := randomdata.Alphanumeric(12)
+	group, err := groupRepo.Create(Group{TenantID: tenantID, Name: "ops", CreatedAt: time.Now().UTC().Truncate(time.Millisecond)})
+	require.NoError(t, err)
+	members, err := groupRepo.Members(group.ID, tenantID, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, members)
+	for _, userID := range []string{"user-1", "user-2", "user-3", "user-1"} {
+		require.NoError(t, groupRepo.AddMember(group.ID, tenantID, userID))
+	}
+	members, err = groupRepo.Members(group.ID, tenantID, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user-2", "user-3"}, members)
+	require.NoError(t, groupRepo.RemoveMember(group.ID, tenantID, "user-2"))
+	members, err = groupRepo.Members(group.ID, tenantID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user-1", "user-3"}, members)
+	require.ErrorIs(t, groupRepo.AddMember(primitive.NewObjectID().Hex(), tenantID, "user-1"), mongo.ErrNoDocuments)
+
+	require.NoError(t, groupRepo.GrantRole(group.ID, tenantID, "moderator"))
+	require.NoError(t, groupRepo.GrantRole(group.ID, tenantID, "moderator"))
+	groups, err := groupRepo.FindByMember(tenantID, "user-3")
+	require.NoError(t, err)
+	group.Roles = []string{"moderator"}
+	require.Equal(t, []Group{group}, groups)
+	require.NoError(t, groupRepo.RevokeRole(group.ID, tenantID, "moderator"))
+	groups, err = groupRepo.FindByMember(tenantID, "user-2")
+	require.NoError(t, err)
+	require.Empty(t, groups)
+}
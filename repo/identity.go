@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExternalIdentity links the account at an external OpenID Connect provider,
+// named by Issuer and Subject, to a user. An account is linked to at most one
+// user per tenant.
+type ExternalIdentity struct {
+	ID       string    `json:"id" bson:"_id,omitempty"`
+	TenantID string    `json:"-" bson:"tenantId"`
+	UserID   string    `json:"-" bson:"userId"`
+	Issuer   string    `json:"issuer" bson:"issuer"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+type ExternalIdentityRepository interface {
+	Create(identity ExternalIdentity) (ExternalIdentity, error)
+	FindBySubject(tenantID, issuer, subject string) (ExternalIdentity, error)
+	FindByUser(userID string) ([]ExternalIdentity, error)
+	Delete(id, userID string) error
+}
+
+type MongoExternalIdentityRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoExternalIdentityRepository(collection *mongo.Collection) (*MongoExternalIdentityRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenantId", Value: 1}, {Key: "issuer", Value: 1}, {Key: "subject", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "linkedAt", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoExternalIdentityRepository{collection: collection}, err
+}
+
+func (r *MongoExternalIdentityRepository) Create(identity ExternalIdentity) (ExternalIdentity, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, identity)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	identity.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return identity, nil
+}
+
+func (r *MongoExternalIdentityRepository) FindBySubject(tenantID, issuer, subject string) (ExternalIdentity, error) {
+	ctx := context.Background()
+	var identity ExternalIdentity
+	err := r.collection.FindOne(ctx, bson.M{"tenantId": tenantID, "issuer": issuer, "subject": subject}).Decode(&identity)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	return identity, nil
+}
+
+// FindByUser returns the identities linked to the user, oldest first.
+func (r *MongoExternalIdentityRepository) FindByUser(userID string) ([]ExternalIdentity, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"linkedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	identities := []ExternalIdentity{}
+	if err := cursor.All(ctx, &identities); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Delete unlinks an identity of the user, it returns mongo.ErrNoDocuments when
+// the user has no such identity.
+func (r *MongoExternalIdentityRepository) Delete(id, userID string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// FederatedLogin holds the nonce and PKCE code verifier of a login with an
+// external provider until the user comes back with a code. It is identified
+// by the hash of the state parameter and removed once ExpiresAt has passed.
+type FederatedLogin struct {
+	ID       string `bson:"_id"`
+	TenantID string `bson:"tenantId"`
+	// UserID is set when a logged in user links an identity.
+	UserID    string    `bson:"userId,omitempty"`
+	Nonce     string    `bson:"nonce"`
+	Verifier  string    `bson:"verifier"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type FederatedLoginRepository interface {
+	Create(login FederatedLogin) error
+	Consume(id string, at time.Time) (FederatedLogin, error)
+}
+
+type MongoFederatedLoginRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoFederatedLoginRepository(collection *mongo.Collection) (*MongoFederatedLoginRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoFederatedLoginRepository{collection: collection}, err
+}
+
+func (r *MongoFederatedLoginRepository) Create(login FederatedLogin) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, login)
+	return err
+}
+
+// Consume atomically removes the login, so that every state is only used
+// once. It returns mongo.ErrNoDocuments when the login is unknown or expired.
+func (r *MongoFederatedLoginRepository) Consume(id string, at time.Time) (FederatedLogin, error) {
+	ctx := context.Background()
+	var login FederatedLogin
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": at}}).Decode(&login)
+	if err != nil {
+		return FederatedLogin{}, err
+	}
+	return login, nil
+}
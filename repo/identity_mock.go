@@ -0,0 +1,150 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./identity.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./identity.go -destination=./identity_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockExternalIdentityRepository is a mock of ExternalIdentityRepository interface.
+type MockExternalIdentityRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockExternalIdentityRepositoryMockRecorder
+}
+
+// MockExternalIdentityRepositoryMockRecorder is the mock recorder for MockExternalIdentityRepository.
+type MockExternalIdentityRepositoryMockRecorder struct {
+	mock *MockExternalIdentityRepository
+}
+
+// NewMockExternalIdentityRepository creates a new mock instance.
+func NewMockExternalIdentityRepository(ctrl *gomock.Controller) *MockExternalIdentityRepository {
+	mock := &MockExternalIdentityRepository{ctrl: ctrl}
+	mock.recorder = &MockExternalIdentityRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExternalIdentityRepository) EXPECT() *MockExternalIdentityRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockExternalIdentityRepository) Create(identity ExternalIdentity) (ExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", identity)
+	ret0, _ := ret[0].(ExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockExternalIdentityRepositoryMockRecorder) Create(identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockExternalIdentityRepository)(nil).Create), identity)
+}
+
+// Delete mocks base method.
+func (m *MockExternalIdentityRepository) Delete(id, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockExternalIdentityRepositoryMockRecorder) Delete(id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockExternalIdentityRepository)(nil).Delete), id, userID)
+}
+
+// FindBySubject mocks base method.
+func (m *MockExternalIdentityRepository) FindBySubject(tenantID, issuer, subject string) (ExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBySubject", tenantID, issuer, subject)
+	ret0, _ := ret[0].(ExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBySubject indicates an expected call of FindBySubject.
+func (mr *MockExternalIdentityRepositoryMockRecorder) FindBySubject(tenantID, issuer, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBySubject", reflect.TypeOf((*MockExternalIdentityRepository)(nil).FindBySubject), tenantID, issuer, subject)
+}
+
+// FindByUser mocks base method.
+func (m *MockExternalIdentityRepository) FindByUser(userID string) ([]ExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUser", userID)
+	ret0, _ := ret[0].([]ExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUser indicates an expected call of FindByUser.
+func (mr *MockExternalIdentityRepositoryMockRecorder) FindByUser(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockExternalIdentityRepository)(nil).FindByUser), userID)
+}
+
+// MockFederatedLoginRepository is a mock of FederatedLoginRepository interface.
+type MockFederatedLoginRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockFederatedLoginRepositoryMockRecorder
+}
+
+// MockFederatedLoginRepositoryMockRecorder is the mock recorder for MockFederatedLoginRepository.
+type MockFederatedLoginRepositoryMockRecorder struct {
+	mock *MockFederatedLoginRepository
+}
+
+// NewMockFederatedLoginRepository creates a new mock instance.
+func NewMockFederatedLoginRepository(ctrl *gomock.Controller) *MockFederatedLoginRepository {
+	mock := &MockFederatedLoginRepository{ctrl: ctrl}
+	mock.recorder = &MockFederatedLoginRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFederatedLoginRepository) EXPECT() *MockFederatedLoginRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockFederatedLoginRepository) Consume(id string, at time.Time) (FederatedLogin, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", id, at)
+	ret0, _ := ret[0].(FederatedLogin)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockFederatedLoginRepositoryMockRecorder) Consume(id, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockFederatedLoginRepository)(nil).Consume), id, at)
+}
+
+// Create mocks base method.
+func (m *MockFederatedLoginRepository) Create(login FederatedLogin) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockFederatedLoginRepositoryMockRecorder) Create(login any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockFederatedLoginRepository)(nil).Create), login)
+}
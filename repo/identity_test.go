@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestExternalIdentity(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	identityRepo, err := NewMongoExternalIdentityRepository(db.Collection("external_identities"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	userID := randomdata.Alphanumeric(24)
+	identity, err := identityRepo.Create(ExternalIdentity{
+		TenantID: DefaultTenant,
+		UserID:   userID,
+		Issuer:   "https://idp.example.com",
+		Subject:  randomdata.Alphanumeric(16),
+		Email:    randomdata.Email(),
+		LinkedAt: now,
+	})
+	require.NoError(t, err)
+	// Linked to one user per tenant only
+	_, err = identityRepo.Create(ExternalIdentity{TenantID: DefaultTenant, UserID: randomdata.Alphanumeric(24), Issuer: identity.Issuer, Subject: identity.Subject, LinkedAt: now})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	found, err := identityRepo.FindBySubject(DefaultTenant, identity.Issuer, identity.Subject)
+	require.NoError(t, err)
+	require.Equal(t, identity, found)
+	_, err = identityRepo.FindBySubject(randomdata.Alphanumeric(12), identity.Issuer, identity.Subject)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	identities, err := identityRepo.FindByUser(userID)
+	require.NoError(t, err)
+	require.Equal(t, []ExternalIdentity{identity}, identities)
+
+	require.ErrorIs(t, identityRepo.Delete(identity.ID, randomdata.Alphanumeric(24)), mongo.ErrNoDocuments)
+	require.NoError(t, identityRepo.Delete(identity.ID, userID))
+	_, err = identityRepo.FindBySubject(DefaultTenant, identity.Issuer, identity.Subject)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestFederatedLogin(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	loginRepo, err := NewMongoFederatedLoginRepository(db.Collection("federated_logins"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	login := FederatedLogin{
+		ID:        randomdata.Alphanumeric(64),
+		TenantID:  DefaultTenant,
+		Nonce:     randomdata.Alphanumeric(43),
+		Verifier:  randomdata.Alphanumeric(43),
+		ExpiresAt: now.Add(time.Minute),
+	}
+	require.NoError(t, loginRepo.Create(login))
+	expired := login
+	expired.ID = randomdata.Alphanumeric(64)
+	expired.ExpiresAt = now
+	require.NoError(t, loginRepo.Create(expired))
+
+	_, err = loginRepo.Consume(expired.ID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	consumed, err := loginRepo.Consume(login.ID, now)
+	require.NoError(t, err)
+	require.Equal(t, login, consumed)
+	_, err = loginRepo.Consume(login.ID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
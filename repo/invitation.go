@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Invitation allows signing up with Email in a tenant, it is identified by
+// the ID of the signed token emailed to the invitee. It is removed once it was
+// accepted or ExpiresAt has passed.
+type Invitation struct {
+	ID        string    `json:"id" bson:"_id"`
+	TenantID  string    `json:"-" bson:"tenantId"`
+	Email     string    `json:"email" bson:"email"`
+	InvitedBy string    `json:"invitedBy" bson:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+type InvitationRepository interface {
+	Create(invitation Invitation) error
+	FindPending(tenantID string, at time.Time, skip, limit int) ([]Invitation, error)
+	Consume(id, tenantID string, at time.Time) (Invitation, error)
+	Delete(id, tenantID string) error
+	DeleteByEmail(tenantID, email string) error
+}
+
+type MongoInvitationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoInvitationRepository(collection *mongo.Collection) (*MongoInvitationRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "email", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoInvitationRepository{collection: collection}, err
+}
+
+func (r *MongoInvitationRepository) Create(invitation Invitation) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, invitation)
+	return err
+}
+
+// FindPending lists the invitations of the tenant that have not expired at the given time, oldest first.
+func (r *MongoInvitationRepository) FindPending(tenantID string, at time.Time, skip, limit int) ([]Invitation, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.M{"createdAt": 1}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"tenantId": tenantID, "expiresAt": bson.M{"$gt": at}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	invitations := []Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// Consume atomically removes the invitation, so that it is only accepted once.
+// It returns mongo.ErrNoDocuments when the invitation is unknown, expired,
+// revoked or was created in another tenant.
+func (r *MongoInvitationRepository) Consume(id, tenantID string, at time.Time) (Invitation, error) {
+	ctx := context.Background()
+	var invitation Invitation
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"_id":       id,
+		"tenantId":  tenantID,
+		"expiresAt": bson.M{"$gt": at},
+	}).Decode(&invitation)
+	if err != nil {
+		return Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// Delete revokes an invitation of the tenant, it returns mongo.ErrNoDocuments
+// when the tenant has no such invitation.
+func (r *MongoInvitationRepository) Delete(id, tenantID string) error {
+	ctx := context.Background()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "tenantId": tenantID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteByEmail revokes all invitations of email in the tenant.
+func (r *MongoInvitationRepository) DeleteByEmail(tenantID, email string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteMany(ctx, bson.M{"tenantId": tenantID, "email": email})
+	return err
+}
@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./invitation.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./invitation.go -destination=./invitation_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockInvitationRepository is a mock of InvitationRepository interface.
+type MockInvitationRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockInvitationRepositoryMockRecorder
+}
+
+// MockInvitationRepositoryMockRecorder is the mock recorder for MockInvitationRepository.
+type MockInvitationRepositoryMockRecorder struct {
+	mock *MockInvitationRepository
+}
+
+// NewMockInvitationRepository creates a new mock instance.
+func NewMockInvitationRepository(ctrl *gomock.Controller) *MockInvitationRepository {
+	mock := &MockInvitationRepository{ctrl: ctrl}
+	mock.recorder = &MockInvitationRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInvitationRepository) EXPECT() *MockInvitationRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockInvitationRepository) Consume(id, tenantID string, at time.Time) (Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", id, tenantID, at)
+	ret0, _ := ret[0].(Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockInvitationRepositoryMockRecorder) Consume(id, tenantID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockInvitationRepository)(nil).Consume), id, tenantID, at)
+}
+
+// Create mocks base method.
+func (m *MockInvitationRepository) Create(invitation Invitation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", invitation)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockInvitationRepositoryMockRecorder) Create(invitation any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockInvitationRepository)(nil).Create), invitation)
+}
+
+// Delete mocks base method.
+func (m *MockInvitationRepository) Delete(id, tenantID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id, tenantID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockInvitationRepositoryMockRecorder) Delete(id, tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockInvitationRepository)(nil).Delete), id, tenantID)
+}
+
+// DeleteByEmail mocks base method.
+func (m *MockInvitationRepository) DeleteByEmail(tenantID, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByEmail", tenantID, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByEmail indicates an expected call of DeleteByEmail.
+func (mr *MockInvitationRepositoryMockRecorder) DeleteByEmail(tenantID, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByEmail", reflect.TypeOf((*MockInvitationRepository)(nil).DeleteByEmail), tenantID, email)
+}
+
+// FindPending mocks base method.
+func (m *MockInvitationRepository) FindPending(tenantID string, at time.Time, skip, limit int) ([]Invitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPending", tenantID, at, skip, limit)
+	ret0, _ := ret[0].([]Invitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPending indicates an expected call of FindPending.
+func (mr *MockInvitationRepositoryMockRecorder) FindPending(tenantID, at, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPending", reflect.TypeOf((*MockInvitationRepository)(nil).FindPending), tenantID, at, skip, limit)
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestInvitation(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	invitationRepo, err := NewMongoInvitationRepository(db.Collection("invitations"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	tenantID := randomdata.Alphanumeric(12)
+	invitation := Invitation{
+		ID:        randomdata.Alphanumeric(32),
+		TenantID:  tenantID,
+		Email:     randomdata.Email(),
+		InvitedBy: randomdata.Alphanumeric(24),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.NoError(t, invitationRepo.Create(invitation))
+	expired := invitation
+	expired.ID = randomdata.Alphanumeric(32)
+	expired.ExpiresAt = now
+	require.NoError(t, invitationRepo.Create(expired))
+	pending, err := invitationRepo.FindPending(tenantID, now, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []Invitation{invitation}, pending)
+	pending, err = invitationRepo.FindPending(randomdata.Alphanumeric(12), now, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	// Invitations can only be accepted in their tenant and once
+	_, err = invitationRepo.Consume(invitation.ID, randomdata.Alphanumeric(12), now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	_, err = invitationRepo.Consume(expired.ID, tenantID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	consumed, err := invitationRepo.Consume(invitation.ID, tenantID, now)
+	require.NoError(t, err)
+	require.Equal(t, invitation, consumed)
+	_, err = invitationRepo.Consume(invitation.ID, tenantID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestInvitationDelete(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	invitationRepo, err := NewMongoInvitationRepository(db.Collection("invitations"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	tenantID := randomdata.Alphanumeric(12)
+	invitation := Invitation{
+		ID:        randomdata.Alphanumeric(32),
+		TenantID:  tenantID,
+		Email:     randomdata.Email(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.NoError(t, invitationRepo.Create(invitation))
+	require.ErrorIs(t, invitationRepo.Delete(invitation.ID, randomdata.Alphanumeric(12)), mongo.ErrNoDocuments)
+	require.NoError(t, invitationRepo.Delete(invitation.ID, tenantID))
+	require.ErrorIs(t, invitationRepo.Delete(invitation.ID, tenantID), mongo.ErrNoDocuments)
+
+	invitation.ID = randomdata.Alphanumeric(32)
+	require.NoError(t, invitationRepo.Create(invitation))
+	require.NoError(t, invitationRepo.DeleteByEmail(tenantID, invitation.Email))
+	pending, err := invitationRepo.FindPending(tenantID, now, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
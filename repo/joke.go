@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Joke is a joke that has been served by the API and can therefore be rated.
+type Joke struct {
+	ID         string   `json:"id" bson:"_id"`
+	Categories []string `json:"categories" bson:"categories,omitempty"`
+	IconURL    string   `json:"icon_url" bson:"iconUrl"`
+	URL        string   `json:"url" bson:"url"`
+	Value      string   `json:"value" bson:"value"`
+}
+
+// Vote is the vote of a user on a joke, jokes are ranked per tenant.
+type Vote struct {
+	TenantID string    `json:"-" bson:"tenantId"`
+	UserID   string    `json:"userId" bson:"userId"`
+	JokeID   string    `json:"jokeId" bson:"jokeId"`
+	Value    int       `json:"value" bson:"value"`
+	VotedAt  time.Time `json:"votedAt" bson:"votedAt"`
+}
+
+type RatedJoke struct {
+	Joke      `bson:"joke"`
+	Score     int `json:"score" bson:"score"`
+	Upvotes   int `json:"upvotes" bson:"upvotes"`
+	Downvotes int `json:"downvotes" bson:"downvotes"`
+}
+
+type JokeRepository interface {
+	Save(jokes []Joke) error
+	FindOne(id string) (Joke, error)
+}
+
+type VoteRepository interface {
+	Vote(vote Vote) error
+	Top(tenantID string, since time.Time, limit int) ([]RatedJoke, error)
+}
+
+type MongoJokeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoJokeRepository(collection *mongo.Collection) *MongoJokeRepository {
+	return &MongoJokeRepository{collection: collection}
+}
+
+func (r *MongoJokeRepository) Save(jokes []Joke) error {
+	if len(jokes) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	models := make([]mongo.WriteModel, 0, len(jokes))
+	for _, joke := range jokes {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": joke.ID}).
+			SetReplacement(joke).
+			SetUpsert(true))
+	}
+	_, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *MongoJokeRepository) FindOne(id string) (Joke, error) {
+	ctx := context.Background()
+	var joke Joke
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&joke)
+	if err != nil {
+		return Joke{}, err
+	}
+	return joke, nil
+}
+
+type MongoVoteRepository struct {
+	collection *mongo.Collection
+	jokes      string
+}
+
+// NewMongoVoteRepository stores votes in collection and resolves rated jokes
+// from the jokes collection when ranking.
+func NewMongoVoteRepository(collection *mongo.Collection, jokes *mongo.Collection) (*MongoVoteRepository, error) {
+	ctx := context.Background()
+	r := &MongoVoteRepository{collection: collection, jokes: jokes.Name()}
+	// Votes cast before there were tenants belong to the default tenant
+	_, err := collection.UpdateMany(ctx, bson.M{"tenantId": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenantId": DefaultTenant}})
+	if err != nil {
+		return r, err
+	}
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "jokeId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "votedAt", Value: 1}},
+		},
+	}
+	_, err = collection.Indexes().CreateMany(ctx, indexModels)
+
+	return r, err
+}
+
+// Vote records the user's vote for a joke, replacing any earlier vote they cast on it.
+func (r *MongoVoteRepository) Vote(vote Vote) error {
+	ctx := context.Background()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{
+		"tenantId": vote.TenantID,
+		"userId":   vote.UserID,
+		"jokeId":   vote.JokeID,
+	}, vote, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Top returns the highest scoring jokes of the tenant counting only votes cast
+// since the given time.
+func (r *MongoVoteRepository) Top(tenantID string, since time.Time, limit int) ([]RatedJoke, error) {
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenantId": tenantID, "votedAt": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$jokeId",
+			"score":     bson.M{"$sum": "$value"},
+			"upvotes":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$value", 0}}, 1, 0}}},
+			"downvotes": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$value", 0}}, 1, 0}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "upvotes", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.jokes,
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "joke",
+		}}},
+		{{Key: "$unwind", Value: "$joke"}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	rated := []RatedJoke{}
+	if err := cursor.All(ctx, &rated); err != nil {
+		return nil, err
+	}
+	return rated, nil
+}
@@ -0,0 +1,120 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./joke.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./joke.go -destination=./joke_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockJokeRepository is a mock of JokeRepository interface.
+type MockJokeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockJokeRepositoryMockRecorder
+}
+
+// MockJokeRepositoryMockRecorder is the mock recorder for MockJokeRepository.
+type MockJokeRepositoryMockRecorder struct {
+	mock *MockJokeRepository
+}
+
+// NewMockJokeRepository creates a new mock instance.
+func NewMockJokeRepository(ctrl *gomock.Controller) *MockJokeRepository {
+	mock := &MockJokeRepository{ctrl: ctrl}
+	mock.recorder = &MockJokeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJokeRepository) EXPECT() *MockJokeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindOne mocks base method.
+func (m *MockJokeRepository) FindOne(id string) (Joke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id)
+	ret0, _ := ret[0].(Joke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockJokeRepositoryMockRecorder) FindOne(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockJokeRepository)(nil).FindOne), id)
+}
+
+// Save mocks base method.
+func (m *MockJokeRepository) Save(jokes []Joke) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", jokes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockJokeRepositoryMockRecorder) Save(jokes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockJokeRepository)(nil).Save), jokes)
+}
+
+// MockVoteRepository is a mock of VoteRepository interface.
+type MockVoteRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockVoteRepositoryMockRecorder
+}
+
+// MockVoteRepositoryMockRecorder is the mock recorder for MockVoteRepository.
+type MockVoteRepositoryMockRecorder struct {
+	mock *MockVoteRepository
+}
+
+// NewMockVoteRepository creates a new mock instance.
+func NewMockVoteRepository(ctrl *gomock.Controller) *MockVoteRepository {
+	mock := &MockVoteRepository{ctrl: ctrl}
+	mock.recorder = &MockVoteRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockVoteRepository) EXPECT() *MockVoteRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Top mocks base method.
+func (m *MockVoteRepository) Top(tenantID string, since time.Time, limit int) ([]RatedJoke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Top", tenantID, since, limit)
+	ret0, _ := ret[0].([]RatedJoke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Top indicates an expected call of Top.
+func (mr *MockVoteRepositoryMockRecorder) Top(tenantID, since, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Top", reflect.TypeOf((*MockVoteRepository)(nil).Top), tenantID, since, limit)
+}
+
+// Vote mocks base method.
+func (m *MockVoteRepository) Vote(vote Vote) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Vote", vote)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Vote indicates an expected call of Vote.
+func (mr *MockVoteRepositoryMockRecorder) Vote(vote any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vote", reflect.TypeOf((*MockVoteRepository)(nil).Vote), vote)
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteTop(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	jokeRepo := NewMongoJokeRepository(db.Collection("jokes"))
+	voteRepo, err := NewMongoVoteRepository(db.Collection("joke_votes"), db.Collection("jokes"))
+	require.NoError(t, err)
+
+	funny := Joke{ID: randomdata.Alphanumeric(22), Value: randomdata.Paragraph()}
+	lame := Joke{ID: randomdata.Alphanumeric(22), Value: randomdata.Paragraph()}
+	require.NoError(t, jokeRepo.Save([]Joke{funny, lame}))
+	// Saving a joke again must not fail
+	require.NoError(t, jokeRepo.Save([]Joke{funny}))
+
+	found, err := jokeRepo.FindOne(funny.ID)
+	require.NoError(t, err)
+	require.Equal(t, funny, found)
+
+	now := time.Now()
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: DefaultTenant, UserID: "a", JokeID: funny.ID, Value: 1, VotedAt: now}))
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: DefaultTenant, UserID: "b", JokeID: funny.ID, Value: 1, VotedAt: now}))
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: DefaultTenant, UserID: "a", JokeID: lame.ID, Value: 1, VotedAt: now}))
+	// A second vote from the same user replaces the first one
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: DefaultTenant, UserID: "a", JokeID: lame.ID, Value: -1, VotedAt: now}))
+	// Votes outside of the window are not counted
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: DefaultTenant, UserID: "c", JokeID: lame.ID, Value: 1, VotedAt: now.Add(-48 * time.Hour)}))
+
+	top, err := voteRepo.Top(DefaultTenant, now.Add(-24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, funny.ID, top[0].ID)
+	require.Equal(t, funny.Value, top[0].Value)
+	require.Equal(t, 2, top[0].Score)
+	require.Equal(t, 2, top[0].Upvotes)
+	require.Equal(t, lame.ID, top[1].ID)
+	require.Equal(t, -1, top[1].Score)
+	require.Equal(t, 1, top[1].Downvotes)
+
+	top, err = voteRepo.Top(DefaultTenant, now.Add(-24*time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+
+	// The votes of other tenants are ranked apart
+	require.NoError(t, voteRepo.Vote(Vote{TenantID: "acme", UserID: "d", JokeID: lame.ID, Value: 1, VotedAt: now}))
+	top, err = voteRepo.Top("acme", now.Add(-24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	require.Equal(t, lame.ID, top[0].ID)
+	require.Equal(t, 1, top[0].Score)
+}
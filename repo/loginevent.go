@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/geoip"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ways of logging in.
+const (
+	LoginPassword = "password"
+	LoginWebAuthn = "webauthn"
+	LoginOIDC     = "oidc"
+)
+
+// Outcomes of login attempts.
+const (
+	LoginSucceeded   = "success"
+	LoginFailed      = "failure"
+	LoginMFARequired = "mfa_required"
+	LoginLocked      = "locked"
+)
+
+// Flags of suspicious logins.
+const (
+	FlagNewDevice        = "new_device"
+	FlagImpossibleTravel = "impossible_travel"
+)
+
+// LoginEvent is a login attempt. UserID is empty when the email is unknown.
+// Events are removed by a TTL index once ExpiresAt has passed.
+type LoginEvent struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	TenantID  string    `json:"-" bson:"tenantId"`
+	UserID    string    `json:"-" bson:"userId,omitempty"`
+	Email     string    `json:"-" bson:"email,omitempty"`
+	At        time.Time `json:"at" bson:"at"`
+	IP        string    `json:"ip" bson:"ip"`
+	UserAgent string    `json:"userAgent" bson:"userAgent"`
+	// Device is the browser and operating system read from UserAgent, so
+	// browser updates do not make a device look new.
+	Device  string `json:"device,omitempty" bson:"device,omitempty"`
+	Method  string `json:"method" bson:"method"`
+	Outcome string `json:"outcome" bson:"outcome"`
+	// MFA is whether a second factor was verified.
+	MFA      bool            `json:"mfa" bson:"mfa"`
+	Location *geoip.Location `json:"location,omitempty" bson:"location,omitempty"`
+	// Flags mark why a successful login looked suspicious.
+	Flags     []string  `json:"flags,omitempty" bson:"flags,omitempty"`
+	ExpiresAt time.Time `json:"-" bson:"expiresAt"`
+}
+
+type LoginEventRepository interface {
+	Create(event LoginEvent) (LoginEvent, error)
+	FindByUser(userID string, skip, limit int) ([]LoginEvent, error)
+	LastSuccess(userID string) (LoginEvent, error)
+	KnownDevice(userID, device, userAgent string) (bool, error)
+}
+
+type MongoLoginEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginEventRepository(collection *mongo.Collection) (*MongoLoginEventRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "outcome", Value: 1}, {Key: "device", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "outcome", Value: 1}, {Key: "userAgent", Value: 1}},
+		},
+		{
+			Keys:    bson.M{"expiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoLoginEventRepository{collection: collection}, err
+}
+
+func (r *MongoLoginEventRepository) Create(event LoginEvent) (LoginEvent, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		return LoginEvent{}, err
+	}
+	event.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return event, nil
+}
+
+// FindByUser returns a page of the login attempts of the user, newest first.
+func (r *MongoLoginEventRepository) FindByUser(userID string, skip, limit int) ([]LoginEvent, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.M{"at": -1}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []LoginEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// LastSuccess returns the latest successful login of the user, it returns
+// mongo.ErrNoDocuments when the user never logged in.
+func (r *MongoLoginEventRepository) LastSuccess(userID string) (LoginEvent, error) {
+	ctx := context.Background()
+	filter := bson.M{"userId": userID, "outcome": LoginSucceeded}
+	var event LoginEvent
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.M{"at": -1})).Decode(&event)
+	if err != nil {
+		return LoginEvent{}, err
+	}
+	return event, nil
+}
+
+// KnownDevice reports whether the user logged in successfully from the
+// device before. Logins recorded without a device match on the exact user
+// agent instead.
+func (r *MongoLoginEventRepository) KnownDevice(userID, device, userAgent string) (bool, error) {
+	ctx := context.Background()
+	filter := bson.M{"userId": userID, "outcome": LoginSucceeded, "$or": bson.A{
+		bson.M{"device": device},
+		bson.M{"device": bson.M{"$exists": false}, "userAgent": userAgent},
+	}}
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
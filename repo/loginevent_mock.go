@@ -0,0 +1,98 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./loginevent.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./loginevent.go -destination=./loginevent_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginEventRepository is a mock of LoginEventRepository interface.
+type MockLoginEventRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginEventRepositoryMockRecorder
+}
+
+// MockLoginEventRepositoryMockRecorder is the mock recorder for MockLoginEventRepository.
+type MockLoginEventRepositoryMockRecorder struct {
+	mock *MockLoginEventRepository
+}
+
+// NewMockLoginEventRepository creates a new mock instance.
+func NewMockLoginEventRepository(ctrl *gomock.Controller) *MockLoginEventRepository {
+	mock := &MockLoginEventRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginEventRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginEventRepository) EXPECT() *MockLoginEventRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockLoginEventRepository) Create(event LoginEvent) (LoginEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", event)
+	ret0, _ := ret[0].(LoginEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockLoginEventRepositoryMockRecorder) Create(event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockLoginEventRepository)(nil).Create), event)
+}
+
+// FindByUser mocks base method.
+func (m *MockLoginEventRepository) FindByUser(userID string, skip, limit int) ([]LoginEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUser", userID, skip, limit)
+	ret0, _ := ret[0].([]LoginEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUser indicates an expected call of FindByUser.
+func (mr *MockLoginEventRepositoryMockRecorder) FindByUser(userID, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockLoginEventRepository)(nil).FindByUser), userID, skip, limit)
+}
+
+// KnownDevice mocks base method.
+func (m *MockLoginEventRepository) KnownDevice(userID, device, userAgent string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KnownDevice", userID, device, userAgent)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// KnownDevice indicates an expected call of KnownDevice.
+func (mr *MockLoginEventRepositoryMockRecorder) KnownDevice(userID, device, userAgent any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KnownDevice", reflect.TypeOf((*MockLoginEventRepository)(nil).KnownDevice), userID, device, userAgent)
+}
+
+// LastSuccess mocks base method.
+func (m *MockLoginEventRepository) LastSuccess(userID string) (LoginEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastSuccess", userID)
+	ret0, _ := ret[0].(LoginEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastSuccess indicates an expected call of LastSuccess.
+func (mr *MockLoginEventRepositoryMockRecorder) LastSuccess(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastSuccess", reflect.TypeOf((*MockLoginEventRepository)(nil).LastSuccess), userID)
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Davut97/go-user/pkg/geoip"
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestLoginEvent(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	eventRepo, err := NewMongoLoginEventRepository(db.Collection("login_events"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	userID := randomdata.Alphanumeric(24)
+	_, err = eventRepo.LastSuccess(userID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	success, err := eventRepo.Create(LoginEvent{
+		TenantID:  DefaultTenant,
+		UserID:    userID,
+		At:        now.Add(-time.Hour),
+		IP:        "8.8.8.8",
+		UserAgent: "laptop",
+		Method:    LoginPassword,
+		Outcome:   LoginSucceeded,
+		Location:  &geoip.Location{Country: "US", City: "Mountain View", Latitude: 37.422, Longitude: -122.085},
+		ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	failure, err := eventRepo.Create(LoginEvent{TenantID: DefaultTenant, UserID: userID, At: now, UserAgent: "phone", Method: LoginPassword, Outcome: LoginFailed, ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	events, err := eventRepo.FindByUser(userID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []LoginEvent{failure, success}, events)
+	events, err = eventRepo.FindByUser(userID, 1, 10)
+	require.NoError(t, err)
+	require.Equal(t, []LoginEvent{success}, events)
+
+	last, err := eventRepo.LastSuccess(userID)
+	require.NoError(t, err)
+	require.Equal(t, success, last)
+	known, err := eventRepo.KnownDevice(userID, "laptop", "laptop")
+	require.NoError(t, err)
+	require.True(t, known)
+	known, err = eventRepo.KnownDevice(userID, "phone", "phone")
+	require.NoError(t, err)
+	require.False(t, known)
+	_, err = eventRepo.Create(LoginEvent{TenantID: DefaultTenant, UserID: userID, At: now, UserAgent: "Firefox/130.0", Device: "Firefox", Method: LoginPassword, Outcome: LoginSucceeded, ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	known, err = eventRepo.KnownDevice(userID, "Firefox", "Firefox/131.0")
+	require.NoError(t, err)
+	require.True(t, known)
+}
@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OAuthClient is an app users of a tenant can sign in to with their account.
+// Clients without a SecretHash are public and rely on PKCE alone.
+type OAuthClient struct {
+	ID           string    `json:"id" bson:"_id,omitempty"`
+	TenantID     string    `json:"-" bson:"tenantId"`
+	Name         string    `json:"name" bson:"name"`
+	SecretHash   string    `json:"-" bson:"secretHash,omitempty"`
+	RedirectURIs []string  `json:"redirectUris" bson:"redirectUris"`
+	CreatedBy    string    `json:"createdBy" bson:"createdBy"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Public reports whether the client can not keep a secret.
+func (c OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+type OAuthClientRepository interface {
+	Create(client OAuthClient) (OAuthClient, error)
+	FindOne(id string) (OAuthClient, error)
+	Find(tenantID string, skip, limit int) ([]OAuthClient, error)
+	Delete(id, tenantID string) error
+}
+
+type MongoOAuthClientRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOAuthClientRepository(collection *mongo.Collection) (*MongoOAuthClientRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "createdAt", Value: 1}},
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoOAuthClientRepository{collection: collection}, err
+}
+
+func (r *MongoOAuthClientRepository) Create(client OAuthClient) (OAuthClient, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, client)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	client.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return client, nil
+}
+
+// FindOne finds a client of any tenant, clients are identified by their ID
+// alone when users sign in to them.
+func (r *MongoOAuthClientRepository) FindOne(id string) (OAuthClient, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	var client OAuthClient
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&client)
+	if err != nil {
+		return OAuthClient{}, err
+	}
+	return client, nil
+}
+
+// Find lists the clients of the tenant, oldest first.
+func (r *MongoOAuthClientRepository) Find(tenantID string, skip, limit int) ([]OAuthClient, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.M{"createdAt": 1}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"tenantId": tenantID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	clients := []OAuthClient{}
+	if err := cursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// Delete removes a client of the tenant, it returns mongo.ErrNoDocuments when
+// the tenant has no such client.
+func (r *MongoOAuthClientRepository) Delete(id, tenantID string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "tenantId": tenantID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// AuthorizationCode is handed to a client once the user consented, the client
+// exchanges it for tokens. It is identified by the hash of the code and
+// removed once it was exchanged or ExpiresAt has passed.
+type AuthorizationCode struct {
+	ID          string `bson:"_id"`
+	ClientID    string `bson:"clientId"`
+	TenantID    string `bson:"tenantId"`
+	UserID      string `bson:"userId"`
+	RedirectURI string `bson:"redirectUri"`
+	Scope       string `bson:"scope"`
+	Nonce       string `bson:"nonce,omitempty"`
+	// CodeChallenge is the S256 PKCE challenge of the authorization request.
+	CodeChallenge string    `bson:"codeChallenge"`
+	AuthTime      time.Time `bson:"authTime"`
+	ExpiresAt     time.Time `bson:"expiresAt"`
+}
+
+type AuthorizationCodeRepository interface {
+	Create(code AuthorizationCode) error
+	Consume(id string, at time.Time) (AuthorizationCode, error)
+}
+
+type MongoAuthorizationCodeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuthorizationCodeRepository(collection *mongo.Collection) (*MongoAuthorizationCodeRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoAuthorizationCodeRepository{collection: collection}, err
+}
+
+func (r *MongoAuthorizationCodeRepository) Create(code AuthorizationCode) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, code)
+	return err
+}
+
+// Consume atomically removes the code, so that it is only exchanged once. It
+// returns mongo.ErrNoDocuments when the code is unknown, used or expired.
+func (r *MongoAuthorizationCodeRepository) Consume(id string, at time.Time) (AuthorizationCode, error) {
+	ctx := context.Background()
+	var code AuthorizationCode
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": at}}).Decode(&code)
+	if err != nil {
+		return AuthorizationCode{}, err
+	}
+	return code, nil
+}
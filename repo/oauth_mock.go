@@ -0,0 +1,150 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./oauth.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./oauth.go -destination=./oauth_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOAuthClientRepository is a mock of OAuthClientRepository interface.
+type MockOAuthClientRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockOAuthClientRepositoryMockRecorder
+}
+
+// MockOAuthClientRepositoryMockRecorder is the mock recorder for MockOAuthClientRepository.
+type MockOAuthClientRepositoryMockRecorder struct {
+	mock *MockOAuthClientRepository
+}
+
+// NewMockOAuthClientRepository creates a new mock instance.
+func NewMockOAuthClientRepository(ctrl *gomock.Controller) *MockOAuthClientRepository {
+	mock := &MockOAuthClientRepository{ctrl: ctrl}
+	mock.recorder = &MockOAuthClientRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOAuthClientRepository) EXPECT() *MockOAuthClientRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockOAuthClientRepository) Create(client OAuthClient) (OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", client)
+	ret0, _ := ret[0].(OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockOAuthClientRepositoryMockRecorder) Create(client any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOAuthClientRepository)(nil).Create), client)
+}
+
+// Delete mocks base method.
+func (m *MockOAuthClientRepository) Delete(id, tenantID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id, tenantID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockOAuthClientRepositoryMockRecorder) Delete(id, tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOAuthClientRepository)(nil).Delete), id, tenantID)
+}
+
+// Find mocks base method.
+func (m *MockOAuthClientRepository) Find(tenantID string, skip, limit int) ([]OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", tenantID, skip, limit)
+	ret0, _ := ret[0].([]OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockOAuthClientRepositoryMockRecorder) Find(tenantID, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockOAuthClientRepository)(nil).Find), tenantID, skip, limit)
+}
+
+// FindOne mocks base method.
+func (m *MockOAuthClientRepository) FindOne(id string) (OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id)
+	ret0, _ := ret[0].(OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockOAuthClientRepositoryMockRecorder) FindOne(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockOAuthClientRepository)(nil).FindOne), id)
+}
+
+// MockAuthorizationCodeRepository is a mock of AuthorizationCodeRepository interface.
+type MockAuthorizationCodeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthorizationCodeRepositoryMockRecorder
+}
+
+// MockAuthorizationCodeRepositoryMockRecorder is the mock recorder for MockAuthorizationCodeRepository.
+type MockAuthorizationCodeRepositoryMockRecorder struct {
+	mock *MockAuthorizationCodeRepository
+}
+
+// NewMockAuthorizationCodeRepository creates a new mock instance.
+func NewMockAuthorizationCodeRepository(ctrl *gomock.Controller) *MockAuthorizationCodeRepository {
+	mock := &MockAuthorizationCodeRepository{ctrl: ctrl}
+	mock.recorder = &MockAuthorizationCodeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthorizationCodeRepository) EXPECT() *MockAuthorizationCodeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockAuthorizationCodeRepository) Consume(id string, at time.Time) (AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", id, at)
+	ret0, _ := ret[0].(AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockAuthorizationCodeRepositoryMockRecorder) Consume(id, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockAuthorizationCodeRepository)(nil).Consume), id, at)
+}
+
+// Create mocks base method.
+func (m *MockAuthorizationCodeRepository) Create(code AuthorizationCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAuthorizationCodeRepositoryMockRecorder) Create(code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAuthorizationCodeRepository)(nil).Create), code)
+}
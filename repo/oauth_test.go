@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOAuthClient(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	clientRepo, err := NewMongoOAuthClientRepository(db.Collection("oauth_clients"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	tenantID := randomdata.Alphanumeric(12)
+	client, err := clientRepo.Create(OAuthClient{
+		TenantID:     tenantID,
+		Name:         "Wiki",
+		SecretHash:   randomdata.Alphanumeric(64),
+		RedirectURIs: []string{"https://wiki.example.com/callback"},
+		CreatedBy:    randomdata.Alphanumeric(24),
+		CreatedAt:    now,
+	})
+	require.NoError(t, err)
+	require.False(t, client.Public())
+
+	found, err := clientRepo.FindOne(client.ID)
+	require.NoError(t, err)
+	require.Equal(t, client, found)
+	clients, err := clientRepo.Find(tenantID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []OAuthClient{client}, clients)
+	clients, err = clientRepo.Find(randomdata.Alphanumeric(12), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, clients)
+
+	require.ErrorIs(t, clientRepo.Delete(client.ID, randomdata.Alphanumeric(12)), mongo.ErrNoDocuments)
+	require.NoError(t, clientRepo.Delete(client.ID, tenantID))
+	_, err = clientRepo.FindOne(client.ID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestAuthorizationCode(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	codeRepo, err := NewMongoAuthorizationCodeRepository(db.Collection("authorization_codes"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	code := AuthorizationCode{
+		ID:            randomdata.Alphanumeric(64),
+		ClientID:      randomdata.Alphanumeric(24),
+		TenantID:      DefaultTenant,
+		UserID:        randomdata.Alphanumeric(24),
+		RedirectURI:   "https://wiki.example.com/callback",
+		Scope:         "openid email",
+		Nonce:         randomdata.Alphanumeric(16),
+		CodeChallenge: randomdata.Alphanumeric(43),
+		AuthTime:      now,
+		ExpiresAt:     now.Add(time.Minute),
+	}
+	require.NoError(t, codeRepo.Create(code))
+	expired := code
+	expired.ID = randomdata.Alphanumeric(64)
+	expired.ExpiresAt = now
+	require.NoError(t, codeRepo.Create(expired))
+
+	// Codes can only be exchanged once and before they expire
+	_, err = codeRepo.Consume(expired.ID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	consumed, err := codeRepo.Consume(code.ID, now)
+	require.NoError(t, err)
+	require.Equal(t, code, consumed)
+	_, err = codeRepo.Consume(code.ID, now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OneTimeToken records a single-use token, such as an email verification
+// token, by its ID or the hash of an opaque token. It is removed once
+// ExpiresAt has passed.
+type OneTimeToken struct {
+	ID        string     `json:"id" bson:"_id"`
+	UserID    string     `json:"userId" bson:"userId"`
+	Purpose   string     `json:"purpose" bson:"purpose"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+}
+
+type OneTimeTokenRepository interface {
+	Create(token OneTimeToken) error
+	FindOne(id string) (OneTimeToken, error)
+	Consume(id, purpose string, at time.Time) (OneTimeToken, error)
+	DeleteByUser(userID, purpose string) error
+}
+
+type MongoOneTimeTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOneTimeTokenRepository(collection *mongo.Collection) (*MongoOneTimeTokenRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"expiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoOneTimeTokenRepository{collection: collection}, err
+}
+
+func (r *MongoOneTimeTokenRepository) Create(token OneTimeToken) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *MongoOneTimeTokenRepository) FindOne(id string) (OneTimeToken, error) {
+	ctx := context.Background()
+	var token OneTimeToken
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&token)
+	if err != nil {
+		return OneTimeToken{}, err
+	}
+	return token, nil
+}
+
+// Usable reports whether the token can still be consumed for purpose at the given time.
+func (t OneTimeToken) Usable(purpose string, at time.Time) bool {
+	return t.Purpose == purpose && t.UsedAt == nil && at.Before(t.ExpiresAt)
+}
+
+// Consume atomically marks the token as used. It returns
+// mongo.ErrNoDocuments when the token is unknown, already used, expired or
+// was issued for another purpose.
+func (r *MongoOneTimeTokenRepository) Consume(id, purpose string, at time.Time) (OneTimeToken, error) {
+	ctx := context.Background()
+	var token OneTimeToken
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":       id,
+		"purpose":   purpose,
+		"usedAt":    bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": at},
+	}, bson.M{"$set": bson.M{"usedAt": at}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&token)
+	if err != nil {
+		return OneTimeToken{}, err
+	}
+	return token, nil
+}
+
+// DeleteByUser revokes all tokens of the user issued for purpose.
+func (r *MongoOneTimeTokenRepository) DeleteByUser(userID, purpose string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteMany(ctx, bson.M{"userId": userID, "purpose": purpose})
+	return err
+}
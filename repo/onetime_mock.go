@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./onetime.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./onetime.go -destination=./onetime_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOneTimeTokenRepository is a mock of OneTimeTokenRepository interface.
+type MockOneTimeTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockOneTimeTokenRepositoryMockRecorder
+}
+
+// MockOneTimeTokenRepositoryMockRecorder is the mock recorder for MockOneTimeTokenRepository.
+type MockOneTimeTokenRepositoryMockRecorder struct {
+	mock *MockOneTimeTokenRepository
+}
+
+// NewMockOneTimeTokenRepository creates a new mock instance.
+func NewMockOneTimeTokenRepository(ctrl *gomock.Controller) *MockOneTimeTokenRepository {
+	mock := &MockOneTimeTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockOneTimeTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOneTimeTokenRepository) EXPECT() *MockOneTimeTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockOneTimeTokenRepository) Consume(id, purpose string, at time.Time) (OneTimeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", id, purpose, at)
+	ret0, _ := ret[0].(OneTimeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockOneTimeTokenRepositoryMockRecorder) Consume(id, purpose, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockOneTimeTokenRepository)(nil).Consume), id, purpose, at)
+}
+
+// Create mocks base method.
+func (m *MockOneTimeTokenRepository) Create(token OneTimeToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockOneTimeTokenRepositoryMockRecorder) Create(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOneTimeTokenRepository)(nil).Create), token)
+}
+
+// DeleteByUser mocks base method.
+func (m *MockOneTimeTokenRepository) DeleteByUser(userID, purpose string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByUser", userID, purpose)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByUser indicates an expected call of DeleteByUser.
+func (mr *MockOneTimeTokenRepositoryMockRecorder) DeleteByUser(userID, purpose any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByUser", reflect.TypeOf((*MockOneTimeTokenRepository)(nil).DeleteByUser), userID, purpose)
+}
+
+// FindOne mocks base method.
+func (m *MockOneTimeTokenRepository) FindOne(id string) (OneTimeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id)
+	ret0, _ := ret[0].(OneTimeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockOneTimeTokenRepositoryMockRecorder) FindOne(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockOneTimeTokenRepository)(nil).FindOne), id)
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneTimeTokenConsume(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	tokenRepo, err := NewMongoOneTimeTokenRepository(db.Collection("one_time_tokens"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	token := OneTimeToken{
+		ID:        randomdata.Alphanumeric(32),
+		UserID:    randomdata.Alphanumeric(24),
+		Purpose:   "verify-email",
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.NoError(t, tokenRepo.Create(token))
+	found, err := tokenRepo.FindOne(token.ID)
+	require.NoError(t, err)
+	require.True(t, found.Usable("verify-email", now))
+	require.False(t, found.Usable("reset-password", now))
+	require.False(t, found.Usable("verify-email", now.Add(time.Hour)))
+
+	_, err = tokenRepo.Consume(token.ID, "reset-password", now)
+	require.Error(t, err)
+	consumed, err := tokenRepo.Consume(token.ID, "verify-email", now)
+	require.NoError(t, err)
+	require.Equal(t, token.UserID, consumed.UserID)
+	require.Equal(t, now, *consumed.UsedAt)
+
+	// A token can only be used once
+	_, err = tokenRepo.Consume(token.ID, "verify-email", now)
+	require.Error(t, err)
+	require.False(t, consumed.Usable("verify-email", now))
+}
+
+func TestOneTimeTokenExpiredAndRevoked(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	tokenRepo, err := NewMongoOneTimeTokenRepository(db.Collection("one_time_tokens"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	userID := randomdata.Alphanumeric(24)
+	expired := OneTimeToken{ID: randomdata.Alphanumeric(32), UserID: userID, Purpose: "verify-email", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, tokenRepo.Create(expired))
+	_, err = tokenRepo.Consume(expired.ID, "verify-email", now.Add(2*time.Hour))
+	require.Error(t, err)
+
+	revoked := OneTimeToken{ID: randomdata.Alphanumeric(32), UserID: userID, Purpose: "verify-email", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, tokenRepo.Create(revoked))
+	require.NoError(t, tokenRepo.DeleteByUser(userID, "verify-email"))
+	_, err = tokenRepo.Consume(revoked.ID, "verify-email", now)
+	require.Error(t, err)
+}
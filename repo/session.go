@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Session is a login on a device. The access token handed out on login names
+// its session, which is only accepted while the session exists, so deleting
+// it revokes the token before it expires. Sessions are removed by a TTL index
+// once their token expired.
+type Session struct {
+	ID         string    `json:"id" bson:"_id,omitempty"`
+	TenantID   string    `json:"-" bson:"tenantId"`
+	UserID     string    `json:"-" bson:"userId"`
+	UserAgent  string    `json:"userAgent" bson:"userAgent"`
+	IP         string    `json:"ip" bson:"ip"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt" bson:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+type SessionRepository interface {
+	Create(session Session) (Session, error)
+	FindOne(id string) (Session, error)
+	FindByUser(userID string, at time.Time) ([]Session, error)
+	Delete(id, userID string) error
+	DeleteByUser(userID string) (int64, error)
+	Touch(id string, at time.Time) error
+}
+
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSessionRepository(collection *mongo.Collection) (*MongoSessionRepository, error) {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastSeenAt", Value: -1}},
+		},
+		{
+			Keys:    bson.M{"expiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+
+	return &MongoSessionRepository{collection: collection}, err
+}
+
+func (r *MongoSessionRepository) Create(session Session) (Session, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		return Session{}, err
+	}
+	session.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return session, nil
+}
+
+// FindOne returns the session, expired sessions the TTL index did not remove
+// yet included.
+func (r *MongoSessionRepository) FindOne(id string) (Session, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Session{}, err
+	}
+	var session Session
+	if err := r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&session); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// FindByUser returns the sessions of the user that did not expire at the
+// given time, the most recently seen first.
+func (r *MongoSessionRepository) FindByUser(userID string, at time.Time) ([]Session, error) {
+	ctx := context.Background()
+	filter := bson.M{"userId": userID, "expiresAt": bson.M{"$gt": at}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"lastSeenAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete revokes a session of the user, it returns mongo.ErrNoDocuments when
+// the user has no such session.
+func (r *MongoSessionRepository) Delete(id, userID string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// DeleteByUser revokes all sessions of the user and returns how many there
+// were.
+func (r *MongoSessionRepository) DeleteByUser(userID string) (int64, error) {
+	ctx := context.Background()
+	result, err := r.collection.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Touch records that the session was used at the given time.
+func (r *MongoSessionRepository) Touch(id string, at time.Time) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"lastSeenAt": at}})
+	return err
+}
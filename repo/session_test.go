@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSession(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	sessionRepo, err := NewMongoSessionRepository(db.Collection("sessions"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	userID := randomdata.Alphanumeric(24)
+	older, err := sessionRepo.Create(Session{
+		TenantID:   DefaultTenant,
+		UserID:     userID,
+		UserAgent:  "curl/8.0",
+		IP:         "10.0.0.1",
+		CreatedAt:  now.Add(-time.Hour),
+		LastSeenAt: now.Add(-time.Hour),
+		ExpiresAt:  now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	session, err := sessionRepo.Create(Session{TenantID: DefaultTenant, UserID: userID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = sessionRepo.Create(Session{TenantID: DefaultTenant, UserID: userID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+
+	found, err := sessionRepo.FindOne(session.ID)
+	require.NoError(t, err)
+	require.Equal(t, session, found)
+	sessions, err := sessionRepo.FindByUser(userID, now)
+	require.NoError(t, err)
+	require.Equal(t, []Session{session, older}, sessions)
+
+	require.NoError(t, sessionRepo.Touch(older.ID, now.Add(time.Minute)))
+	found, err = sessionRepo.FindOne(older.ID)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Minute), found.LastSeenAt)
+
+	require.ErrorIs(t, sessionRepo.Delete(session.ID, randomdata.Alphanumeric(24)), mongo.ErrNoDocuments)
+	require.NoError(t, sessionRepo.Delete(session.ID, userID))
+	_, err = sessionRepo.FindOne(session.ID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	deleted, err := sessionRepo.DeleteByUser(userID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+	sessions, err = sessionRepo.FindByUser(userID, now)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
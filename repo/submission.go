@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SubmissionStatus string
+
+const (
+	SubmissionPending  SubmissionStatus = "pending"
+	SubmissionApproved SubmissionStatus = "approved"
+	SubmissionRejected SubmissionStatus = "rejected"
+)
+
+// Submission is a joke submitted by a user that has to be approved by a
+// moderator of the same tenant before it is served to the tenant.
+type Submission struct {
+	ID          string           `json:"id" bson:"_id,omitempty"`
+	TenantID    string           `json:"-" bson:"tenantId"`
+	UserID      string           `json:"userId" bson:"userId"`
+	Value       string           `json:"value" bson:"value"`
+	Categories  []string         `json:"categories" bson:"categories,omitempty"`
+	Status      SubmissionStatus `json:"status" bson:"status"`
+	Reason      string           `json:"reason,omitempty" bson:"reason,omitempty"`
+	ModeratedBy string           `json:"moderatedBy,omitempty" bson:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time       `json:"moderatedAt,omitempty" bson:"moderatedAt,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt" bson:"createdAt"`
+}
+
+type SubmissionRepository interface {
+	Create(submission Submission) (Submission, error)
+	FindOne(id, tenantID string) (Submission, error)
+	FindByStatus(tenantID string, status SubmissionStatus, skip, limit int) ([]Submission, error)
+	Moderate(id, tenantID string, status SubmissionStatus, moderatorID, reason string) (Submission, error)
+	RandomApproved(tenantID string, limit int) ([]Submission, error)
+}
+
+type MongoSubmissionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSubmissionRepository(collection *mongo.Collection) (*MongoSubmissionRepository, error) {
+	ctx := context.Background()
+	r := &MongoSubmissionRepository{collection: collection}
+	// Submissions made before there were tenants belong to the default tenant
+	_, err := collection.UpdateMany(ctx, bson.M{"tenantId": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenantId": DefaultTenant}})
+	if err != nil {
+		return r, err
+	}
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "tenantId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+	}
+	_, err = collection.Indexes().CreateOne(ctx, indexModel)
+
+	return r, err
+}
+
+func (r *MongoSubmissionRepository) Create(submission Submission) (Submission, error) {
+	ctx := context.Background()
+	doc, err := r.collection.InsertOne(ctx, submission)
+	if err != nil {
+		return Submission{}, err
+	}
+	submission.ID = doc.InsertedID.(primitive.ObjectID).Hex()
+	return submission, nil
+}
+
+func (r *MongoSubmissionRepository) FindOne(id, tenantID string) (Submission, error) {
+	ctx := context.Background()
+	var submission Submission
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID, "tenantId": tenantID}).Decode(&submission)
+	if err != nil {
+		return Submission{}, err
+	}
+	return submission, nil
+}
+
+// FindByStatus lists submissions of the tenant with the given status, oldest first.
+func (r *MongoSubmissionRepository) FindByStatus(tenantID string, status SubmissionStatus, skip, limit int) ([]Submission, error) {
+	ctx := context.Background()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"tenantId": tenantID, "status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	submissions := []Submission{}
+	if err := cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// Moderate approves or rejects a pending submission. Submissions that are not
+// pending anymore are left untouched and mongo.ErrNoDocuments is returned.
+func (r *MongoSubmissionRepository) Moderate(id, tenantID string, status SubmissionStatus, moderatorID, reason string) (Submission, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	var submission Submission
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":      objID,
+		"tenantId": tenantID,
+		"status":   SubmissionPending,
+	}, bson.M{
+		"$set": bson.M{
+			"status":      status,
+			"reason":      reason,
+			"moderatedBy": moderatorID,
+			"moderatedAt": time.Now(),
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&submission)
+	if err != nil {
+		return Submission{}, err
+	}
+	return submission, nil
+}
+
+// RandomApproved samples up to limit approved submissions of the tenant.
+func (r *MongoSubmissionRepository) RandomApproved(tenantID string, limit int) ([]Submission, error) {
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenantId": tenantID, "status": SubmissionApproved}}},
+		{{Key: "$sample", Value: bson.M{"size": limit}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	submissions := []Submission{}
+	if err := cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
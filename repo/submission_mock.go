@@ -0,0 +1,113 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./submission.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./submission.go -destination=./submission_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSubmissionRepository is a mock of SubmissionRepository interface.
+type MockSubmissionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubmissionRepositoryMockRecorder
+}
+
+// MockSubmissionRepositoryMockRecorder is the mock recorder for MockSubmissionRepository.
+type MockSubmissionRepositoryMockRecorder struct {
+	mock *MockSubmissionRepository
+}
+
+// NewMockSubmissionRepository creates a new mock instance.
+func NewMockSubmissionRepository(ctrl *gomock.Controller) *MockSubmissionRepository {
+	mock := &MockSubmissionRepository{ctrl: ctrl}
+	mock.recorder = &MockSubmissionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubmissionRepository) EXPECT() *MockSubmissionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSubmissionRepository) Create(submission Submission) (Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", submission)
+	ret0, _ := ret[0].(Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSubmissionRepositoryMockRecorder) Create(submission any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSubmissionRepository)(nil).Create), submission)
+}
+
+// FindByStatus mocks base method.
+func (m *MockSubmissionRepository) FindByStatus(tenantID string, status SubmissionStatus, skip, limit int) ([]Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByStatus", tenantID, status, skip, limit)
+	ret0, _ := ret[0].([]Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByStatus indicates an expected call of FindByStatus.
+func (mr *MockSubmissionRepositoryMockRecorder) FindByStatus(tenantID, status, skip, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByStatus", reflect.TypeOf((*MockSubmissionRepository)(nil).FindByStatus), tenantID, status, skip, limit)
+}
+
+// FindOne mocks base method.
+func (m *MockSubmissionRepository) FindOne(id, tenantID string) (Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id, tenantID)
+	ret0, _ := ret[0].(Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockSubmissionRepositoryMockRecorder) FindOne(id, tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockSubmissionRepository)(nil).FindOne), id, tenantID)
+}
+
+// Moderate mocks base method.
+func (m *MockSubmissionRepository) Moderate(id, tenantID string, status SubmissionStatus, moderatorID, reason string) (Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Moderate", id, tenantID, status, moderatorID, reason)
+	ret0, _ := ret[0].(Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Moderate indicates an expected call of Moderate.
+func (mr *MockSubmissionRepositoryMockRecorder) Moderate(id, tenantID, status, moderatorID, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Moderate", reflect.TypeOf((*MockSubmissionRepository)(nil).Moderate), id, tenantID, status, moderatorID, reason)
+}
+
+// RandomApproved mocks base method.
+func (m *MockSubmissionRepository) RandomApproved(tenantID string, limit int) ([]Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RandomApproved", tenantID, limit)
+	ret0, _ := ret[0].([]Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RandomApproved indicates an expected call of RandomApproved.
+func (mr *MockSubmissionRepositoryMockRecorder) RandomApproved(tenantID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RandomApproved", reflect.TypeOf((*MockSubmissionRepository)(nil).RandomApproved), tenantID, limit)
+}
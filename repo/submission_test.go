@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSubmissionModeration(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	submissionRepo, err := NewMongoSubmissionRepository(db.Collection("joke_submissions"))
+	require.NoError(t, err)
+
+	submission, err := submissionRepo.Create(Submission{
+		TenantID:  DefaultTenant,
+		UserID:    "user-1",
+		Value:     randomdata.Paragraph(),
+		Status:    SubmissionPending,
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, submission.ID)
+
+	pending, err := submissionRepo.FindByStatus(DefaultTenant, SubmissionPending, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, submission.ID, pending[0].ID)
+
+	approved, err := submissionRepo.RandomApproved(DefaultTenant, 5)
+	require.NoError(t, err)
+	require.Empty(t, approved)
+
+	// Other tenants neither see nor moderate the submission
+	pending, err = submissionRepo.FindByStatus("acme", SubmissionPending, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+	_, err = submissionRepo.FindOne(submission.ID, "acme")
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	_, err = submissionRepo.Moderate(submission.ID, "acme", SubmissionApproved, "admin-2", "")
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	moderated, err := submissionRepo.Moderate(submission.ID, DefaultTenant, SubmissionApproved, "admin-1", "")
+	require.NoError(t, err)
+	require.Equal(t, SubmissionApproved, moderated.Status)
+	require.Equal(t, "admin-1", moderated.ModeratedBy)
+	require.NotNil(t, moderated.ModeratedAt)
+
+	// A moderated submission can not be moderated again
+	_, err = submissionRepo.Moderate(submission.ID, DefaultTenant, SubmissionRejected, "admin-2", "")
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	approved, err = submissionRepo.RandomApproved(DefaultTenant, 5)
+	require.NoError(t, err)
+	require.Len(t, approved, 1)
+	require.Equal(t, submission.Value, approved[0].Value)
+	approved, err = submissionRepo.RandomApproved("acme", 5)
+	require.NoError(t, err)
+	require.Empty(t, approved)
+
+	pending, err = submissionRepo.FindByStatus(DefaultTenant, SubmissionPending, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
@@ -2,20 +2,42 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/Davut97/go-user/pkg/password"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID        string  `json:"id" bson:"_id,omitempty"`
+	ID string `json:"id" bson:"_id,omitempty"`
+	// TenantID is the organization the user belongs to, emails are unique per tenant.
+	TenantID  string  `json:"tenantId" bson:"tenantId"`
 	Email     string  `json:"email"`
 	FirstName string  `json:"firstName"`
 	LastName  string  `json:"lastName"`
 	Password  *string `json:"-"`
+	// EmailVerified is set once the user followed the link sent to Email.
+	EmailVerified bool `json:"emailVerified" bson:"emailVerified"`
+	// PasswordChangedAt is when the password was last reset or changed,
+	// access tokens issued before are no longer accepted.
+	PasswordChangedAt *time.Time `json:"-" bson:"passwordChangedAt,omitempty"`
+	// PasswordHistory holds the hashes of previous passwords, oldest first.
+	PasswordHistory []string `json:"-" bson:"passwordHistory,omitempty"`
+	// TOTPSecret is the encrypted TOTP secret, TOTPEnabled is set once the
+	// user confirmed it with a first code.
+	TOTPSecret  string `json:"-" bson:"totpSecret,omitempty"`
+	TOTPEnabled bool   `json:"totpEnabled" bson:"totpEnabled"`
+	// TOTPCounter is the period of the last accepted code, codes of the same
+	// or earlier periods are replays.
+	TOTPCounter int64 `json:"-" bson:"totpCounter,omitempty"`
+	// RecoveryCodes holds the hashes of the unused recovery codes.
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes,omitempty"`
+	// Roles grant the user permissions, see the rbac package.
+	Roles []string `json:"roles,omitempty" bson:"roles,omitempty"`
 }
 
 type UserRepository interface {
@@ -24,29 +46,78 @@ type UserRepository interface {
 	Create(user User) (User, error)
 	Update(user User) (User, error)
 	Delete(id string) error
+	VerifyEmail(id string) error
+	UpdatePassword(id, password string, at time.Time, history int) error
+	SetTOTPSecret(id, secret string) error
+	EnableTOTP(id string, counter int64, recoveryCodes []string) error
+	DisableTOTP(id string) error
+	UseTOTPCounter(id string, counter int64) error
+	UseRecoveryCode(id, recoveryCode string) error
+	GrantRole(id, role string) error
+	RevokeRole(id, role string) error
 }
 
+// DefaultTenant is the tenant of users created before there were tenants and
+// of requests that do not name one.
+const DefaultTenant = "default"
+
+// UserTenants hands out user repositories that only see the users of one tenant.
+type UserTenants interface {
+	ForTenant(tenantID string) UserRepository
+}
+
+// MongoUserRepository only reads and writes the users of its tenant, every
+// filter is scoped by the tenant ID.
 type MongoUserRepository struct {
 	collection *mongo.Collection
+	hasher     *password.Hasher
+	tenantID   string
 }
 
-func NewMongoUserRepository(collection *mongo.Collection) (*MongoUserRepository, error) {
+// NewMongoUserRepository stores users in collection, hashing their passwords
+// with hasher. The repository is scoped to DefaultTenant, use ForTenant for
+// the others.
+func NewMongoUserRepository(collection *mongo.Collection, hasher *password.Hasher) (*MongoUserRepository, error) {
+	ctx := context.Background()
+	r := &MongoUserRepository{collection: collection, hasher: hasher, tenantID: DefaultTenant}
+	// Emails used to be unique across all users, which belong to the default tenant now.
+	_, err := collection.Indexes().DropOne(ctx, "email_1")
+	var commandErr mongo.CommandError
+	if err != nil && !(errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound")) {
+		return r, err
+	}
+	_, err = collection.UpdateMany(ctx, bson.M{"tenantId": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenantId": DefaultTenant}})
+	if err != nil {
+		return r, err
+	}
 	indexModel := mongo.IndexModel{
-		Keys:    bson.M{"email": 1},
+		Keys:    bson.D{{Key: "tenantId", Value: 1}, {Key: "email", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	}
-	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+	_, err = collection.Indexes().CreateOne(ctx, indexModel)
 
-	return &MongoUserRepository{collection: collection}, err
+	return r, err
+}
+
+// ForTenant returns a repository of the users of tenantID sharing the collection.
+func (r *MongoUserRepository) ForTenant(tenantID string) UserRepository {
+	return &MongoUserRepository{collection: r.collection, hasher: r.hasher, tenantID: tenantID}
+}
+
+// scope restricts filter to the users of the tenant.
+func (r *MongoUserRepository) scope(filter bson.M) bson.M {
+	filter["tenantId"] = r.tenantID
+	return filter
 }
 
 func (r *MongoUserRepository) Create(user User) (User, error) {
 	ctx := context.Background()
-	hashedPassword, err := HashPassword(*user.Password)
+	hashedPassword, err := r.hasher.Hash(*user.Password)
 	if err != nil {
 		return User{}, err
 	}
 	user.Password = &hashedPassword
+	user.TenantID = r.tenantID
 	doc, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
 		return User{}, err
@@ -62,9 +133,7 @@ func (r *MongoUserRepository) FindOne(id string) (User, error) {
 	if err != nil {
 		return User{}, err
 	}
-	err = r.collection.FindOne(ctx, bson.D{
-		{"_id", objID},
-	}).Decode(&user)
+	err = r.collection.FindOne(ctx, r.scope(bson.M{"_id": objID})).Decode(&user)
 	if err != nil {
 		return User{}, err
 	}
@@ -74,9 +143,7 @@ func (r *MongoUserRepository) FindOne(id string) (User, error) {
 func (r *MongoUserRepository) FindByEmail(email string) (User, error) {
 	ctx := context.Background()
 	var user User
-	err := r.collection.FindOne(ctx, bson.D{
-		{"email", email},
-	}).Decode(&user)
+	err := r.collection.FindOne(ctx, r.scope(bson.M{"email": email})).Decode(&user)
 	if err != nil {
 		return User{}, err
 	}
@@ -90,9 +157,7 @@ func (r *MongoUserRepository) Delete(id string) error {
 		return err
 	}
 
-	_, err = r.collection.DeleteOne(ctx, bson.D{
-		{"_id", objID},
-	})
+	_, err = r.collection.DeleteOne(ctx, r.scope(bson.M{"_id": objID}))
 	if err != nil {
 		return err
 	}
@@ -102,7 +167,7 @@ func (r *MongoUserRepository) Delete(id string) error {
 func (r *MongoUserRepository) Update(user User) (User, error) {
 	ctx := context.Background()
 	if user.Password != nil {
-		hashedPassword, err := HashPassword(*user.Password)
+		hashedPassword, err := r.hasher.Hash(*user.Password)
 		if err != nil {
 			return User{}, err
 		}
@@ -112,15 +177,13 @@ func (r *MongoUserRepository) Update(user User) (User, error) {
 	if err != nil {
 		return User{}, err
 	}
-	_, err = r.collection.UpdateOne(ctx, bson.D{
-		{"_id", objID},
-	}, bson.D{
-		{"$set", bson.D{
-			{"email", user.Email},
-			{"firstName", user.FirstName},
-			{"lastName", user.LastName},
-			{"password", user.Password},
-		}},
+	_, err = r.collection.UpdateOne(ctx, r.scope(bson.M{"_id": objID}), bson.M{
+		"$set": bson.M{
+			"email":     user.Email,
+			"firstName": user.FirstName,
+			"lastName":  user.LastName,
+			"password":  user.Password,
+		},
 	})
 	if err != nil {
 		return User{}, err
@@ -128,12 +191,114 @@ func (r *MongoUserRepository) Update(user User) (User, error) {
 	return user, nil
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+func (r *MongoUserRepository) VerifyEmail(id string) error {
+	return r.updateOne(id, bson.M{}, bson.M{"$set": bson.M{"emailVerified": true}})
+}
+
+// UpdatePassword hashes and stores a new password and records when it was
+// changed. The hash of the replaced password is kept in the password history,
+// which is trimmed to the last history hashes.
+func (r *MongoUserRepository) UpdatePassword(id, password string, at time.Time, history int) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := r.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	var passwordHistory interface{} = bson.A{}
+	if history > 0 {
+		passwordHistory = bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{bson.M{"$ifNull": bson.A{"$passwordHistory", bson.A{}}}, bson.A{"$password"}}},
+			-history,
+		}}
+	}
+	// All fields of a $set stage are computed from the document before the
+	// update, so the replaced password is added to the history.
+	result, err := r.collection.UpdateOne(ctx, r.scope(bson.M{"_id": objID}), mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"passwordHistory":   passwordHistory,
+			"password":          hashedPassword,
+			"passwordChangedAt": at,
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// SetTOTPSecret stores a TOTP secret to be confirmed, it returns
+// mongo.ErrNoDocuments when the user already enabled TOTP.
+func (r *MongoUserRepository) SetTOTPSecret(id, secret string) error {
+	return r.updateOne(id, bson.M{"totpEnabled": bson.M{"$ne": true}}, bson.M{
+		"$set":   bson.M{"totpSecret": secret, "totpEnabled": false},
+		"$unset": bson.M{"totpCounter": "", "recoveryCodes": ""},
+	})
+}
+
+// EnableTOTP enables the stored TOTP secret with the counter of the
+// confirming code and the hashes of the recovery codes.
+func (r *MongoUserRepository) EnableTOTP(id string, counter int64, recoveryCodes []string) error {
+	return r.updateOne(id, bson.M{"totpSecret": bson.M{"$exists": true}, "totpEnabled": bson.M{"$ne": true}}, bson.M{
+		"$set": bson.M{"totpEnabled": true, "totpCounter": counter, "recoveryCodes": recoveryCodes},
+	})
+}
+
+func (r *MongoUserRepository) DisableTOTP(id string) error {
+	return r.updateOne(id, bson.M{}, bson.M{
+		"$set":   bson.M{"totpEnabled": false},
+		"$unset": bson.M{"totpSecret": "", "totpCounter": "", "recoveryCodes": ""},
+	})
+}
+
+// UseTOTPCounter records the counter of an accepted code, it returns
+// mongo.ErrNoDocuments when a code of the same or a later period was accepted before.
+func (r *MongoUserRepository) UseTOTPCounter(id string, counter int64) error {
+	return r.updateOne(id, bson.M{"$or": bson.A{
+		bson.M{"totpCounter": bson.M{"$lt": counter}},
+		bson.M{"totpCounter": bson.M{"$exists": false}},
+	}}, bson.M{"$set": bson.M{"totpCounter": counter}})
+}
+
+// UseRecoveryCode removes the hash of a recovery code, it returns
+// mongo.ErrNoDocuments when the user has no such unused code.
+func (r *MongoUserRepository) UseRecoveryCode(id, recoveryCode string) error {
+	return r.updateOne(id, bson.M{"recoveryCodes": recoveryCode}, bson.M{
+		"$pull": bson.M{"recoveryCodes": recoveryCode},
+	})
+}
+
+// GrantRole adds role to the roles of the user, granting it twice has no effect.
+func (r *MongoUserRepository) GrantRole(id, role string) error {
+	return r.updateOne(id, bson.M{}, bson.M{"$addToSet": bson.M{"roles": role}})
+}
+
+// RevokeRole removes role from the roles of the user.
+func (r *MongoUserRepository) RevokeRole(id, role string) error {
+	return r.updateOne(id, bson.M{}, bson.M{"$pull": bson.M{"roles": role}})
+}
+
+// updateOne updates the user matching filter and returns
+// mongo.ErrNoDocuments when there is none.
+func (r *MongoUserRepository) updateOne(id string, filter bson.M, update bson.M) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter["_id"] = objID
+	result, err := r.collection.UpdateOne(ctx, r.scope(filter), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
@@ -10,7 +10,7 @@ package repo
 
 import (
 	reflect "reflect"
-
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -67,6 +67,34 @@ func (mr *MockUserRepositoryMockRecorder) Delete(id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepository)(nil).Delete), id)
 }
 
+// DisableTOTP mocks base method.
+func (m *MockUserRepository) DisableTOTP(id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockUserRepositoryMockRecorder) DisableTOTP(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockUserRepository)(nil).DisableTOTP), id)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockUserRepository) EnableTOTP(id string, counter int64, recoveryCodes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", id, counter, recoveryCodes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockUserRepositoryMockRecorder) EnableTOTP(id, counter, recoveryCodes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockUserRepository)(nil).EnableTOTP), id, counter, recoveryCodes)
+}
+
 // FindByEmail mocks base method.
 func (m *MockUserRepository) FindByEmail(email string) (User, error) {
 	m.ctrl.T.Helper()
@@ -97,6 +125,48 @@ func (mr *MockUserRepositoryMockRecorder) FindOne(id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockUserRepository)(nil).FindOne), id)
 }
 
+// GrantRole mocks base method.
+func (m *MockUserRepository) GrantRole(id, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", id, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockUserRepositoryMockRecorder) GrantRole(id, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockUserRepository)(nil).GrantRole), id, role)
+}
+
+// RevokeRole mocks base method.
+func (m *MockUserRepository) RevokeRole(id, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", id, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockUserRepositoryMockRecorder) RevokeRole(id, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockUserRepository)(nil).RevokeRole), id, role)
+}
+
+// SetTOTPSecret mocks base method.
+func (m *MockUserRepository) SetTOTPSecret(id, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPSecret", id, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTPSecret indicates an expected call of SetTOTPSecret.
+func (mr *MockUserRepositoryMockRecorder) SetTOTPSecret(id, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPSecret", reflect.TypeOf((*MockUserRepository)(nil).SetTOTPSecret), id, secret)
+}
+
 // Update mocks base method.
 func (m *MockUserRepository) Update(user User) (User, error) {
 	m.ctrl.T.Helper()
@@ -111,3 +181,96 @@ func (mr *MockUserRepositoryMockRecorder) Update(user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserRepository)(nil).Update), user)
 }
+
+// UpdatePassword mocks base method.
+func (m *MockUserRepository) UpdatePassword(id, password string, at time.Time, history int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", id, password, at, history)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryMockRecorder) UpdatePassword(id, password, at, history any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepository)(nil).UpdatePassword), id, password, at, history)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockUserRepository) UseRecoveryCode(id, recoveryCode string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", id, recoveryCode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockUserRepositoryMockRecorder) UseRecoveryCode(id, recoveryCode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockUserRepository)(nil).UseRecoveryCode), id, recoveryCode)
+}
+
+// UseTOTPCounter mocks base method.
+func (m *MockUserRepository) UseTOTPCounter(id string, counter int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPCounter", id, counter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPCounter indicates an expected call of UseTOTPCounter.
+func (mr *MockUserRepositoryMockRecorder) UseTOTPCounter(id, counter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPCounter", reflect.TypeOf((*MockUserRepository)(nil).UseTOTPCounter), id, counter)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserRepository) VerifyEmail(id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserRepositoryMockRecorder) VerifyEmail(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserRepository)(nil).VerifyEmail), id)
+}
+
+// MockUserTenants is a mock of UserTenants interface.
+type MockUserTenants struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserTenantsMockRecorder
+}
+
+// MockUserTenantsMockRecorder is the mock recorder for MockUserTenants.
+type MockUserTenantsMockRecorder struct {
+	mock *MockUserTenants
+}
+
+// NewMockUserTenants creates a new mock instance.
+func NewMockUserTenants(ctrl *gomock.Controller) *MockUserTenants {
+	mock := &MockUserTenants{ctrl: ctrl}
+	mock.recorder = &MockUserTenantsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserTenants) EXPECT() *MockUserTenantsMockRecorder {
+	return m.recorder
+}
+
+// ForTenant mocks base method.
+func (m *MockUserTenants) ForTenant(tenantID string) UserRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForTenant", tenantID)
+	ret0, _ := ret[0].(UserRepository)
+	return ret0
+}
+
+// ForTenant indicates an expected call of ForTenant.
+func (mr *MockUserTenantsMockRecorder) ForTenant(tenantID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForTenant", reflect.TypeOf((*MockUserTenants)(nil).ForTenant), tenantID)
+}
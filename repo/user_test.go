@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Davut97/go-user/pkg/password"
 	"github.com/Pallinder/go-randomdata"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -23,14 +26,21 @@ func SetUpDB() (*mongo.Database, error) {
 
 }
 
+var hasher = password.NewDefaultHasher()
+
 func passwordString(password string) *string {
 	return &password
 }
+
+func checkPasswordHash(plain, hash string) bool {
+	match, _, err := hasher.Verify(plain, hash)
+	return err == nil && match
+}
 func TestCreate(t *testing.T) {
 	db, err := SetUpDB()
 	require.NoError(t, err)
 	collection := db.Collection("users")
-	repo, err := NewMongoUserRepository(collection)
+	repo, err := NewMongoUserRepository(collection, hasher)
 	require.NoError(t, err)
 	testUser := User{
 		Email:     randomdata.Email(),
@@ -55,7 +65,7 @@ func TestCreate(t *testing.T) {
 	require.Equal(t, user.FirstName, newUser.FirstName)
 	require.Equal(t, user.LastName, newUser.LastName)
 	require.Equal(t, user.Password, newUser.Password)
-	require.True(t, CheckPasswordHash(*testUser.Password, *newUser.Password))
+	require.True(t, checkPasswordHash(*testUser.Password, *newUser.Password))
 
 	userByEmail, err := repo.FindByEmail(testUser.Email)
 	require.NoError(t, err)
@@ -65,7 +75,7 @@ func TestCreate(t *testing.T) {
 	require.Equal(t, user.FirstName, userByEmail.FirstName)
 	require.Equal(t, user.LastName, userByEmail.LastName)
 	require.Equal(t, user.Password, userByEmail.Password)
-	require.True(t, CheckPasswordHash(*testUser.Password, *newUser.Password))
+	require.True(t, checkPasswordHash(*testUser.Password, *newUser.Password))
 	// Test duplicate email
 	_, err = repo.Create(testUser)
 	require.Error(t, err)
@@ -76,7 +86,7 @@ func TestDelete(t *testing.T) {
 	db, err := SetUpDB()
 	require.NoError(t, err)
 	collection := db.Collection("users")
-	repo, err := NewMongoUserRepository(collection)
+	repo, err := NewMongoUserRepository(collection, hasher)
 	require.NoError(t, err)
 	testUser := User{
 		Email:     randomdata.Email(),
@@ -104,7 +114,7 @@ func TestUpdateUpdatedPassword(t *testing.T) {
 	db, err := SetUpDB()
 	require.NoError(t, err)
 	collection := db.Collection("users")
-	repo, err := NewMongoUserRepository(collection)
+	repo, err := NewMongoUserRepository(collection, hasher)
 	require.NoError(t, err)
 	testUser := User{
 		Email:     randomdata.Email(),
@@ -144,7 +154,7 @@ func TestUpdate(t *testing.T) {
 	db, err := SetUpDB()
 	require.NoError(t, err)
 	collection := db.Collection("users")
-	repo, err := NewMongoUserRepository(collection)
+	repo, err := NewMongoUserRepository(collection, hasher)
 	require.NoError(t, err)
 	testUser := User{
 		Email:     randomdata.Email(),
@@ -179,3 +189,152 @@ func TestUpdate(t *testing.T) {
 	require.Equal(t, newUser.Password, updatedUser.Password)
 
 }
+
+func TestVerifyEmail(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	repo, err := NewMongoUserRepository(db.Collection("users"), hasher)
+	require.NoError(t, err)
+	user, err := repo.Create(User{
+		Email:    randomdata.Email(),
+		Password: passwordString(randomdata.Alphanumeric(12)),
+	})
+	require.NoError(t, err)
+	require.False(t, user.EmailVerified)
+
+	require.NoError(t, repo.VerifyEmail(user.ID))
+	found, err := repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.True(t, found.EmailVerified)
+
+	require.ErrorIs(t, repo.VerifyEmail("6553a1e1f1d2c3b4a5968778"), mongo.ErrNoDocuments)
+}
+
+func TestUpdatePassword(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	repo, err := NewMongoUserRepository(db.Collection("users"), hasher)
+	require.NoError(t, err)
+	user, err := repo.Create(User{
+		Email:    randomdata.Email(),
+		Password: passwordString(randomdata.Alphanumeric(12)),
+	})
+	require.NoError(t, err)
+
+	changedAt := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, repo.UpdatePassword(user.ID, "new-password", changedAt, 2))
+	found, err := repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.True(t, checkPasswordHash("new-password", *found.Password))
+	require.Equal(t, changedAt, *found.PasswordChangedAt)
+	require.Equal(t, []string{*user.Password}, found.PasswordHistory)
+
+	// Only the last two previous hashes are kept
+	require.NoError(t, repo.UpdatePassword(user.ID, "newer-password", changedAt, 2))
+	require.NoError(t, repo.UpdatePassword(user.ID, "newest-password", changedAt, 2))
+	found, err = repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.Len(t, found.PasswordHistory, 2)
+	require.True(t, checkPasswordHash("new-password", found.PasswordHistory[0]))
+	require.True(t, checkPasswordHash("newer-password", found.PasswordHistory[1]))
+
+	require.ErrorIs(t, repo.UpdatePassword("6553a1e1f1d2c3b4a5968778", "new-password", changedAt, 2), mongo.ErrNoDocuments)
+}
+
+func TestTOTP(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	repo, err := NewMongoUserRepository(db.Collection("users"), hasher)
+	require.NoError(t, err)
+	user, err := repo.Create(User{
+		Email:    randomdata.Email(),
+		Password: passwordString(randomdata.Alphanumeric(12)),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SetTOTPSecret(user.ID, "sealed-secret"))
+	require.NoError(t, repo.EnableTOTP(user.ID, 100, []string{"code-1", "code-2"}))
+	found, err := repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.True(t, found.TOTPEnabled)
+	require.Equal(t, "sealed-secret", found.TOTPSecret)
+	require.Equal(t, int64(100), found.TOTPCounter)
+
+	// An enabled secret can not be replaced without disabling it first
+	require.ErrorIs(t, repo.SetTOTPSecret(user.ID, "other-secret"), mongo.ErrNoDocuments)
+
+	// Codes can not be replayed
+	require.ErrorIs(t, repo.UseTOTPCounter(user.ID, 100), mongo.ErrNoDocuments)
+	require.NoError(t, repo.UseTOTPCounter(user.ID, 101))
+
+	// Recovery codes are single-use
+	require.NoError(t, repo.UseRecoveryCode(user.ID, "code-1"))
+	require.ErrorIs(t, repo.UseRecoveryCode(user.ID, "code-1"), mongo.ErrNoDocuments)
+	found, err = repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"code-2"}, found.RecoveryCodes)
+
+	require.NoError(t, repo.DisableTOTP(user.ID))
+	found, err = repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.False(t, found.TOTPEnabled)
+	require.Empty(t, found.TOTPSecret)
+	require.Empty(t, found.RecoveryCodes)
+}
+
+func TestRoles(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	repo, err := NewMongoUserRepository(db.Collection("users"), hasher)
+	require.NoError(t, err)
+	user, err := repo.Create(User{
+		Email:    randomdata.Email(),
+		Password: passwordString(randomdata.Alphanumeric(12)),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.GrantRole(user.ID, "admin"))
+	require.NoError(t, repo.GrantRole(user.ID, "moderator"))
+	require.NoError(t, repo.GrantRole(user.ID, "admin"))
+	found, err := repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin", "moderator"}, found.Roles)
+
+	require.NoError(t, repo.RevokeRole(user.ID, "admin"))
+	found, err = repo.FindOne(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"moderator"}, found.Roles)
+
+	require.ErrorIs(t, repo.GrantRole(primitive.NewObjectID().Hex(), "admin"), mongo.ErrNoDocuments)
+}
+
+func TestTenants(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	repo, err := NewMongoUserRepository(db.Collection("users"), hasher)
+	require.NoError(t, err)
+	acme := repo.ForTenant("acme-" + randomdata.Alphanumeric(8))
+	globex := repo.ForTenant("globex-" + randomdata.Alphanumeric(8))
+
+	// Emails are unique per tenant
+	email := randomdata.Email()
+	acmeUser, err := acme.Create(User{Email: email, Password: passwordString(randomdata.Alphanumeric(12))})
+	require.NoError(t, err)
+	globexUser, err := globex.Create(User{Email: email, Password: passwordString(randomdata.Alphanumeric(12))})
+	require.NoError(t, err)
+	_, err = acme.Create(User{Email: email, Password: passwordString(randomdata.Alphanumeric(12))})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	found, err := acme.FindByEmail(email)
+	require.NoError(t, err)
+	require.Equal(t, acmeUser.ID, found.ID)
+	require.NotEqual(t, acmeUser.TenantID, globexUser.TenantID)
+
+	// Users of other tenants can neither be read nor changed
+	_, err = acme.FindOne(globexUser.ID)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	require.ErrorIs(t, acme.GrantRole(globexUser.ID, "admin"), mongo.ErrNoDocuments)
+	require.NoError(t, acme.Delete(globexUser.ID))
+	_, err = globex.FindOne(globexUser.ID)
+	require.NoError(t, err)
+}
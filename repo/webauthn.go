@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user,
+// identified by its base64url encoded credential ID.
+type WebAuthnCredential struct {
+	ID     string `json:"id" bson:"_id"`
+	UserID string `json:"-" bson:"userId"`
+	// Name is chosen by the user to tell their credentials apart.
+	Name            string   `json:"name" bson:"name"`
+	PublicKey       []byte   `json:"-" bson:"publicKey"`
+	AttestationType string   `json:"-" bson:"attestationType"`
+	Transports      []string `json:"transports,omitempty" bson:"transports,omitempty"`
+	AAGUID          []byte   `json:"-" bson:"aaguid,omitempty"`
+	// SignCount is the last signature counter the authenticator reported, a
+	// counter that does not increase hints at a cloned authenticator.
+	SignCount      uint32     `json:"-" bson:"signCount"`
+	BackupEligible bool       `json:"backupEligible" bson:"backupEligible"`
+	BackupState    bool       `json:"backupState" bson:"backupState"`
+	CreatedAt      time.Time  `json:"createdAt" bson:"createdAt"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+type WebAuthnCredentialRepository interface {
+	Create(credential WebAuthnCredential) error
+	FindOne(id string) (WebAuthnCredential, error)
+	FindByUser(userID string) ([]WebAuthnCredential, error)
+	UpdateSignCount(id string, from, to uint32, backupState bool, at time.Time) error
+	Delete(id, userID string) error
+}
+
+type MongoWebAuthnCredentialRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWebAuthnCredentialRepository(collection *mongo.Collection) (*MongoWebAuthnCredentialRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys: bson.M{"userId": 1},
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoWebAuthnCredentialRepository{collection: collection}, err
+}
+
+func (r *MongoWebAuthnCredentialRepository) Create(credential WebAuthnCredential) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, credential)
+	return err
+}
+
+func (r *MongoWebAuthnCredentialRepository) FindOne(id string) (WebAuthnCredential, error) {
+	ctx := context.Background()
+	var credential WebAuthnCredential
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&credential)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// FindByUser returns the credentials of the user, oldest first.
+func (r *MongoWebAuthnCredentialRepository) FindByUser(userID string) ([]WebAuthnCredential, error) {
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	credentials := []WebAuthnCredential{}
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateSignCount records a login with the credential. It only succeeds while
+// the stored counter is still from, otherwise the credential was used
+// concurrently and mongo.ErrNoDocuments is returned.
+func (r *MongoWebAuthnCredentialRepository) UpdateSignCount(id string, from, to uint32, backupState bool, at time.Time) error {
+	ctx := context.Background()
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "signCount": from}, bson.M{"$set": bson.M{
+		"signCount":   to,
+		"backupState": backupState,
+		"lastUsedAt":  at,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete removes a credential of the user, it returns mongo.ErrNoDocuments
+// when the user has no such credential.
+func (r *MongoWebAuthnCredentialRepository) Delete(id, userID string) error {
+	ctx := context.Background()
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// WebAuthnSession holds the challenge of a registration or login ceremony
+// until the authenticator's response arrives. It is removed once ExpiresAt
+// has passed.
+type WebAuthnSession struct {
+	ID string `bson:"_id"`
+	// UserID is empty for logins, the user is only known from the credential used.
+	UserID           string    `bson:"userId,omitempty"`
+	Purpose          string    `bson:"purpose"`
+	Challenge        string    `bson:"challenge"`
+	UserVerification string    `bson:"userVerification"`
+	ExpiresAt        time.Time `bson:"expiresAt"`
+}
+
+type WebAuthnSessionRepository interface {
+	Create(session WebAuthnSession) error
+	Consume(id, purpose string, at time.Time) (WebAuthnSession, error)
+}
+
+type MongoWebAuthnSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWebAuthnSessionRepository(collection *mongo.Collection) (*MongoWebAuthnSessionRepository, error) {
+	indexModel := mongo.IndexModel{
+		Keys:    bson.M{"expiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), indexModel)
+
+	return &MongoWebAuthnSessionRepository{collection: collection}, err
+}
+
+func (r *MongoWebAuthnSessionRepository) Create(session WebAuthnSession) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+// Consume atomically removes the session, so that every challenge is only
+// answered once. It returns mongo.ErrNoDocuments when the session is unknown,
+// expired or was started for another purpose.
+func (r *MongoWebAuthnSessionRepository) Consume(id, purpose string, at time.Time) (WebAuthnSession, error) {
+	ctx := context.Background()
+	var session WebAuthnSession
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"_id":       id,
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": at},
+	}).Decode(&session)
+	if err != nil {
+		return WebAuthnSession{}, err
+	}
+	return session, nil
+}
@@ -0,0 +1,163 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webauthn.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webauthn.go -destination=./webauthn_mock.go
+//
+// Package mock_repo is a generated GoMock package.
+package repo
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockWebAuthnCredentialRepository is a mock of WebAuthnCredentialRepository interface.
+type MockWebAuthnCredentialRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebAuthnCredentialRepositoryMockRecorder
+}
+
+// MockWebAuthnCredentialRepositoryMockRecorder is the mock recorder for MockWebAuthnCredentialRepository.
+type MockWebAuthnCredentialRepositoryMockRecorder struct {
+	mock *MockWebAuthnCredentialRepository
+}
+
+// NewMockWebAuthnCredentialRepository creates a new mock instance.
+func NewMockWebAuthnCredentialRepository(ctrl *gomock.Controller) *MockWebAuthnCredentialRepository {
+	mock := &MockWebAuthnCredentialRepository{ctrl: ctrl}
+	mock.recorder = &MockWebAuthnCredentialRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebAuthnCredentialRepository) EXPECT() *MockWebAuthnCredentialRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockWebAuthnCredentialRepository) Create(credential WebAuthnCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", credential)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) Create(credential any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).Create), credential)
+}
+
+// Delete mocks base method.
+func (m *MockWebAuthnCredentialRepository) Delete(id, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) Delete(id, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).Delete), id, userID)
+}
+
+// FindByUser mocks base method.
+func (m *MockWebAuthnCredentialRepository) FindByUser(userID string) ([]WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUser", userID)
+	ret0, _ := ret[0].([]WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUser indicates an expected call of FindByUser.
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) FindByUser(userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUser", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).FindByUser), userID)
+}
+
+// FindOne mocks base method.
+func (m *MockWebAuthnCredentialRepository) FindOne(id string) (WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOne", id)
+	ret0, _ := ret[0].(WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOne indicates an expected call of FindOne.
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) FindOne(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOne", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).FindOne), id)
+}
+
+// UpdateSignCount mocks base method.
+func (m *MockWebAuthnCredentialRepository) UpdateSignCount(id string, from, to uint32, backupState bool, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignCount", id, from, to, backupState, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSignCount indicates an expected call of UpdateSignCount.
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) UpdateSignCount(id, from, to, backupState, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignCount", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).UpdateSignCount), id, from, to, backupState, at)
+}
+
+// MockWebAuthnSessionRepository is a mock of WebAuthnSessionRepository interface.
+type MockWebAuthnSessionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebAuthnSessionRepositoryMockRecorder
+}
+
+// MockWebAuthnSessionRepositoryMockRecorder is the mock recorder for MockWebAuthnSessionRepository.
+type MockWebAuthnSessionRepositoryMockRecorder struct {
+	mock *MockWebAuthnSessionRepository
+}
+
+// NewMockWebAuthnSessionRepository creates a new mock instance.
+func NewMockWebAuthnSessionRepository(ctrl *gomock.Controller) *MockWebAuthnSessionRepository {
+	mock := &MockWebAuthnSessionRepository{ctrl: ctrl}
+	mock.recorder = &MockWebAuthnSessionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebAuthnSessionRepository) EXPECT() *MockWebAuthnSessionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockWebAuthnSessionRepository) Consume(id, purpose string, at time.Time) (WebAuthnSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", id, purpose, at)
+	ret0, _ := ret[0].(WebAuthnSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockWebAuthnSessionRepositoryMockRecorder) Consume(id, purpose, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockWebAuthnSessionRepository)(nil).Consume), id, purpose, at)
+}
+
+// Create mocks base method.
+func (m *MockWebAuthnSessionRepository) Create(session WebAuthnSession) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockWebAuthnSessionRepositoryMockRecorder) Create(session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockWebAuthnSessionRepository)(nil).Create), session)
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWebAuthnCredential(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	credentialRepo, err := NewMongoWebAuthnCredentialRepository(db.Collection("webauthn_credentials"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	userID := randomdata.Alphanumeric(24)
+	credential := WebAuthnCredential{
+		ID:        randomdata.Alphanumeric(32),
+		UserID:    userID,
+		Name:      "laptop",
+		PublicKey: []byte{1, 2, 3},
+		SignCount: 5,
+		CreatedAt: now,
+	}
+	require.NoError(t, credentialRepo.Create(credential))
+	require.True(t, mongo.IsDuplicateKeyError(credentialRepo.Create(credential)))
+	found, err := credentialRepo.FindOne(credential.ID)
+	require.NoError(t, err)
+	require.Equal(t, credential, found)
+	credentials, err := credentialRepo.FindByUser(userID)
+	require.NoError(t, err)
+	require.Equal(t, []WebAuthnCredential{credential}, credentials)
+
+	require.NoError(t, credentialRepo.UpdateSignCount(credential.ID, 5, 6, true, now))
+	// The counter was already moved on by another login
+	require.ErrorIs(t, credentialRepo.UpdateSignCount(credential.ID, 5, 7, true, now), mongo.ErrNoDocuments)
+	found, err = credentialRepo.FindOne(credential.ID)
+	require.NoError(t, err)
+	require.Equal(t, uint32(6), found.SignCount)
+	require.True(t, found.BackupState)
+	require.Equal(t, now, *found.LastUsedAt)
+
+	require.ErrorIs(t, credentialRepo.Delete(credential.ID, randomdata.Alphanumeric(24)), mongo.ErrNoDocuments)
+	require.NoError(t, credentialRepo.Delete(credential.ID, userID))
+	credentials, err = credentialRepo.FindByUser(userID)
+	require.NoError(t, err)
+	require.Empty(t, credentials)
+}
+
+func TestWebAuthnSessionConsume(t *testing.T) {
+	db, err := SetUpDB()
+	require.NoError(t, err)
+	sessionRepo, err := NewMongoWebAuthnSessionRepository(db.Collection("webauthn_sessions"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	session := WebAuthnSession{
+		ID:        randomdata.Alphanumeric(32),
+		Purpose:   "webauthn-login",
+		Challenge: randomdata.Alphanumeric(43),
+		ExpiresAt: now.Add(time.Minute),
+	}
+	require.NoError(t, sessionRepo.Create(session))
+	_, err = sessionRepo.Consume(session.ID, "webauthn-registration", now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	_, err = sessionRepo.Consume(session.ID, "webauthn-login", now.Add(time.Minute))
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+	consumed, err := sessionRepo.Consume(session.ID, "webauthn-login", now)
+	require.NoError(t, err)
+	require.Equal(t, session, consumed)
+
+	// A challenge can only be answered once
+	_, err = sessionRepo.Consume(session.ID, "webauthn-login", now)
+	require.ErrorIs(t, err, mongo.ErrNoDocuments)
+}